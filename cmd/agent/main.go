@@ -1,6 +1,11 @@
 package main
 
 import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/GarikMirzoyan/metricalert/internal/agent"
 	"github.com/GarikMirzoyan/metricalert/internal/agent/config"
 )
@@ -8,6 +13,10 @@ import (
 func main() {
 	config := config.InitConfig()
 
+	// Агент завершается по SIGINT, SIGTERM или SIGQUIT, отправив последний батч
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
+	defer stop()
+
 	agent := agent.NewAgent(config)
-	agent.Run()
+	agent.Run(ctx)
 }
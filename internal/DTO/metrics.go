@@ -1,8 +1,21 @@
 package dto
 
+import "github.com/GarikMirzoyan/metricalert/internal/sketch"
+
 type Metrics struct {
-	ID    string   `json:"id"`              // имя метрики
-	MType string   `json:"type"`            // параметр, принимающий значение gauge или counter
-	Delta *int64   `json:"delta,omitempty"` // значение метрики в случае передачи counter
-	Value *float64 `json:"value,omitempty"` // значение метрики в случае передачи gauge
+	ID        string            `json:"id"`                  // имя метрики
+	MType     string            `json:"type"`                // параметр, принимающий значение gauge, counter, histogram, summary, set или info
+	Delta     *int64            `json:"delta,omitempty"`     // значение метрики в случае передачи counter
+	Value     *float64          `json:"value,omitempty"`     // значение gauge, одно наблюдение histogram или summary; в ответе для summary — квантиль
+	Labels    map[string]string `json:"labels,omitempty"`    // метки серии, например {"host": "web-1"}
+	Buckets   []float64         `json:"buckets,omitempty"`   // верхние границы корзин histogram по возрастанию, без +Inf
+	Counts    []uint64          `json:"counts,omitempty"`    // число наблюдений histogram в каждой корзине, последняя — +Inf
+	Sum       *float64          `json:"sum,omitempty"`       // сумма наблюдений histogram или summary
+	Count     *uint64           `json:"count,omitempty"`     // число наблюдений histogram или summary; для set — оценка числа элементов
+	Quantile  *float64          `json:"quantile,omitempty"`  // квантиль summary от 0 до 1; в запросе /value/ — какой вернуть в value
+	Sketch    *sketch.State     `json:"sketch,omitempty"`    // состояние summary (DDSketch) для слияния и хранения
+	Members   []string          `json:"members,omitempty"`   // элементы, добавляемые в set
+	HLL       *sketch.HLLState  `json:"hll,omitempty"`       // состояние set (HyperLogLog) для слияния и хранения
+	Text      *string           `json:"text,omitempty"`      // строковое значение info, например версия сборки
+	Timestamp *int64            `json:"timestamp,omitempty"` // время наблюдения в миллисекундах Unix; без него — время получения сервером
 }
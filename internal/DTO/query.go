@@ -0,0 +1,12 @@
+package dto
+
+import "github.com/GarikMirzoyan/metricalert/internal/models"
+
+type QueryResponse struct {
+	ID     string            `json:"id"`               // имя метрики
+	MType  string            `json:"type"`             // тип метрики
+	Labels map[string]string `json:"labels,omitempty"` // метки серии
+	Func   string            `json:"fn"`               // функция агрегации
+	Step   string            `json:"step"`             // шаг агрегации
+	Points []models.Sample   `json:"points"`           // агрегированные значения
+}
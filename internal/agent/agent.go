@@ -1,69 +1,250 @@
 package agent
 
 import (
+	"context"
+	"log"
+	"runtime"
+	"sync"
 	"time"
 
 	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/GarikMirzoyan/metricalert/internal/agent/collector"
 	"github.com/GarikMirzoyan/metricalert/internal/agent/config"
+	"github.com/GarikMirzoyan/metricalert/internal/agent/procfs"
+	"github.com/GarikMirzoyan/metricalert/internal/agent/sender"
+	"github.com/GarikMirzoyan/metricalert/internal/agent/spool"
+	"github.com/GarikMirzoyan/metricalert/internal/grpcclient"
 	"github.com/GarikMirzoyan/metricalert/internal/metrics"
+	"github.com/GarikMirzoyan/metricalert/internal/retry"
 )
 
+// Размер очереди батчей, ожидающих отправки
+const sendQueueSize = 10
+
+// Время на отправку оставшихся батчей при завершении агента
+const shutdownTimeout = 15 * time.Second
+
 type Agent struct {
-	config    config.Config
-	pollCount metrics.Counter
+	config     config.Config
+	registry   *collector.Registry
+	buffer     *collector.Buffer
+	pool       *sender.Pool
+	spool      *spool.Spool
+	grpcClient *grpcclient.Client
+	// Отправка в обход буфера берёт блокировку на чтение, работа с непустым буфером —
+	// на запись: пока буфер не опустеет, новые батчи ждут и не обгоняют сохранённые
+	sendMu sync.RWMutex
 }
 
 func NewAgent(config config.Config) *Agent {
-	return &Agent{
-		config:    config,
-		pollCount: 0,
+	agent := &Agent{
+		config:   config,
+		registry: collector.NewRegistry(),
+		buffer:   collector.NewBuffer(),
+	}
+
+	if config.UseGRPC() {
+		client, err := grpcclient.NewClient(config.GRPCAddress, config.Key, config.PublicKey)
+		if err != nil {
+			log.Fatalf("ошибка инициализации gRPC-клиента: %v", err)
+		}
+		agent.grpcClient = client
+	}
+
+	if config.SpoolDir != "" {
+		sp, err := spool.Open(config.SpoolDir, config.SpoolMaxSize, config.SpoolMaxAge)
+		if err != nil {
+			log.Fatalf("ошибка открытия буфера батчей: %v", err)
+		}
+		agent.spool = sp
 	}
+
+	agent.pool = sender.NewPool(config.RateLimit, sendQueueSize, agent.sendBatch)
+
+	agent.registerCollectors()
+
+	return agent
+}
+
+// Встроенные коллекторы агента
+func builtinCollectors() []collector.Collector {
+	builtins := []collector.Collector{collector.NewRuntimeCollector()}
+
+	// Метрики хоста доступны только на Linux
+	if runtime.GOOS == "linux" {
+		builtins = append(builtins, procfs.NewSystemCollector(procfs.NewFS(procfs.DefaultRoot)))
+	}
+
+	return builtins
 }
 
-func (a *Agent) Run() {
-	go a.startPolling()
-	a.startReporting()
+// Регистрация включённых в конфигурации коллекторов
+func (a *Agent) registerCollectors() {
+	known := map[string]bool{collector.RuntimeName: true, procfs.CollectorName: true}
+	for _, name := range a.config.Collectors {
+		if !known[name] {
+			log.Printf("неизвестный коллектор %q пропущен", name)
+		}
+	}
+
+	for _, c := range builtinCollectors() {
+		if !a.config.CollectorEnabled(c.Name()) {
+			continue
+		}
+		if err := a.registry.Register(c, a.config.CollectorInterval(c.Name())); err != nil {
+			log.Fatalf("ошибка регистрации коллектора: %v", err)
+		}
+	}
+
+	// Метрики очереди отправки и буфера собираются всегда
+	if err := a.registry.Register(a.pool, a.config.PollInterval); err != nil {
+		log.Fatalf("ошибка регистрации коллектора: %v", err)
+	}
+	if a.spool != nil {
+		if err := a.registry.Register(a.spool, a.config.PollInterval); err != nil {
+			log.Fatalf("ошибка регистрации коллектора: %v", err)
+		}
+	}
+}
+
+// Работа агента до отмены контекста. При завершении агент отправляет финальный
+// батч с метриками, накопленными после последней отправки, и ждёт опустошения очереди.
+func (a *Agent) Run(ctx context.Context) {
+	// Воркеры не зависят от ctx, чтобы после сигнала дослать оставшиеся батчи;
+	// отправка прерывается, если не уложилась в shutdownTimeout
+	sendCtx, cancelSend := context.WithCancel(context.Background())
+	defer cancelSend()
+	a.pool.Start(sendCtx)
+
+	collectorsDone := make(chan struct{})
+	go func() {
+		defer close(collectorsDone)
+		a.registry.Run(ctx, a.buffer.Add)
+	}()
+
+	a.startReporting(ctx)
+	<-collectorsDone
+
+	if batch := a.prepareMetricsBatch(); len(batch) > 0 {
+		if !a.pool.Submit(sender.NewBatch(batch)) {
+			log.Printf("очередь отправки переполнена, финальный батч отброшен")
+		}
+	}
+
+	a.shutdown(cancelSend)
 }
 
-func (a *Agent) startPolling() {
-	ticker := time.NewTicker(a.config.PollInterval)
-	for range ticker.C {
-		a.pollCount++
+func (a *Agent) shutdown(cancelSend context.CancelFunc) {
+	done := make(chan struct{})
+	go func() {
+		a.pool.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(shutdownTimeout):
+		log.Printf("не все батчи отправлены за %s до завершения агента", shutdownTimeout)
+		// Прерываем текущие запросы и ретраи; батчи в буфере на диске будут отправлены при следующем запуске
+		cancelSend()
+		<-done
+	}
+
+	if a.grpcClient != nil {
+		a.grpcClient.Close()
 	}
 }
 
-func (a *Agent) startReporting() {
+func (a *Agent) startReporting(ctx context.Context) {
 	ticker := time.NewTicker(a.config.ReportInterval)
-	for range ticker.C {
-		batch := a.prepareMetricsBatch()
-		if len(batch) == 0 {
-			continue
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			batch := a.prepareMetricsBatch()
+			if len(batch) == 0 {
+				continue
+			}
+			if !a.pool.Submit(sender.NewBatch(batch)) {
+				log.Printf("очередь отправки переполнена, батч из %d метрик отброшен", len(batch))
+			}
 		}
-		metrics.SendBatchMetrics(batch, a.config)
 	}
 }
 
-func (a *Agent) prepareMetricsBatch() []dto.Metrics {
-	var batch []dto.Metrics
-
-	// Собираем gauge метрики
-	collected := metrics.CollectMetrics()
-	for name, value := range collected {
-		val := float64(value)
-		batch = append(batch, dto.Metrics{
-			ID:    name,
-			MType: "gauge",
-			Value: &val,
-		})
-	}
-
-	// Добавляем PollCount как counter
-	delta := int64(a.pollCount)
-	batch = append(batch, dto.Metrics{
-		ID:    "PollCount",
-		MType: "counter",
-		Delta: &delta,
+// Отправка батча воркером пула.
+// Если сервер недоступен, батч сохраняется в буфер на диске; пока буфер не пуст,
+// новые батчи ставятся за сохранёнными, чтобы сервер получил их в исходном порядке.
+func (a *Agent) sendBatch(ctx context.Context, batch sender.Batch) {
+	if a.spool == nil {
+		if err := a.deliver(ctx, batch); err != nil {
+			log.Printf("не удалось отправить батч метрик: %v", err)
+		}
+		return
+	}
+
+	a.sendMu.RLock()
+	if a.spool.Len() == 0 {
+		a.deliverOrSpool(ctx, batch)
+		a.sendMu.RUnlock()
+		return
+	}
+	a.sendMu.RUnlock()
+
+	// Буфер не пуст: ждём завершения текущих отправок, сохраняем батч за остальными
+	// и переотправляем буфер; остальные воркеры в это время ждут
+	a.sendMu.Lock()
+	defer a.sendMu.Unlock()
+	if err := a.spool.Push(batch); err != nil {
+		log.Printf("не удалось сохранить батч в буфер: %v", err)
+	}
+	a.replaySpool(ctx)
+}
+
+// Отправка батча; если сервер недоступен, батч сохраняется в буфер
+func (a *Agent) deliverOrSpool(ctx context.Context, batch sender.Batch) {
+	err := a.deliver(ctx, batch)
+	if err == nil {
+		return
+	}
+	if !retry.IsRetriableError(err) {
+		log.Printf("сервер отклонил батч метрик: %v", err)
+		return
+	}
+
+	log.Printf("сервер недоступен, батч сохранён в буфер: %v", err)
+	if err := a.spool.Push(batch); err != nil {
+		log.Printf("не удалось сохранить батч в буфер: %v", err)
+	}
+}
+
+// Переотправка сохранённых батчей; отклонённые сервером батчи удаляются из буфера
+func (a *Agent) replaySpool(ctx context.Context) {
+	err := a.spool.Replay(ctx, func(ctx context.Context, batch sender.Batch) error {
+		err := a.deliver(ctx, batch)
+		if err != nil && !retry.IsRetriableError(err) {
+			log.Printf("сервер отклонил батч %s из буфера: %v", batch.ID, err)
+			return nil
+		}
+		return err
 	})
+	if err != nil {
+		log.Printf("не удалось отправить батчи из буфера, осталось %d: %v", a.spool.Len(), err)
+	}
+}
 
-	return batch
+// Отправка батча по выбранному в конфигурации транспорту
+func (a *Agent) deliver(ctx context.Context, batch sender.Batch) error {
+	if a.grpcClient != nil {
+		return a.grpcClient.SendBatch(ctx, batch.ID, batch.Metrics)
+	}
+	return metrics.SendBatchMetrics(batch.Metrics, batch.ID, a.config, ctx)
+}
+
+// Батч из накопленных коллекторами метрик: последние значения gauge и приращения counter с прошлой отправки
+func (a *Agent) prepareMetricsBatch() []dto.Metrics {
+	return a.buffer.Drain()
 }
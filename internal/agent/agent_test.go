@@ -0,0 +1,119 @@
+package agent
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/GarikMirzoyan/metricalert/internal/agent/config"
+	"github.com/GarikMirzoyan/metricalert/internal/agent/sender"
+	"github.com/GarikMirzoyan/metricalert/internal/agent/spool"
+	"github.com/GarikMirzoyan/metricalert/internal/idempotency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSendsFinalBatchOnShutdown(t *testing.T) {
+	var mu sync.Mutex
+	var received [][]dto.Metrics
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reader, err := gzip.NewReader(r.Body)
+		require.NoError(t, err)
+
+		var batch []dto.Metrics
+		require.NoError(t, json.NewDecoder(reader).Decode(&batch))
+
+		mu.Lock()
+		received = append(received, batch)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	agent := NewAgent(config.Config{
+		Address: server.URL,
+		// Отправка по таймеру не успеет сработать, батч уйдёт только при завершении
+		ReportInterval: time.Hour,
+		PollInterval:   10 * time.Millisecond,
+		RateLimit:      1,
+		Collectors:     []string{"runtime"},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		agent.Run(ctx)
+		close(done)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("агент не завершился после отмены контекста")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, received, 1)
+
+	var pollCount *dto.Metrics
+	for i, metric := range received[0] {
+		if metric.ID == "PollCount" {
+			pollCount = &received[0][i]
+		}
+	}
+	require.NotNil(t, pollCount)
+	assert.Positive(t, *pollCount.Delta)
+}
+
+func TestSendBatchWaitsForSpoolReplay(t *testing.T) {
+	var mu sync.Mutex
+	var received []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		received = append(received, r.Header.Get(idempotency.HeaderName))
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	sp, err := spool.Open(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+	agent := &Agent{config: config.Config{Address: server.URL}, spool: sp}
+
+	value := 1.0
+	newBatch := func() sender.Batch {
+		return sender.NewBatch([]dto.Metrics{{ID: "Alloc", MType: "gauge", Value: &value}})
+	}
+	spooled := []sender.Batch{newBatch(), newBatch()}
+	for _, batch := range spooled {
+		require.NoError(t, sp.Push(batch))
+	}
+
+	// Несколько воркеров одновременно: новые батчи не обгоняют сохранённые в буфере
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			agent.sendBatch(context.Background(), newBatch())
+		}()
+	}
+	wg.Wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, received, 6)
+	assert.Equal(t, []string{spooled[0].ID, spooled[1].ID}, received[:2])
+	assert.Zero(t, sp.Len())
+}
@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"sort"
+	"sync"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+)
+
+// Накопитель метрик между отправками.
+// Для gauge хранится последнее значение, оно остаётся и после Drain;
+// приращения counter суммируются и обнуляются при Drain.
+type Buffer struct {
+	mu       sync.Mutex
+	gauges   map[string]dto.Metrics
+	counters map[string]dto.Metrics
+}
+
+func NewBuffer() *Buffer {
+	return &Buffer{
+		gauges:   make(map[string]dto.Metrics),
+		counters: make(map[string]dto.Metrics),
+	}
+}
+
+func (b *Buffer) Add(batch []dto.Metrics) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, metric := range batch {
+		key := models.SeriesKey(metric.ID, metric.Labels)
+
+		switch constants.MetricType(metric.MType) {
+		case constants.GaugeName:
+			if metric.Value == nil {
+				continue
+			}
+			value := *metric.Value
+			metric.Value = &value
+			b.gauges[key] = metric
+		case constants.CounterName:
+			if metric.Delta == nil {
+				continue
+			}
+			delta := *metric.Delta
+			if existing, ok := b.counters[key]; ok {
+				delta += *existing.Delta
+			}
+			metric.Delta = &delta
+			b.counters[key] = metric
+		}
+	}
+}
+
+// Батч для отправки: все gauge и накопленные приращения counter
+func (b *Buffer) Drain() []dto.Metrics {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	batch := make([]dto.Metrics, 0, len(b.gauges)+len(b.counters))
+	for _, key := range sortedKeys(b.gauges) {
+		metric := b.gauges[key]
+		value := *metric.Value
+		metric.Value = &value
+		batch = append(batch, metric)
+	}
+	for _, key := range sortedKeys(b.counters) {
+		batch = append(batch, b.counters[key])
+	}
+
+	b.counters = make(map[string]dto.Metrics)
+	return batch
+}
+
+func sortedKeys(m map[string]dto.Metrics) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,132 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+)
+
+var (
+	ErrDuplicateCollector = errors.New("collector already registered")
+	ErrInvalidInterval    = errors.New("collector poll interval must be positive")
+)
+
+// Источник метрик агента.
+// Gauge-метрики возвращаются текущими значениями, counter-метрики — приращениями с прошлого вызова.
+// При частичной ошибке коллектор может вернуть и собранные метрики, и ошибку.
+type Collector interface {
+	Name() string
+	Collect(ctx context.Context) ([]dto.Metrics, error)
+}
+
+type entry struct {
+	collector Collector
+	interval  time.Duration
+}
+
+// Набор зарегистрированных коллекторов со своими интервалами опроса
+type Registry struct {
+	mu      sync.Mutex
+	entries []entry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) Register(c Collector, interval time.Duration) error {
+	if interval <= 0 {
+		return fmt.Errorf("%w: %s", ErrInvalidInterval, c.Name())
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, e := range r.entries {
+		if e.collector.Name() == c.Name() {
+			return fmt.Errorf("%w: %s", ErrDuplicateCollector, c.Name())
+		}
+	}
+	r.entries = append(r.entries, entry{collector: c, interval: interval})
+	return nil
+}
+
+// Имена зарегистрированных коллекторов в порядке регистрации
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.entries))
+	for _, e := range r.entries {
+		names = append(names, e.collector.Name())
+	}
+	return names
+}
+
+// Опрос всех коллекторов, каждого со своим интервалом, до отмены контекста.
+// Результаты передаются в sink; ошибка или паника одного коллектора не влияет на остальные.
+func (r *Registry) Run(ctx context.Context, sink func([]dto.Metrics)) {
+	r.mu.Lock()
+	entries := make([]entry, len(r.entries))
+	copy(entries, r.entries)
+	r.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, e := range entries {
+		wg.Add(1)
+		go func(e entry) {
+			defer wg.Done()
+			poll(ctx, e, sink)
+		}(e)
+	}
+	wg.Wait()
+}
+
+func poll(ctx context.Context, e entry, sink func([]dto.Metrics)) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		if batch := collectSafely(ctx, e.collector); len(batch) > 0 {
+			sink(batch)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func collectSafely(ctx context.Context, c Collector) (batch []dto.Metrics) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("паника в коллекторе %s: %v", c.Name(), r)
+			batch = nil
+		}
+	}()
+
+	batch, err := c.Collect(ctx)
+	if err != nil {
+		log.Printf("ошибка коллектора %s: %v", c.Name(), err)
+	}
+	stamp(batch, time.Now())
+	return batch
+}
+
+// Время сбора для метрик без метки: при задержке отправки сервер запишет
+// значения на момент наблюдения, а не получения
+func stamp(batch []dto.Metrics, now time.Time) {
+	timestamp := now.UnixMilli()
+	for i := range batch {
+		if batch[i].Timestamp == nil {
+			batch[i].Timestamp = &timestamp
+		}
+	}
+}
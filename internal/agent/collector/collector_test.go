@@ -0,0 +1,164 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type funcCollector struct {
+	name  string
+	calls atomic.Int64
+	fn    func() ([]dto.Metrics, error)
+}
+
+func (c *funcCollector) Name() string {
+	return c.name
+}
+
+func (c *funcCollector) Collect(ctx context.Context) ([]dto.Metrics, error) {
+	c.calls.Add(1)
+	return c.fn()
+}
+
+func gauge(id string, value float64) dto.Metrics {
+	return dto.Metrics{ID: id, MType: "gauge", Value: &value}
+}
+
+func counter(id string, delta int64) dto.Metrics {
+	return dto.Metrics{ID: id, MType: "counter", Delta: &delta}
+}
+
+func TestRegister(t *testing.T) {
+	registry := NewRegistry()
+	ok := func() ([]dto.Metrics, error) { return nil, nil }
+
+	require.NoError(t, registry.Register(&funcCollector{name: "a", fn: ok}, time.Second))
+	require.NoError(t, registry.Register(&funcCollector{name: "b", fn: ok}, time.Second))
+
+	err := registry.Register(&funcCollector{name: "a", fn: ok}, time.Second)
+	assert.ErrorIs(t, err, ErrDuplicateCollector)
+
+	err = registry.Register(&funcCollector{name: "c", fn: ok}, 0)
+	assert.ErrorIs(t, err, ErrInvalidInterval)
+
+	assert.Equal(t, []string{"a", "b"}, registry.Names())
+}
+
+func TestRunIsolatesFailingCollectors(t *testing.T) {
+	registry := NewRegistry()
+
+	good := &funcCollector{name: "good", fn: func() ([]dto.Metrics, error) {
+		return []dto.Metrics{counter("ticks", 1)}, nil
+	}}
+	failing := &funcCollector{name: "failing", fn: func() ([]dto.Metrics, error) {
+		return nil, errors.New("boom")
+	}}
+	panicking := &funcCollector{name: "panicking", fn: func() ([]dto.Metrics, error) {
+		panic("collector bug")
+	}}
+	partial := &funcCollector{name: "partial", fn: func() ([]dto.Metrics, error) {
+		return []dto.Metrics{gauge("partial", 1)}, errors.New("one source failed")
+	}}
+
+	for _, c := range []*funcCollector{good, failing, panicking, partial} {
+		require.NoError(t, registry.Register(c, 5*time.Millisecond))
+	}
+
+	buffer := NewBuffer()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		registry.Run(ctx, buffer.Add)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		return good.calls.Load() >= 3 && panicking.calls.Load() >= 3
+	}, 2*time.Second, 5*time.Millisecond)
+	cancel()
+	<-done
+
+	batch := buffer.Drain()
+	ids := make(map[string]dto.Metrics)
+	for _, m := range batch {
+		ids[m.ID] = m
+	}
+	assert.Contains(t, ids, "partial")
+	require.Contains(t, ids, "ticks")
+	assert.Equal(t, good.calls.Load(), *ids["ticks"].Delta)
+	// Метрики помечаются временем сбора
+	require.NotNil(t, ids["ticks"].Timestamp)
+	assert.WithinDuration(t, time.Now(), time.UnixMilli(*ids["ticks"].Timestamp), time.Minute)
+	assert.GreaterOrEqual(t, failing.calls.Load(), int64(3))
+}
+
+func TestRunUsesPerCollectorIntervals(t *testing.T) {
+	registry := NewRegistry()
+	noop := func() ([]dto.Metrics, error) { return nil, nil }
+
+	fast := &funcCollector{name: "fast", fn: noop}
+	slow := &funcCollector{name: "slow", fn: noop}
+	require.NoError(t, registry.Register(fast, 5*time.Millisecond))
+	require.NoError(t, registry.Register(slow, time.Hour))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		registry.Run(ctx, func([]dto.Metrics) {})
+	}()
+
+	require.Eventually(t, func() bool { return fast.calls.Load() >= 5 }, 2*time.Second, 5*time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	// Медленный коллектор опрашивается сразу при запуске и больше не успевает
+	assert.Equal(t, int64(1), slow.calls.Load())
+}
+
+func TestBuffer(t *testing.T) {
+	buffer := NewBuffer()
+
+	buffer.Add([]dto.Metrics{gauge("Alloc", 1), counter("PollCount", 1)})
+	buffer.Add([]dto.Metrics{gauge("Alloc", 2), counter("PollCount", 1)})
+
+	labeled := counter("PollCount", 5)
+	labeled.Labels = map[string]string{"host": "web-1"}
+	buffer.Add([]dto.Metrics{labeled})
+
+	batch := buffer.Drain()
+	require.Len(t, batch, 3)
+	assert.Equal(t, "Alloc", batch[0].ID)
+	assert.Equal(t, 2.0, *batch[0].Value)
+	assert.Equal(t, int64(2), *batch[1].Delta)
+	assert.Nil(t, batch[1].Labels)
+	assert.Equal(t, int64(5), *batch[2].Delta)
+
+	// После отправки gauge сохраняются, приращения counter обнуляются
+	batch = buffer.Drain()
+	require.Len(t, batch, 1)
+	assert.Equal(t, "Alloc", batch[0].ID)
+}
+
+func TestRuntimeCollector(t *testing.T) {
+	batch, err := NewRuntimeCollector().Collect(context.Background())
+	require.NoError(t, err)
+
+	ids := make(map[string]dto.Metrics)
+	for _, m := range batch {
+		ids[m.ID] = m
+	}
+	require.Contains(t, ids, "HeapAlloc")
+	assert.Equal(t, "gauge", ids["HeapAlloc"].MType)
+	require.Contains(t, ids, "PollCount")
+	assert.Equal(t, int64(1), *ids["PollCount"].Delta)
+}
@@ -0,0 +1,46 @@
+package collector
+
+import (
+	"context"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/metrics"
+)
+
+const RuntimeName = "runtime"
+
+// Встроенный коллектор статистики runtime.MemStats агента.
+// Каждый опрос также увеличивает PollCount на единицу.
+type RuntimeCollector struct{}
+
+func NewRuntimeCollector() *RuntimeCollector {
+	return &RuntimeCollector{}
+}
+
+func (c *RuntimeCollector) Name() string {
+	return RuntimeName
+}
+
+func (c *RuntimeCollector) Collect(ctx context.Context) ([]dto.Metrics, error) {
+	collected := metrics.CollectMetrics()
+
+	batch := make([]dto.Metrics, 0, len(collected)+1)
+	for name, value := range collected {
+		val := float64(value)
+		batch = append(batch, dto.Metrics{
+			ID:    name,
+			MType: string(constants.GaugeName),
+			Value: &val,
+		})
+	}
+
+	pollCount := int64(1)
+	batch = append(batch, dto.Metrics{
+		ID:    "PollCount",
+		MType: string(constants.CounterName),
+		Delta: &pollCount,
+	})
+
+	return batch, nil
+}
@@ -1,10 +1,22 @@
 package config
 
 import (
+	"crypto/rsa"
 	"flag"
+	"fmt"
+	"log"
 	"os"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/encryption"
+)
+
+// Транспорт для отправки метрик на сервер
+const (
+	TransportHTTP = "http"
+	TransportGRPC = "grpc"
 )
 
 // Структура конфигурации для агента
@@ -12,6 +24,19 @@ type Config struct {
 	Address        string
 	ReportInterval time.Duration
 	PollInterval   time.Duration
+	Key            string
+	CryptoKey      string
+	PublicKey      *rsa.PublicKey
+	Transport      string
+	GRPCAddress    string
+	RateLimit      int
+	// Буфер неотправленных батчей на диске; пустой каталог отключает буфер
+	SpoolDir     string
+	SpoolMaxSize int64
+	SpoolMaxAge  time.Duration
+	// Включённые коллекторы и их интервалы опроса; без интервала используется PollInterval
+	Collectors         []string
+	CollectorIntervals map[string]time.Duration
 }
 
 func InitConfig() Config {
@@ -19,11 +44,31 @@ func InitConfig() Config {
 	defaultAddress := "localhost:8080"
 	defaultReportInterval := 10 * time.Second
 	defaultPollInterval := 2 * time.Second
+	defaultKey := ""
+	defaultCryptoKey := ""
+	defaultTransport := TransportHTTP
+	defaultGRPCAddress := "localhost:3200"
+	defaultRateLimit := 1
+	defaultSpoolDir := ""
+	defaultSpoolMaxSize := int64(10 << 20)
+	defaultSpoolMaxAge := time.Hour
+	defaultCollectors := "runtime,system"
+	defaultCollectorIntervals := ""
 
 	// Читаем флаги командной строки
 	address := flag.String("a", defaultAddress, "HTTP server address (without http:// or https://)")
 	reportInterval := flag.Int("r", int(defaultReportInterval.Seconds()), "Report interval in seconds")
 	pollInterval := flag.Int("p", int(defaultPollInterval.Seconds()), "Poll interval in seconds")
+	key := flag.String("k", defaultKey, "Key for HMAC-SHA256 signing of requests")
+	cryptoKey := flag.String("crypto-key", defaultCryptoKey, "Path to PEM file with server RSA public key")
+	transport := flag.String("transport", defaultTransport, "Transport for sending metrics: http or grpc")
+	grpcAddress := flag.String("grpc-address", defaultGRPCAddress, "gRPC server address")
+	rateLimit := flag.Int("l", defaultRateLimit, "Maximum number of concurrent requests to the server")
+	spoolDir := flag.String("spool-dir", defaultSpoolDir, "Directory for batches that failed to send, empty to disable")
+	spoolMaxSize := flag.Int64("spool-max-size", defaultSpoolMaxSize, "Maximum total size of spooled batches in bytes")
+	spoolMaxAge := flag.Int("spool-max-age", int(defaultSpoolMaxAge.Seconds()), "Maximum age of spooled batches in seconds")
+	collectors := flag.String("collectors", defaultCollectors, "Comma-separated list of enabled collectors")
+	collectorIntervals := flag.String("collector-intervals", defaultCollectorIntervals, "Per-collector poll intervals, e.g. system=10s,runtime=2")
 	flag.Parse()
 
 	// Читаем переменные окружения
@@ -43,6 +88,75 @@ func InitConfig() Config {
 		}
 	}
 
+	if envKey := os.Getenv("KEY"); envKey != "" {
+		*key = envKey
+	}
+
+	if envCryptoKey := os.Getenv("CRYPTO_KEY"); envCryptoKey != "" {
+		*cryptoKey = envCryptoKey
+	}
+
+	if envTransport := os.Getenv("TRANSPORT"); envTransport != "" {
+		*transport = envTransport
+	}
+
+	if envGRPCAddress := os.Getenv("GRPC_ADDRESS"); envGRPCAddress != "" {
+		*grpcAddress = envGRPCAddress
+	}
+
+	if envRateLimit := os.Getenv("RATE_LIMIT"); envRateLimit != "" {
+		if rl, err := strconv.Atoi(envRateLimit); err == nil {
+			*rateLimit = rl
+		}
+	}
+
+	if *rateLimit < 1 {
+		log.Fatalf("RATE_LIMIT должен быть не меньше 1, получено %d", *rateLimit)
+	}
+
+	if envSpoolDir := os.Getenv("SPOOL_DIR"); envSpoolDir != "" {
+		*spoolDir = envSpoolDir
+	}
+
+	if envSpoolMaxSize := os.Getenv("SPOOL_MAX_SIZE"); envSpoolMaxSize != "" {
+		if size, err := strconv.ParseInt(envSpoolMaxSize, 10, 64); err == nil {
+			*spoolMaxSize = size
+		}
+	}
+
+	if envSpoolMaxAge := os.Getenv("SPOOL_MAX_AGE"); envSpoolMaxAge != "" {
+		if age, err := time.ParseDuration(envSpoolMaxAge + "s"); err == nil {
+			*spoolMaxAge = int(age.Seconds())
+		}
+	}
+
+	if envCollectors, ok := os.LookupEnv("COLLECTORS"); ok {
+		*collectors = envCollectors
+	}
+
+	if envCollectorIntervals := os.Getenv("COLLECTOR_INTERVALS"); envCollectorIntervals != "" {
+		*collectorIntervals = envCollectorIntervals
+	}
+
+	intervals, err := parseIntervals(*collectorIntervals)
+	if err != nil {
+		log.Fatalf("некорректные интервалы коллекторов: %v", err)
+	}
+
+	if *transport != TransportHTTP && *transport != TransportGRPC {
+		log.Fatalf("неизвестный транспорт %q: ожидается %s или %s", *transport, TransportHTTP, TransportGRPC)
+	}
+
+	// Открытый ключ загружается один раз при старте
+	var publicKey *rsa.PublicKey
+	if *cryptoKey != "" {
+		pk, err := encryption.LoadPublicKey(*cryptoKey)
+		if err != nil {
+			log.Fatalf("не удалось загрузить открытый ключ: %v", err)
+		}
+		publicKey = pk
+	}
+
 	finalAddress := *address
 	if !strings.HasPrefix(finalAddress, "http://") && !strings.HasPrefix(finalAddress, "https://") {
 		finalAddress = "http://" + finalAddress
@@ -52,5 +166,70 @@ func InitConfig() Config {
 		Address:        finalAddress,
 		ReportInterval: time.Duration(*reportInterval) * time.Second,
 		PollInterval:   time.Duration(*pollInterval) * time.Second,
+		Key:            *key,
+		CryptoKey:      *cryptoKey,
+		PublicKey:      publicKey,
+		Transport:      *transport,
+		GRPCAddress:    *grpcAddress,
+		RateLimit:      *rateLimit,
+		SpoolDir:       *spoolDir,
+		SpoolMaxSize:   *spoolMaxSize,
+		SpoolMaxAge:    time.Duration(*spoolMaxAge) * time.Second,
+
+		Collectors:         splitList(*collectors),
+		CollectorIntervals: intervals,
+	}
+}
+
+func (c Config) UseGRPC() bool {
+	return c.Transport == TransportGRPC
+}
+
+func (c Config) CollectorEnabled(name string) bool {
+	for _, enabled := range c.Collectors {
+		if enabled == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (c Config) CollectorInterval(name string) time.Duration {
+	if interval, ok := c.CollectorIntervals[name]; ok {
+		return interval
+	}
+	return c.PollInterval
+}
+
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// Разбор списка вида "system=10s,runtime=2"; число без единиц — секунды
+func parseIntervals(value string) (map[string]time.Duration, error) {
+	intervals := make(map[string]time.Duration)
+	for _, item := range splitList(value) {
+		name, raw, ok := strings.Cut(item, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("ожидается name=interval, получено %q", item)
+		}
+
+		var interval time.Duration
+		if seconds, err := strconv.Atoi(raw); err == nil {
+			interval = time.Duration(seconds) * time.Second
+		} else if interval, err = time.ParseDuration(raw); err != nil {
+			return nil, fmt.Errorf("интервал коллектора %s: %w", name, err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("интервал коллектора %s должен быть положительным", name)
+		}
+		intervals[strings.TrimSpace(name)] = interval
 	}
+	return intervals, nil
 }
@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIntervals(t *testing.T) {
+	intervals, err := parseIntervals("system=10s, runtime=2,fast=500ms")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]time.Duration{
+		"system":  10 * time.Second,
+		"runtime": 2 * time.Second,
+		"fast":    500 * time.Millisecond,
+	}, intervals)
+
+	for _, invalid := range []string{"system", "system=abc", "system=0", "=5s"} {
+		_, err := parseIntervals(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestCollectorSettings(t *testing.T) {
+	cfg := Config{
+		PollInterval:       2 * time.Second,
+		Collectors:         []string{"runtime"},
+		CollectorIntervals: map[string]time.Duration{"runtime": 5 * time.Second},
+	}
+
+	assert.True(t, cfg.CollectorEnabled("runtime"))
+	assert.False(t, cfg.CollectorEnabled("system"))
+	assert.Equal(t, 5*time.Second, cfg.CollectorInterval("runtime"))
+	assert.Equal(t, 2*time.Second, cfg.CollectorInterval("system"))
+}
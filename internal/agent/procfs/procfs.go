@@ -0,0 +1,288 @@
+package procfs
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Корень файловой системы /proc по умолчанию; в тестах подменяется на каталог с фикстурами
+const DefaultRoot = "/proc"
+
+// Размер сектора в /proc/diskstats всегда 512 байт, независимо от устройства
+const sectorSize = 512
+
+// Времена CPU из /proc/stat в тиках
+type CPUTimes struct {
+	User    uint64
+	Nice    uint64
+	System  uint64
+	Idle    uint64
+	IOWait  uint64
+	IRQ     uint64
+	SoftIRQ uint64
+	Steal   uint64
+}
+
+func (t CPUTimes) Total() uint64 {
+	return t.User + t.Nice + t.System + t.Idle + t.IOWait + t.IRQ + t.SoftIRQ + t.Steal
+}
+
+func (t CPUTimes) idle() uint64 {
+	return t.Idle + t.IOWait
+}
+
+// Память из /proc/meminfo в байтах
+type MemInfo struct {
+	Total     uint64
+	Free      uint64
+	Available uint64
+}
+
+type LoadAvg struct {
+	Load1  float64
+	Load5  float64
+	Load15 float64
+}
+
+// Счётчики устройства из /proc/diskstats в байтах
+type DiskStats struct {
+	Device     string
+	ReadBytes  uint64
+	WriteBytes uint64
+}
+
+// Счётчики сетевого интерфейса из /proc/net/dev
+type NetDevStats struct {
+	Interface     string
+	ReceiveBytes  uint64
+	TransmitBytes uint64
+}
+
+// Чтение статистики из файлов /proc относительно заданного корня
+type FS struct {
+	root string
+}
+
+func NewFS(root string) FS {
+	return FS{root: root}
+}
+
+// Времена по каждому CPU, ключ — номер CPU ("0", "1", ...); суммарная строка "cpu" пропускается
+func (fs FS) CPUStats() (map[string]CPUTimes, error) {
+	result := make(map[string]CPUTimes)
+
+	err := fs.scanLines("stat", func(line string) error {
+		fields := strings.Fields(line)
+		if len(fields) < 5 || !strings.HasPrefix(fields[0], "cpu") || fields[0] == "cpu" {
+			return nil
+		}
+
+		values := make([]uint64, 8)
+		for i := 1; i < len(fields) && i <= len(values); i++ {
+			v, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return fmt.Errorf("stat: некорректное значение %q: %w", fields[i], err)
+			}
+			values[i-1] = v
+		}
+
+		result[strings.TrimPrefix(fields[0], "cpu")] = CPUTimes{
+			User:    values[0],
+			Nice:    values[1],
+			System:  values[2],
+			Idle:    values[3],
+			IOWait:  values[4],
+			IRQ:     values[5],
+			SoftIRQ: values[6],
+			Steal:   values[7],
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (fs FS) MemInfo() (MemInfo, error) {
+	var info MemInfo
+	var foundTotal, foundFree bool
+
+	err := fs.scanLines("meminfo", func(line string) error {
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil
+		}
+
+		var target *uint64
+		switch name {
+		case "MemTotal":
+			target, foundTotal = &info.Total, true
+		case "MemFree":
+			target, foundFree = &info.Free, true
+		case "MemAvailable":
+			target = &info.Available
+		default:
+			return nil
+		}
+
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return fmt.Errorf("meminfo: пустое значение %s", name)
+		}
+		v, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("meminfo: некорректное значение %s: %w", name, err)
+		}
+		if len(fields) > 1 && fields[1] == "kB" {
+			v *= 1024
+		}
+		*target = v
+		return nil
+	})
+	if err != nil {
+		return MemInfo{}, err
+	}
+	if !foundTotal || !foundFree {
+		return MemInfo{}, fmt.Errorf("meminfo: нет MemTotal или MemFree")
+	}
+
+	return info, nil
+}
+
+func (fs FS) LoadAvg() (LoadAvg, error) {
+	data, err := os.ReadFile(fs.path("loadavg"))
+	if err != nil {
+		return LoadAvg{}, err
+	}
+
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		return LoadAvg{}, fmt.Errorf("loadavg: неожиданный формат %q", string(data))
+	}
+
+	var loads [3]float64
+	for i := range loads {
+		v, err := strconv.ParseFloat(fields[i], 64)
+		if err != nil {
+			return LoadAvg{}, fmt.Errorf("loadavg: некорректное значение %q: %w", fields[i], err)
+		}
+		loads[i] = v
+	}
+
+	return LoadAvg{Load1: loads[0], Load5: loads[1], Load15: loads[2]}, nil
+}
+
+// Статистика блочных устройств; виртуальные loop и ram пропускаются
+func (fs FS) DiskStats() ([]DiskStats, error) {
+	var result []DiskStats
+
+	err := fs.scanLines("diskstats", func(line string) error {
+		fields := strings.Fields(line)
+		if len(fields) < 10 {
+			return nil
+		}
+
+		device := fields[2]
+		if strings.HasPrefix(device, "loop") || strings.HasPrefix(device, "ram") {
+			return nil
+		}
+
+		sectorsRead, err := strconv.ParseUint(fields[5], 10, 64)
+		if err != nil {
+			return fmt.Errorf("diskstats: некорректное значение для %s: %w", device, err)
+		}
+		sectorsWritten, err := strconv.ParseUint(fields[9], 10, 64)
+		if err != nil {
+			return fmt.Errorf("diskstats: некорректное значение для %s: %w", device, err)
+		}
+
+		result = append(result, DiskStats{
+			Device:     device,
+			ReadBytes:  sectorsRead * sectorSize,
+			WriteBytes: sectorsWritten * sectorSize,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (fs FS) NetDev() ([]NetDevStats, error) {
+	var result []NetDevStats
+
+	err := fs.scanLines(filepath.Join("net", "dev"), func(line string) error {
+		// Две строки заголовка не содержат значений после двоеточия в нужном формате
+		name, rest, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil
+		}
+		fields := strings.Fields(rest)
+		if len(fields) < 16 {
+			return nil
+		}
+
+		received, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("net/dev: некорректное значение для %s: %w", name, err)
+		}
+		transmitted, err := strconv.ParseUint(fields[8], 10, 64)
+		if err != nil {
+			return fmt.Errorf("net/dev: некорректное значение для %s: %w", name, err)
+		}
+
+		result = append(result, NetDevStats{
+			Interface:     strings.TrimSpace(name),
+			ReceiveBytes:  received,
+			TransmitBytes: transmitted,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Загрузка CPU в процентах между двумя замерами
+func CPUUtilization(prev, cur CPUTimes) float64 {
+	total := float64(cur.Total()) - float64(prev.Total())
+	if total <= 0 {
+		return 0
+	}
+	idle := float64(cur.idle()) - float64(prev.idle())
+	utilization := (total - idle) / total * 100
+	if utilization < 0 {
+		return 0
+	}
+	return utilization
+}
+
+func (fs FS) path(name string) string {
+	return filepath.Join(fs.root, name)
+}
+
+func (fs FS) scanLines(name string, fn func(line string) error) error {
+	file, err := os.Open(fs.path(name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if err := fn(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,140 @@
+package procfs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	fixtureRoot     = filepath.Join("testdata", "proc")
+	fixtureNextRoot = filepath.Join("testdata", "proc-next")
+)
+
+func TestCPUStats(t *testing.T) {
+	cpus, err := NewFS(fixtureRoot).CPUStats()
+	require.NoError(t, err)
+
+	require.Len(t, cpus, 2)
+	assert.Equal(t, CPUTimes{User: 1000, Nice: 50, System: 500, Idle: 8000, IOWait: 200, SoftIRQ: 50}, cpus["0"])
+	assert.Equal(t, uint64(9800), cpus["1"].Total())
+}
+
+func TestCPUUtilization(t *testing.T) {
+	prev, err := NewFS(fixtureRoot).CPUStats()
+	require.NoError(t, err)
+	cur, err := NewFS(fixtureNextRoot).CPUStats()
+	require.NoError(t, err)
+
+	assert.InDelta(t, 75.0, CPUUtilization(prev["0"], cur["0"]), 1e-9)
+	assert.InDelta(t, 25.0, CPUUtilization(prev["1"], cur["1"]), 1e-9)
+	assert.Equal(t, 0.0, CPUUtilization(cur["0"], cur["0"]))
+}
+
+func TestMemInfo(t *testing.T) {
+	mem, err := NewFS(fixtureRoot).MemInfo()
+	require.NoError(t, err)
+
+	assert.Equal(t, uint64(16384000*1024), mem.Total)
+	assert.Equal(t, uint64(4096000*1024), mem.Free)
+	assert.Equal(t, uint64(8192000*1024), mem.Available)
+}
+
+func TestLoadAvg(t *testing.T) {
+	load, err := NewFS(fixtureRoot).LoadAvg()
+	require.NoError(t, err)
+
+	assert.Equal(t, LoadAvg{Load1: 0.52, Load5: 0.58, Load15: 0.59}, load)
+}
+
+func TestDiskStats(t *testing.T) {
+	disks, err := NewFS(fixtureRoot).DiskStats()
+	require.NoError(t, err)
+
+	assert.Equal(t, []DiskStats{
+		{Device: "sda", ReadBytes: 200000 * 512, WriteBytes: 400000 * 512},
+		{Device: "sda1", ReadBytes: 180000 * 512, WriteBytes: 380000 * 512},
+	}, disks)
+}
+
+func TestNetDev(t *testing.T) {
+	ifaces, err := NewFS(fixtureRoot).NetDev()
+	require.NoError(t, err)
+
+	assert.Equal(t, []NetDevStats{
+		{Interface: "lo", ReceiveBytes: 123456, TransmitBytes: 123456},
+		{Interface: "eth0", ReceiveBytes: 98765432, TransmitBytes: 12345678},
+	}, ifaces)
+}
+
+func TestMissingFiles(t *testing.T) {
+	fs := NewFS(t.TempDir())
+
+	_, err := fs.CPUStats()
+	assert.Error(t, err)
+	_, err = fs.MemInfo()
+	assert.Error(t, err)
+	_, err = fs.LoadAvg()
+	assert.Error(t, err)
+}
+
+func TestSystemCollector(t *testing.T) {
+	collector := NewSystemCollector(NewFS(fixtureRoot))
+
+	first, err := collector.Collect(context.Background())
+	require.NoError(t, err)
+	byKey := indexMetrics(first)
+
+	// Первый замер: только мгновенные значения, без загрузки CPU и приращений
+	assert.Equal(t, float64(16384000*1024), *byKey["gauge/TotalMemory"].Value)
+	assert.Equal(t, float64(4096000*1024), *byKey["gauge/FreeMemory"].Value)
+	assert.Equal(t, 0.52, *byKey["gauge/LoadAverage1"].Value)
+	assert.Equal(t, 0.59, *byKey["gauge/LoadAverage15"].Value)
+	assert.NotContains(t, byKey, `gauge/CPUutilization{cpu="0"}`)
+	assert.NotContains(t, byKey, `counter/DiskReadBytes{device="sda"}`)
+
+	collector.fs = NewFS(fixtureNextRoot)
+	second, err := collector.Collect(context.Background())
+	require.NoError(t, err)
+	byKey = indexMetrics(second)
+
+	assert.InDelta(t, 75.0, *byKey[`gauge/CPUutilization{cpu="0"}`].Value, 1e-9)
+	assert.InDelta(t, 25.0, *byKey[`gauge/CPUutilization{cpu="1"}`].Value, 1e-9)
+	assert.Equal(t, 1.25, *byKey["gauge/LoadAverage1"].Value)
+
+	assert.Equal(t, int64(100*512), *byKey[`counter/DiskReadBytes{device="sda"}`].Delta)
+	assert.Equal(t, int64(300*512), *byKey[`counter/DiskWriteBytes{device="sda"}`].Delta)
+	assert.NotContains(t, byKey, `counter/DiskReadBytes{device="loop0"}`)
+
+	assert.Equal(t, int64(1000), *byKey[`counter/NetworkReceiveBytes{interface="lo"}`].Delta)
+	assert.Equal(t, int64(1000), *byKey[`counter/NetworkTransmitBytes{interface="eth0"}`].Delta)
+	// Сброс счётчика интерфейса: приращение равно текущему значению
+	assert.Equal(t, int64(1000), *byKey[`counter/NetworkReceiveBytes{interface="eth0"}`].Delta)
+}
+
+func TestSystemCollectorPartialFailure(t *testing.T) {
+	root := t.TempDir()
+	collector := NewSystemCollector(NewFS(root))
+
+	batch, err := collector.Collect(context.Background())
+	assert.Error(t, err)
+	assert.Empty(t, batch)
+
+	collector.fs = NewFS(fixtureRoot)
+	batch, err = collector.Collect(context.Background())
+	require.NoError(t, err)
+	assert.NotEmpty(t, batch)
+}
+
+func indexMetrics(batch []dto.Metrics) map[string]dto.Metrics {
+	result := make(map[string]dto.Metrics, len(batch))
+	for _, metric := range batch {
+		result[metric.MType+"/"+models.SeriesKey(metric.ID, metric.Labels)] = metric
+	}
+	return result
+}
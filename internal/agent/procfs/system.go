@@ -0,0 +1,148 @@
+package procfs
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+)
+
+// Сбор метрик хоста из /proc.
+// Загрузка CPU и байтовые счётчики дисков и сетей считаются как разница с предыдущим замером,
+// поэтому первый вызов Collect только запоминает базовые значения для них.
+type SystemCollector struct {
+	fs FS
+
+	mu      sync.Mutex
+	prevCPU map[string]CPUTimes
+	prevIO  map[string]uint64
+}
+
+// Имя коллектора в конфигурации агента
+const CollectorName = "system"
+
+func NewSystemCollector(fs FS) *SystemCollector {
+	return &SystemCollector{fs: fs}
+}
+
+func (c *SystemCollector) Name() string {
+	return CollectorName
+}
+
+// Метрики хоста. Ошибка одного источника не мешает остальным: возвращается
+// всё, что удалось прочитать, и объединённая ошибка.
+func (c *SystemCollector) Collect(ctx context.Context) ([]dto.Metrics, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var batch []dto.Metrics
+	var errs []error
+
+	if cpus, err := c.fs.CPUStats(); err != nil {
+		errs = append(errs, err)
+	} else {
+		batch = append(batch, c.cpuMetrics(cpus)...)
+	}
+
+	if mem, err := c.fs.MemInfo(); err != nil {
+		errs = append(errs, err)
+	} else {
+		batch = append(batch,
+			gaugeMetric("TotalMemory", float64(mem.Total), nil),
+			gaugeMetric("FreeMemory", float64(mem.Free), nil),
+		)
+	}
+
+	if load, err := c.fs.LoadAvg(); err != nil {
+		errs = append(errs, err)
+	} else {
+		batch = append(batch,
+			gaugeMetric("LoadAverage1", load.Load1, nil),
+			gaugeMetric("LoadAverage5", load.Load5, nil),
+			gaugeMetric("LoadAverage15", load.Load15, nil),
+		)
+	}
+
+	current := make(map[string]uint64)
+
+	if disks, err := c.fs.DiskStats(); err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, disk := range disks {
+			labels := map[string]string{"device": disk.Device}
+			batch = c.appendDelta(batch, current, "DiskReadBytes", labels, disk.ReadBytes)
+			batch = c.appendDelta(batch, current, "DiskWriteBytes", labels, disk.WriteBytes)
+		}
+	}
+
+	if ifaces, err := c.fs.NetDev(); err != nil {
+		errs = append(errs, err)
+	} else {
+		for _, iface := range ifaces {
+			labels := map[string]string{"interface": iface.Interface}
+			batch = c.appendDelta(batch, current, "NetworkReceiveBytes", labels, iface.ReceiveBytes)
+			batch = c.appendDelta(batch, current, "NetworkTransmitBytes", labels, iface.TransmitBytes)
+		}
+	}
+
+	c.prevIO = current
+
+	return batch, errors.Join(errs...)
+}
+
+func (c *SystemCollector) cpuMetrics(cpus map[string]CPUTimes) []dto.Metrics {
+	ids := make([]string, 0, len(cpus))
+	for id := range cpus {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var batch []dto.Metrics
+	for _, id := range ids {
+		prev, ok := c.prevCPU[id]
+		if !ok {
+			continue
+		}
+		batch = append(batch, gaugeMetric("CPUutilization", CPUUtilization(prev, cpus[id]), map[string]string{"cpu": id}))
+	}
+
+	c.prevCPU = cpus
+	return batch
+}
+
+// Счётчик отправляется приращением; при сбросе счётчика (перезагрузка, переполнение)
+// приращением считается текущее значение
+func (c *SystemCollector) appendDelta(batch []dto.Metrics, current map[string]uint64, name string, labels map[string]string, value uint64) []dto.Metrics {
+	key := models.SeriesKey(name, labels)
+	current[key] = value
+
+	prev, ok := c.prevIO[key]
+	if !ok {
+		return batch
+	}
+
+	delta := value
+	if value >= prev {
+		delta = value - prev
+	}
+	d := int64(delta)
+	return append(batch, dto.Metrics{
+		ID:     name,
+		MType:  string(constants.CounterName),
+		Delta:  &d,
+		Labels: labels,
+	})
+}
+
+func gaugeMetric(name string, value float64, labels map[string]string) dto.Metrics {
+	return dto.Metrics{
+		ID:     name,
+		MType:  string(constants.GaugeName),
+		Value:  &value,
+		Labels: labels,
+	}
+}
@@ -0,0 +1,33 @@
+package sender
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+)
+
+// Батч метрик для отправки.
+// Идентификатор сохраняется при повторах и при переотправке из буфера на диске,
+// поэтому сервер может отличить повтор от нового батча и не применять приращения дважды.
+type Batch struct {
+	ID        string        `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	Metrics   []dto.Metrics `json:"metrics"`
+}
+
+func NewBatch(metrics []dto.Metrics) Batch {
+	return Batch{
+		ID:        newBatchID(),
+		CreatedAt: time.Now(),
+		Metrics:   metrics,
+	}
+}
+
+func newBatchID() string {
+	buf := make([]byte, 16)
+	// На поддерживаемых платформах crypto/rand.Read не возвращает ошибок
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
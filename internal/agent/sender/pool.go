@@ -0,0 +1,123 @@
+package sender
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+)
+
+// Имя коллектора метрик самого пула
+const CollectorName = "sender"
+
+// Пул воркеров для отправки батчей на сервер.
+// Число воркеров ограничивает количество одновременных запросов к серверу;
+// если очередь заполнена, новый батч отбрасывается, а не блокирует агента.
+type Pool struct {
+	jobs    chan Batch
+	send    func(ctx context.Context, batch Batch)
+	workers int
+
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+
+	dropped         atomic.Int64
+	reportedDropped atomic.Int64
+}
+
+func NewPool(workers, queueSize int, send func(ctx context.Context, batch Batch)) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Pool{
+		jobs:    make(chan Batch, queueSize),
+		send:    send,
+		workers: workers,
+	}
+}
+
+// Запуск воркеров; они завершаются после Close, отправив оставшиеся в очереди батчи,
+// или сразу при отмене контекста
+func (p *Pool) Start(ctx context.Context) {
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go func() {
+			defer p.wg.Done()
+			p.run(ctx)
+		}()
+	}
+}
+
+func (p *Pool) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case batch, ok := <-p.jobs:
+			if !ok {
+				return
+			}
+			p.send(ctx, batch)
+		}
+	}
+}
+
+// Постановка батча в очередь без блокировки; false, если батч отброшен
+func (p *Pool) Submit(batch Batch) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		p.dropped.Add(1)
+		return false
+	}
+
+	select {
+	case p.jobs <- batch:
+		return true
+	default:
+		p.dropped.Add(1)
+		return false
+	}
+}
+
+// Закрытие очереди и ожидание, пока воркеры отправят оставшиеся батчи
+func (p *Pool) Close() {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+
+	p.wg.Wait()
+}
+
+func (p *Pool) QueueDepth() int {
+	return len(p.jobs)
+}
+
+// Общее число отброшенных батчей
+func (p *Pool) Dropped() int64 {
+	return p.dropped.Load()
+}
+
+func (p *Pool) Name() string {
+	return CollectorName
+}
+
+// Метрики пула: глубина очереди и число отброшенных с прошлого опроса батчей
+func (p *Pool) Collect(ctx context.Context) ([]dto.Metrics, error) {
+	depth := float64(p.QueueDepth())
+
+	dropped := p.dropped.Load()
+	delta := dropped - p.reportedDropped.Swap(dropped)
+
+	return []dto.Metrics{
+		{ID: "SendQueueDepth", MType: string(constants.GaugeName), Value: &depth},
+		{ID: "DroppedBatches", MType: string(constants.CounterName), Delta: &delta},
+	}, nil
+}
@@ -0,0 +1,136 @@
+package sender
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func batchOf(id string) Batch {
+	value := 1.0
+	return NewBatch([]dto.Metrics{{ID: id, MType: "gauge", Value: &value}})
+}
+
+func TestPoolLimitsConcurrency(t *testing.T) {
+	const workers = 3
+
+	var inFlight, maxInFlight, sent atomic.Int64
+	release := make(chan struct{})
+
+	pool := NewPool(workers, 20, func(ctx context.Context, batch Batch) {
+		current := inFlight.Add(1)
+		for {
+			prev := maxInFlight.Load()
+			if current <= prev || maxInFlight.CompareAndSwap(prev, current) {
+				break
+			}
+		}
+		<-release
+		inFlight.Add(-1)
+		sent.Add(1)
+	})
+	pool.Start(context.Background())
+
+	for i := 0; i < 10; i++ {
+		require.True(t, pool.Submit(batchOf("m")))
+	}
+
+	require.Eventually(t, func() bool { return inFlight.Load() == workers }, time.Second, time.Millisecond)
+	assert.Equal(t, 10-workers, pool.QueueDepth())
+
+	close(release)
+	pool.Close()
+
+	assert.Equal(t, int64(10), sent.Load())
+	assert.Equal(t, int64(workers), maxInFlight.Load())
+}
+
+func TestPoolDropsWhenQueueIsFull(t *testing.T) {
+	release := make(chan struct{})
+	pool := NewPool(1, 2, func(ctx context.Context, batch Batch) {
+		<-release
+	})
+	pool.Start(context.Background())
+
+	require.True(t, pool.Submit(batchOf("a")))
+	// Ждём, пока воркер заберёт первый батч и заблокируется на отправке
+	require.Eventually(t, func() bool { return pool.QueueDepth() == 0 }, time.Second, time.Millisecond)
+
+	assert.True(t, pool.Submit(batchOf("b")))
+	assert.True(t, pool.Submit(batchOf("c")))
+	assert.False(t, pool.Submit(batchOf("d")))
+	assert.False(t, pool.Submit(batchOf("e")))
+	assert.Equal(t, int64(2), pool.Dropped())
+
+	close(release)
+	pool.Close()
+
+	assert.False(t, pool.Submit(batchOf("after close")))
+	assert.Equal(t, int64(3), pool.Dropped())
+}
+
+func TestPoolCollect(t *testing.T) {
+	release := make(chan struct{})
+	pool := NewPool(1, 1, func(ctx context.Context, batch Batch) {
+		<-release
+	})
+	pool.Start(context.Background())
+	defer func() {
+		close(release)
+		pool.Close()
+	}()
+
+	pool.Submit(batchOf("a"))
+	require.Eventually(t, func() bool { return pool.QueueDepth() == 0 }, time.Second, time.Millisecond)
+	pool.Submit(batchOf("b"))
+	pool.Submit(batchOf("dropped-1"))
+	pool.Submit(batchOf("dropped-2"))
+
+	collected, err := pool.Collect(context.Background())
+	require.NoError(t, err)
+	require.Len(t, collected, 2)
+	assert.Equal(t, "SendQueueDepth", collected[0].ID)
+	assert.Equal(t, 1.0, *collected[0].Value)
+	assert.Equal(t, "DroppedBatches", collected[1].ID)
+	assert.Equal(t, int64(2), *collected[1].Delta)
+
+	// Приращение отброшенных считается с прошлого опроса
+	collected, err = pool.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), *collected[1].Delta)
+}
+
+func TestPoolStopsOnContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := NewPool(2, 1, func(ctx context.Context, batch Batch) {})
+	pool.Start(ctx)
+
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		pool.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("воркеры должны завершиться после отмены контекста")
+	}
+}
+
+func TestNewBatchIDsAreUnique(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		batch := NewBatch(nil)
+		assert.Len(t, batch.ID, 32)
+		assert.False(t, seen[batch.ID])
+		seen[batch.ID] = true
+	}
+}
@@ -0,0 +1,302 @@
+package spool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/GarikMirzoyan/metricalert/internal/agent/sender"
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+)
+
+// Имя коллектора метрик буфера
+const CollectorName = "spool"
+
+const fileExt = ".json"
+
+var ErrBatchTooLarge = errors.New("batch exceeds spool size limit")
+
+type entry struct {
+	name      string
+	size      int64
+	createdAt time.Time
+}
+
+// Очередь неотправленных батчей на диске.
+// Каждый батч хранится в отдельном файле с возрастающим номером, поэтому порядок
+// сохраняется и после перезапуска агента. При превышении лимитов по размеру или
+// возрасту самые старые батчи удаляются.
+type Spool struct {
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+	now      func() time.Time
+
+	mu      sync.Mutex
+	entries []entry
+	size    int64
+	nextSeq uint64
+
+	// Переотправка выполняется одним вызывающим за раз, чтобы не нарушать порядок
+	replayMu sync.Mutex
+
+	evicted         atomic.Int64
+	reportedEvicted atomic.Int64
+}
+
+// Открытие буфера в каталоге dir; уже лежащие там батчи подхватываются по порядку.
+// maxBytes и maxAge со значением 0 отключают соответствующий лимит.
+func Open(dir string, maxBytes int64, maxAge time.Duration) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("не удалось создать каталог буфера: %w", err)
+	}
+
+	s := &Spool{
+		dir:      dir,
+		maxBytes: maxBytes,
+		maxAge:   maxAge,
+		now:      time.Now,
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Spool) load() error {
+	files, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать каталог буфера: %w", err)
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		path := filepath.Join(s.dir, name)
+
+		// Недописанные временные файлы остаются после аварийного завершения
+		if strings.HasSuffix(name, ".tmp") {
+			os.Remove(path)
+			continue
+		}
+		if file.IsDir() || !strings.HasSuffix(name, fileExt) {
+			continue
+		}
+
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, fileExt), 10, 64)
+		if err != nil {
+			continue
+		}
+
+		batch, size, err := readBatch(path)
+		if err != nil {
+			log.Printf("повреждённый файл буфера %s удалён: %v", name, err)
+			os.Remove(path)
+			continue
+		}
+
+		s.entries = append(s.entries, entry{name: name, size: size, createdAt: batch.CreatedAt})
+		s.size += size
+		if seq >= s.nextSeq {
+			s.nextSeq = seq + 1
+		}
+	}
+
+	sort.Slice(s.entries, func(i, j int) bool { return s.entries[i].name < s.entries[j].name })
+	return nil
+}
+
+// Сохранение батча в конец очереди
+func (s *Spool) Push(batch sender.Batch) error {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("ошибка маршалинга батча: %w", err)
+	}
+	size := int64(len(data))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxBytes > 0 && size > s.maxBytes {
+		s.evicted.Add(1)
+		return fmt.Errorf("%w: %d > %d", ErrBatchTooLarge, size, s.maxBytes)
+	}
+
+	s.expireLocked()
+	for s.maxBytes > 0 && s.size+size > s.maxBytes && len(s.entries) > 0 {
+		s.evictOldestLocked()
+	}
+
+	name := fmt.Sprintf("%020d%s", s.nextSeq, fileExt)
+	if err := writeFileAtomic(filepath.Join(s.dir, name), data); err != nil {
+		return fmt.Errorf("ошибка записи батча в буфер: %w", err)
+	}
+	s.nextSeq++
+
+	s.entries = append(s.entries, entry{name: name, size: size, createdAt: batch.CreatedAt})
+	s.size += size
+	return nil
+}
+
+// Отправка батчей из буфера по порядку, начиная с самого старого.
+// Батч удаляется только после успешной отправки; при ошибке переотправка
+// останавливается, и батч остаётся первым в очереди.
+func (s *Spool) Replay(ctx context.Context, send func(ctx context.Context, batch sender.Batch) error) error {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		s.expireLocked()
+		if len(s.entries) == 0 {
+			s.mu.Unlock()
+			return nil
+		}
+		head := s.entries[0]
+		s.mu.Unlock()
+
+		batch, _, err := readBatch(filepath.Join(s.dir, head.name))
+		if err != nil {
+			log.Printf("не удалось прочитать батч %s из буфера, он удалён: %v", head.name, err)
+			s.remove(head.name)
+			continue
+		}
+
+		if err := send(ctx, batch); err != nil {
+			return err
+		}
+		s.remove(head.name)
+	}
+}
+
+// Число батчей в буфере
+func (s *Spool) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Суммарный размер батчей в буфере в байтах
+func (s *Spool) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// Число батчей, удалённых из-за лимитов
+func (s *Spool) Evicted() int64 {
+	return s.evicted.Load()
+}
+
+func (s *Spool) Name() string {
+	return CollectorName
+}
+
+// Метрики буфера: число и размер батчей, приращение вытесненных с прошлого опроса
+func (s *Spool) Collect(ctx context.Context) ([]dto.Metrics, error) {
+	s.mu.Lock()
+	batches := float64(len(s.entries))
+	size := float64(s.size)
+	s.mu.Unlock()
+
+	evicted := s.evicted.Load()
+	delta := evicted - s.reportedEvicted.Swap(evicted)
+
+	return []dto.Metrics{
+		{ID: "SpoolBatches", MType: string(constants.GaugeName), Value: &batches},
+		{ID: "SpoolBytes", MType: string(constants.GaugeName), Value: &size},
+		{ID: "SpoolEvictedBatches", MType: string(constants.CounterName), Delta: &delta},
+	}, nil
+}
+
+func (s *Spool) remove(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, e := range s.entries {
+		if e.name == name {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			s.size -= e.size
+			break
+		}
+	}
+	if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("не удалось удалить файл буфера %s: %v", name, err)
+	}
+}
+
+func (s *Spool) expireLocked() {
+	if s.maxAge <= 0 {
+		return
+	}
+	deadline := s.now().Add(-s.maxAge)
+	for len(s.entries) > 0 && s.entries[0].createdAt.Before(deadline) {
+		s.evictOldestLocked()
+	}
+}
+
+func (s *Spool) evictOldestLocked() {
+	oldest := s.entries[0]
+	s.entries = s.entries[1:]
+	s.size -= oldest.size
+	s.evicted.Add(1)
+
+	if err := os.Remove(filepath.Join(s.dir, oldest.name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Printf("не удалось удалить файл буфера %s: %v", oldest.name, err)
+	}
+	log.Printf("батч %s удалён из буфера из-за лимитов", oldest.name)
+}
+
+func readBatch(path string) (sender.Batch, int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return sender.Batch{}, 0, err
+	}
+
+	var batch sender.Batch
+	if err := json.Unmarshal(data, &batch); err != nil {
+		return sender.Batch{}, 0, err
+	}
+	return batch, int64(len(data)), nil
+}
+
+// Запись во временный файл с fsync и последующим переименованием,
+// чтобы после сбоя в буфере не оказалось недописанного батча
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
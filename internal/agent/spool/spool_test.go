@@ -0,0 +1,194 @@
+package spool
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/GarikMirzoyan/metricalert/internal/agent/sender"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func counterBatch(id string, delta int64) sender.Batch {
+	return sender.NewBatch([]dto.Metrics{{ID: id, MType: "counter", Delta: &delta}})
+}
+
+func collectReplay(t *testing.T, s *Spool) []sender.Batch {
+	t.Helper()
+
+	var sent []sender.Batch
+	err := s.Replay(context.Background(), func(ctx context.Context, batch sender.Batch) error {
+		sent = append(sent, batch)
+		return nil
+	})
+	require.NoError(t, err)
+	return sent
+}
+
+func TestPushAndReplayInOrder(t *testing.T) {
+	s, err := Open(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+
+	batches := []sender.Batch{counterBatch("a", 1), counterBatch("b", 2), counterBatch("c", 3)}
+	for _, b := range batches {
+		require.NoError(t, s.Push(b))
+	}
+	assert.Equal(t, 3, s.Len())
+
+	sent := collectReplay(t, s)
+	require.Len(t, sent, 3)
+	for i := range batches {
+		assert.Equal(t, batches[i].ID, sent[i].ID)
+		assert.Equal(t, *batches[i].Metrics[0].Delta, *sent[i].Metrics[0].Delta)
+	}
+	assert.Equal(t, 0, s.Len())
+	assert.Equal(t, int64(0), s.Size())
+
+	// Повторная переотправка ничего не отправляет: каждый батч уходит ровно один раз
+	assert.Empty(t, collectReplay(t, s))
+}
+
+func TestReplayStopsOnErrorAndKeepsBatch(t *testing.T) {
+	s, err := Open(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+
+	first, second := counterBatch("a", 1), counterBatch("b", 2)
+	require.NoError(t, s.Push(first))
+	require.NoError(t, s.Push(second))
+
+	var attempts []string
+	sendErr := errors.New("server down")
+	err = s.Replay(context.Background(), func(ctx context.Context, batch sender.Batch) error {
+		attempts = append(attempts, batch.ID)
+		return sendErr
+	})
+	assert.ErrorIs(t, err, sendErr)
+	assert.Equal(t, []string{first.ID}, attempts)
+	assert.Equal(t, 2, s.Len())
+
+	sent := collectReplay(t, s)
+	require.Len(t, sent, 2)
+	assert.Equal(t, first.ID, sent[0].ID)
+	assert.Equal(t, second.ID, sent[1].ID)
+}
+
+func TestReopenKeepsOrder(t *testing.T) {
+	dir := t.TempDir()
+
+	s, err := Open(dir, 0, 0)
+	require.NoError(t, err)
+	var ids []string
+	for i := 0; i < 12; i++ {
+		b := counterBatch("m", int64(i))
+		ids = append(ids, b.ID)
+		require.NoError(t, s.Push(b))
+	}
+
+	// Недописанный временный файл и повреждённый батч после сбоя
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000099.json.tmp"), []byte("{"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "00000000000000000050.json"), []byte("not json"), 0o644))
+
+	reopened, err := Open(dir, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 12, reopened.Len())
+
+	// Новые батчи после перезапуска идут за сохранёнными
+	last := counterBatch("m", 100)
+	require.NoError(t, reopened.Push(last))
+	ids = append(ids, last.ID)
+
+	sent := collectReplay(t, reopened)
+	var sentIDs []string
+	for _, b := range sent {
+		sentIDs = append(sentIDs, b.ID)
+	}
+	assert.Equal(t, ids, sentIDs)
+
+	files, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestSizeLimitEvictsOldest(t *testing.T) {
+	probe := counterBatch("m", 1)
+	s, err := Open(t.TempDir(), 0, 0)
+	require.NoError(t, err)
+	require.NoError(t, s.Push(probe))
+	batchSize := s.Size()
+
+	s, err = Open(t.TempDir(), batchSize*2, 0)
+	require.NoError(t, err)
+
+	b1, b2, b3 := counterBatch("m", 1), counterBatch("m", 2), counterBatch("m", 3)
+	require.NoError(t, s.Push(b1))
+	require.NoError(t, s.Push(b2))
+	require.NoError(t, s.Push(b3))
+
+	assert.Equal(t, 2, s.Len())
+	assert.Equal(t, int64(1), s.Evicted())
+
+	sent := collectReplay(t, s)
+	require.Len(t, sent, 2)
+	assert.Equal(t, b2.ID, sent[0].ID)
+	assert.Equal(t, b3.ID, sent[1].ID)
+}
+
+func TestBatchTooLarge(t *testing.T) {
+	s, err := Open(t.TempDir(), 10, 0)
+	require.NoError(t, err)
+
+	err = s.Push(counterBatch("m", 1))
+	assert.ErrorIs(t, err, ErrBatchTooLarge)
+	assert.Equal(t, 0, s.Len())
+	assert.Equal(t, int64(1), s.Evicted())
+}
+
+func TestAgeLimitEvictsExpired(t *testing.T) {
+	s, err := Open(t.TempDir(), 0, time.Minute)
+	require.NoError(t, err)
+
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	old := counterBatch("m", 1)
+	old.CreatedAt = now.Add(-2 * time.Minute)
+	fresh := counterBatch("m", 2)
+	fresh.CreatedAt = now
+
+	require.NoError(t, s.Push(old))
+	require.NoError(t, s.Push(fresh))
+
+	sent := collectReplay(t, s)
+	require.Len(t, sent, 1)
+	assert.Equal(t, fresh.ID, sent[0].ID)
+	assert.Equal(t, int64(1), s.Evicted())
+}
+
+func TestCollect(t *testing.T) {
+	s, err := Open(t.TempDir(), 0, time.Minute)
+	require.NoError(t, err)
+
+	expired := counterBatch("m", 1)
+	expired.CreatedAt = time.Now().Add(-time.Hour)
+	require.NoError(t, s.Push(expired))
+	require.NoError(t, s.Push(counterBatch("m", 2)))
+	require.NoError(t, s.Push(counterBatch("m", 3)))
+
+	collected, err := s.Collect(context.Background())
+	require.NoError(t, err)
+	require.Len(t, collected, 3)
+	assert.Equal(t, "SpoolBatches", collected[0].ID)
+	assert.Equal(t, 2.0, *collected[0].Value)
+	assert.Equal(t, float64(s.Size()), *collected[1].Value)
+	assert.Equal(t, "SpoolEvictedBatches", collected[2].ID)
+	assert.Equal(t, int64(1), *collected[2].Delta)
+
+	collected, err = s.Collect(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), *collected[2].Delta)
+}
@@ -0,0 +1,220 @@
+package alerts
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/metrics"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+	"go.uber.org/zap"
+)
+
+type State string
+
+const (
+	StatePending  State = "pending"
+	StateFiring   State = "firing"
+	StateResolved State = "resolved"
+)
+
+// Состояние алерта по одному правилу
+type Alert struct {
+	Rule        string     `json:"rule"`
+	Expr        string     `json:"expr"`
+	MetricID    string     `json:"metric_id"`
+	MetricType  string     `json:"metric_type"`
+	Value       float64    `json:"value"`
+	State       State      `json:"state"`
+	ActiveSince time.Time  `json:"active_since"`
+	FiredAt     *time.Time `json:"fired_at,omitempty"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+}
+
+// Событие смены состояния алерта
+type Event struct {
+	Alert
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Получатель событий смены состояния алертов
+type Notifier interface {
+	Notify(event Event)
+}
+
+// Движок периодически вычисляет правила по данным из MetricStorage
+type Engine struct {
+	storage  metrics.MetricStorage
+	rules    []Rule
+	interval time.Duration
+	notifier Notifier
+	logger   *zap.Logger
+	now      func() time.Time
+
+	mu     sync.Mutex
+	alerts map[string]*Alert
+}
+
+// notifier может быть nil, тогда события никуда не отправляются
+func NewEngine(storage metrics.MetricStorage, rules []Rule, interval time.Duration, notifier Notifier, logger *zap.Logger) *Engine {
+	return &Engine{
+		storage:  storage,
+		rules:    rules,
+		interval: interval,
+		notifier: notifier,
+		logger:   logger,
+		now:      time.Now,
+		alerts:   make(map[string]*Alert),
+	}
+}
+
+func (e *Engine) Rules() []Rule {
+	rules := make([]Rule, len(e.rules))
+	copy(rules, e.rules)
+	return rules
+}
+
+// Периодическое вычисление правил до отмены контекста
+func (e *Engine) Start(ctx context.Context) {
+	if len(e.rules) == 0 || e.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	e.logger.Info("запущено вычисление правил алертинга",
+		zap.Int("rules", len(e.rules)),
+		zap.Duration("interval", e.interval),
+	)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.Evaluate(ctx); err != nil {
+				e.logger.Error("ошибка при вычислении правил алертинга", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Однократное вычисление всех правил
+func (e *Engine) Evaluate(ctx context.Context) error {
+	gauges, counters, err := e.storage.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := e.now()
+	events := e.evaluateRules(now, gauges, counters)
+
+	if e.notifier != nil {
+		for _, event := range events {
+			e.notifier.Notify(event)
+		}
+	}
+
+	return nil
+}
+
+func (e *Engine) evaluateRules(now time.Time, gauges map[string]models.GaugeMetric, counters map[string]models.CounterMetric) []Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var events []Event
+	changed := func(alert *Alert) {
+		events = append(events, Event{Alert: *alert, Timestamp: now})
+	}
+
+	for _, rule := range e.rules {
+		value, found := lookupValue(rule, gauges, counters)
+		alert, tracked := e.alerts[rule.Name]
+
+		if found && rule.Matches(value) {
+			started := !tracked || alert.State == StateResolved
+			if started {
+				alert = &Alert{
+					Rule:        rule.Name,
+					Expr:        rule.Expr,
+					MetricID:    rule.MetricID,
+					MetricType:  string(rule.MetricType),
+					State:       StatePending,
+					ActiveSince: now,
+				}
+				e.alerts[rule.Name] = alert
+			}
+			alert.Value = value
+
+			if alert.State == StatePending && now.Sub(alert.ActiveSince) >= rule.For {
+				firedAt := now
+				alert.State = StateFiring
+				alert.FiredAt = &firedAt
+				changed(alert)
+			} else if started {
+				changed(alert)
+			}
+			continue
+		}
+
+		if !tracked {
+			continue
+		}
+		if found {
+			alert.Value = value
+		}
+
+		switch alert.State {
+		case StatePending:
+			delete(e.alerts, rule.Name)
+		case StateFiring:
+			resolvedAt := now
+			alert.State = StateResolved
+			alert.ResolvedAt = &resolvedAt
+			changed(alert)
+		}
+	}
+
+	return events
+}
+
+// Все отслеживаемые алерты, включая разрешённые
+func (e *Engine) Alerts() []Alert {
+	return e.collect(func(a *Alert) bool { return true })
+}
+
+// Алерты в состоянии pending или firing
+func (e *Engine) ActiveAlerts() []Alert {
+	return e.collect(func(a *Alert) bool { return a.State != StateResolved })
+}
+
+func (e *Engine) collect(filter func(a *Alert) bool) []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	result := make([]Alert, 0, len(e.alerts))
+	for _, alert := range e.alerts {
+		if filter(alert) {
+			result = append(result, *alert)
+		}
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Rule < result[j].Rule })
+	return result
+}
+
+func lookupValue(rule Rule, gauges map[string]models.GaugeMetric, counters map[string]models.CounterMetric) (float64, bool) {
+	switch rule.MetricType {
+	case constants.GaugeName:
+		metric, ok := gauges[rule.MetricID]
+		return metric.Value, ok
+	case constants.CounterName:
+		metric, ok := counters[rule.MetricID]
+		return float64(metric.Value), ok
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,187 @@
+package alerts
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/database/fakedb"
+	"github.com/GarikMirzoyan/metricalert/internal/metrics"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+	"github.com/GarikMirzoyan/metricalert/internal/repositories"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// Движок должен вести себя одинаково на всех реализациях хранилища
+var storages = []struct {
+	name       string
+	newStorage func(t *testing.T) metrics.MetricStorage
+}{
+	{name: "mem", newStorage: func(t *testing.T) metrics.MetricStorage {
+		return metrics.NewMemStorage()
+	}},
+	{name: "db", newStorage: func(t *testing.T) metrics.MetricStorage {
+		db := fakedb.New()
+		t.Cleanup(db.Close)
+		return metrics.NewDBStorage(repositories.NewMetricRepository(db))
+	}},
+}
+
+var engineTests = []struct {
+	name string
+	run  func(t *testing.T, storage metrics.MetricStorage)
+}{
+	{name: "pending firing resolved", run: testPendingFiringResolved},
+	{name: "pending resets when condition clears", run: testPendingResetsWhenConditionClears},
+	{name: "counter fires immediately without for", run: testCounterFiresImmediatelyWithoutFor},
+	{name: "notifies on state changes", run: testNotifiesOnStateChanges},
+	{name: "labeled series", run: testLabeledSeries},
+}
+
+func TestEngine(t *testing.T) {
+	for _, backend := range storages {
+		t.Run(backend.name, func(t *testing.T) {
+			for _, tc := range engineTests {
+				t.Run(tc.name, func(t *testing.T) {
+					tc.run(t, backend.newStorage(t))
+				})
+			}
+		})
+	}
+}
+
+func newTestEngine(t *testing.T, storage metrics.MetricStorage, exprs map[string]string) (*Engine, *time.Time) {
+	var rules []Rule
+	for name, expr := range exprs {
+		rule, err := ParseRule(name, expr)
+		require.NoError(t, err)
+		rules = append(rules, rule)
+	}
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	engine := NewEngine(storage, rules, time.Second, nil, zap.NewNop())
+	engine.now = func() time.Time { return now }
+
+	return engine, &now
+}
+
+func setGauge(t *testing.T, storage metrics.MetricStorage, name string, value float64) {
+	err := storage.UpdateGauge(&models.GaugeMetric{Name: name, Type: constants.GaugeName, Value: value}, context.Background())
+	require.NoError(t, err)
+}
+
+func testPendingFiringResolved(t *testing.T, storage metrics.MetricStorage) {
+	engine, now := newTestEngine(t, storage, map[string]string{"HighHeap": "gauge HeapAlloc > 100 for 2m"})
+	ctx := context.Background()
+
+	setGauge(t, storage, "HeapAlloc", 50)
+	require.NoError(t, engine.Evaluate(ctx))
+	assert.Empty(t, engine.Alerts())
+
+	setGauge(t, storage, "HeapAlloc", 150)
+	require.NoError(t, engine.Evaluate(ctx))
+	active := engine.ActiveAlerts()
+	require.Len(t, active, 1)
+	assert.Equal(t, StatePending, active[0].State)
+	assert.Equal(t, 150.0, active[0].Value)
+
+	*now = now.Add(time.Minute)
+	require.NoError(t, engine.Evaluate(ctx))
+	assert.Equal(t, StatePending, engine.ActiveAlerts()[0].State)
+
+	*now = now.Add(time.Minute)
+	require.NoError(t, engine.Evaluate(ctx))
+	active = engine.ActiveAlerts()
+	require.Len(t, active, 1)
+	assert.Equal(t, StateFiring, active[0].State)
+	require.NotNil(t, active[0].FiredAt)
+
+	setGauge(t, storage, "HeapAlloc", 10)
+	*now = now.Add(time.Minute)
+	require.NoError(t, engine.Evaluate(ctx))
+	assert.Empty(t, engine.ActiveAlerts())
+	all := engine.Alerts()
+	require.Len(t, all, 1)
+	assert.Equal(t, StateResolved, all[0].State)
+	require.NotNil(t, all[0].ResolvedAt)
+}
+
+func testPendingResetsWhenConditionClears(t *testing.T, storage metrics.MetricStorage) {
+	engine, now := newTestEngine(t, storage, map[string]string{"HighHeap": "gauge HeapAlloc > 100 for 2m"})
+	ctx := context.Background()
+
+	setGauge(t, storage, "HeapAlloc", 150)
+	require.NoError(t, engine.Evaluate(ctx))
+	require.Len(t, engine.ActiveAlerts(), 1)
+
+	setGauge(t, storage, "HeapAlloc", 50)
+	*now = now.Add(time.Minute)
+	require.NoError(t, engine.Evaluate(ctx))
+	assert.Empty(t, engine.Alerts())
+}
+
+func testCounterFiresImmediatelyWithoutFor(t *testing.T, storage metrics.MetricStorage) {
+	engine, _ := newTestEngine(t, storage, map[string]string{"Polls": "counter PollCount >= 5"})
+	ctx := context.Background()
+
+	err := storage.UpdateCounter(&models.CounterMetric{Name: "PollCount", Type: constants.CounterName, Value: 5}, ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, engine.Evaluate(ctx))
+	active := engine.ActiveAlerts()
+	require.Len(t, active, 1)
+	assert.Equal(t, StateFiring, active[0].State)
+	assert.Equal(t, 5.0, active[0].Value)
+}
+
+type recordingNotifier struct {
+	events []Event
+}
+
+func (n *recordingNotifier) Notify(event Event) {
+	n.events = append(n.events, event)
+}
+
+func testNotifiesOnStateChanges(t *testing.T, storage metrics.MetricStorage) {
+	engine, now := newTestEngine(t, storage, map[string]string{"HighHeap": "gauge HeapAlloc > 100 for 1m"})
+	notifier := &recordingNotifier{}
+	engine.notifier = notifier
+	ctx := context.Background()
+
+	setGauge(t, storage, "HeapAlloc", 150)
+	require.NoError(t, engine.Evaluate(ctx))
+	require.NoError(t, engine.Evaluate(ctx))
+
+	*now = now.Add(time.Minute)
+	require.NoError(t, engine.Evaluate(ctx))
+	require.NoError(t, engine.Evaluate(ctx))
+
+	setGauge(t, storage, "HeapAlloc", 10)
+	require.NoError(t, engine.Evaluate(ctx))
+
+	require.Len(t, notifier.events, 3)
+	assert.Equal(t, StatePending, notifier.events[0].State)
+	assert.Equal(t, StateFiring, notifier.events[1].State)
+	assert.Equal(t, StateResolved, notifier.events[2].State)
+	assert.Equal(t, *now, notifier.events[2].Timestamp)
+}
+
+func testLabeledSeries(t *testing.T, storage metrics.MetricStorage) {
+	engine, _ := newTestEngine(t, storage, map[string]string{"HostHeap": `gauge HeapAlloc{host="web-1"} > 100`})
+	ctx := context.Background()
+
+	for host, value := range map[string]float64{"web-1": 150, "web-2": 10} {
+		err := storage.UpdateGauge(&models.GaugeMetric{Name: "HeapAlloc", Type: constants.GaugeName, Labels: models.Labels{"host": host}, Value: value}, ctx)
+		require.NoError(t, err)
+	}
+	setGauge(t, storage, "HeapAlloc", 10)
+
+	require.NoError(t, engine.Evaluate(ctx))
+	active := engine.ActiveAlerts()
+	require.Len(t, active, 1)
+	assert.Equal(t, StateFiring, active[0].State)
+	assert.Equal(t, 150.0, active[0].Value)
+}
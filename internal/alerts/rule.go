@@ -0,0 +1,156 @@
+package alerts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+)
+
+var ErrInvalidRule = errors.New("invalid alert rule")
+
+// Оператор сравнения значения метрики с порогом
+type Operator string
+
+const (
+	OpGreater      Operator = ">"
+	OpGreaterEqual Operator = ">="
+	OpLess         Operator = "<"
+	OpLessEqual    Operator = "<="
+	OpEqual        Operator = "=="
+	OpNotEqual     Operator = "!="
+)
+
+// Пороговое правило вида "gauge HeapAlloc > 5e8 for 2m".
+// MetricID — ключ серии (models.SeriesKey), метки в выражении не должны содержать пробелов.
+type Rule struct {
+	Name       string
+	Expr       string
+	MetricType constants.MetricType
+	MetricID   string
+	Op         Operator
+	Threshold  float64
+	For        time.Duration
+}
+
+// Описание правила в конфигурационном файле
+type RuleConfig struct {
+	Name string `json:"name"`
+	Expr string `json:"expr"`
+}
+
+type RulesFile struct {
+	Rules []RuleConfig `json:"rules"`
+}
+
+func ParseRule(name, expr string) (Rule, error) {
+	if name == "" {
+		return Rule{}, fmt.Errorf("%w: rule name is required", ErrInvalidRule)
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 4 && len(fields) != 6 {
+		return Rule{}, fmt.Errorf("%w %q: expected \"<type> <id> <op> <threshold> [for <duration>]\"", ErrInvalidRule, expr)
+	}
+
+	rule := Rule{
+		Name:       name,
+		Expr:       expr,
+		MetricType: constants.MetricType(fields[0]),
+		MetricID:   fields[1],
+		Op:         Operator(fields[2]),
+	}
+
+	switch rule.MetricType {
+	case constants.GaugeName, constants.CounterName:
+	default:
+		return Rule{}, fmt.Errorf("%w %q: unknown metric type %s", ErrInvalidRule, expr, fields[0])
+	}
+
+	switch rule.Op {
+	case OpGreater, OpGreaterEqual, OpLess, OpLessEqual, OpEqual, OpNotEqual:
+	default:
+		return Rule{}, fmt.Errorf("%w %q: unknown operator %s", ErrInvalidRule, expr, fields[2])
+	}
+
+	// Серия может быть указана с метками: HeapAlloc{host="web-1"}
+	metricName, labels, err := models.ParseSeriesKey(fields[1])
+	if err != nil {
+		return Rule{}, fmt.Errorf("%w %q: %v", ErrInvalidRule, expr, err)
+	}
+	rule.MetricID = models.SeriesKey(metricName, labels)
+
+	threshold, err := strconv.ParseFloat(fields[3], 64)
+	if err != nil {
+		return Rule{}, fmt.Errorf("%w %q: invalid threshold: %v", ErrInvalidRule, expr, err)
+	}
+	rule.Threshold = threshold
+
+	if len(fields) == 6 {
+		if fields[4] != "for" {
+			return Rule{}, fmt.Errorf("%w %q: expected \"for\", got %s", ErrInvalidRule, expr, fields[4])
+		}
+		duration, err := time.ParseDuration(fields[5])
+		if err != nil || duration < 0 {
+			return Rule{}, fmt.Errorf("%w %q: invalid duration %s", ErrInvalidRule, expr, fields[5])
+		}
+		rule.For = duration
+	}
+
+	return rule, nil
+}
+
+// Проверка, выполняется ли условие правила для значения
+func (r Rule) Matches(value float64) bool {
+	switch r.Op {
+	case OpGreater:
+		return value > r.Threshold
+	case OpGreaterEqual:
+		return value >= r.Threshold
+	case OpLess:
+		return value < r.Threshold
+	case OpLessEqual:
+		return value <= r.Threshold
+	case OpEqual:
+		return value == r.Threshold
+	case OpNotEqual:
+		return value != r.Threshold
+	default:
+		return false
+	}
+}
+
+// Загрузка правил из JSON-файла
+func LoadRules(path string) ([]Rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл правил: %w", err)
+	}
+
+	var file RulesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("ошибка при декодировании файла правил: %w", err)
+	}
+
+	rules := make([]Rule, 0, len(file.Rules))
+	names := make(map[string]struct{}, len(file.Rules))
+	for _, rc := range file.Rules {
+		rule, err := ParseRule(rc.Name, rc.Expr)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := names[rule.Name]; exists {
+			return nil, fmt.Errorf("%w: duplicate rule name %s", ErrInvalidRule, rule.Name)
+		}
+		names[rule.Name] = struct{}{}
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,71 @@
+package alerts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRule(t *testing.T) {
+	rule, err := ParseRule("HighHeap", "gauge HeapAlloc > 5e8 for 2m")
+	require.NoError(t, err)
+
+	assert.Equal(t, constants.GaugeName, rule.MetricType)
+	assert.Equal(t, "HeapAlloc", rule.MetricID)
+	assert.Equal(t, OpGreater, rule.Op)
+	assert.Equal(t, 5e8, rule.Threshold)
+	assert.Equal(t, 2*time.Minute, rule.For)
+
+	rule, err = ParseRule("Polls", "counter PollCount >= 10")
+	require.NoError(t, err)
+	assert.Equal(t, time.Duration(0), rule.For)
+
+	rule, err = ParseRule("HostHeap", `gauge HeapAlloc{region="eu",host="web-1"} > 1`)
+	require.NoError(t, err)
+	assert.Equal(t, `HeapAlloc{host="web-1",region="eu"}`, rule.MetricID)
+}
+
+func TestParseRule_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+	}{
+		{name: "empty", expr: ""},
+		{name: "unknown type", expr: "histogram Latency > 1"},
+		{name: "unknown operator", expr: "gauge Alloc => 1"},
+		{name: "bad threshold", expr: "gauge Alloc > abc"},
+		{name: "missing for keyword", expr: "gauge Alloc > 1 during 2m"},
+		{name: "bad duration", expr: "gauge Alloc > 1 for soon"},
+		{name: "bad labels", expr: "gauge Alloc{host} > 1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRule("rule", tt.expr)
+			assert.ErrorIs(t, err, ErrInvalidRule)
+		})
+	}
+}
+
+func TestLoadRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	content := `{"rules":[{"name":"HighHeap","expr":"gauge HeapAlloc > 5e8 for 2m"},{"name":"Polls","expr":"counter PollCount > 100"}]}`
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+
+	rules, err := LoadRules(path)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	assert.Equal(t, "HighHeap", rules[0].Name)
+	assert.Equal(t, "Polls", rules[1].Name)
+
+	duplicate := `{"rules":[{"name":"A","expr":"gauge X > 1"},{"name":"A","expr":"gauge Y > 1"}]}`
+	require.NoError(t, os.WriteFile(path, []byte(duplicate), 0o644))
+
+	_, err = LoadRules(path)
+	assert.ErrorIs(t, err, ErrInvalidRule)
+}
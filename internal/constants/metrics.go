@@ -3,6 +3,10 @@ package constants
 type MetricType string
 
 const (
-	GaugeName   MetricType = "gauge"
-	CounterName MetricType = "counter"
+	GaugeName     MetricType = "gauge"
+	CounterName   MetricType = "counter"
+	HistogramName MetricType = "histogram"
+	SummaryName   MetricType = "summary"
+	SetName       MetricType = "set"
+	InfoName      MetricType = "info"
 )
@@ -37,9 +37,13 @@ func getProjectRoot() string {
 }
 
 func (db *DB) RunMigrations() error {
+	return runMigrations(db.Conn)
+}
+
+func runMigrations(conn *sql.DB) error {
 	goose.SetDialect("postgres")
 	migrationsPath := filepath.Join(getProjectRoot(), "migrations")
-	return goose.Up(db.Conn, migrationsPath)
+	return goose.Up(conn, migrationsPath)
 }
 
 // Проверка соединения
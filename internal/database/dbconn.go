@@ -5,6 +5,20 @@ import (
 	"database/sql"
 )
 
+// Проверка доступности базы; реализуется и DB, и Pool
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// Подключение к базе, общее для database/sql (DB) и pgxpool (Pool)
+type Database interface {
+	Pinger
+
+	RunMigrations() error
+
+	Close()
+}
+
 type DBConn interface {
 	Ping(ctx context.Context) error
 
@@ -0,0 +1,630 @@
+package fakedb
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/database"
+)
+
+// Хранилище в памяти вместо PostgreSQL для тестов DBStorage и MetricRepository.
+// Драйвер понимает только запросы репозитория метрик (repositories/queries.go)
+// и повторяет их семантику: upsert по (type, name, labels), состояние составных
+// метрик, история, выборки.
+
+var ErrUnsupportedQuery = errors.New("fakedb: unsupported query")
+
+// Новая пустая база; у каждого вызова своё состояние
+func New() *database.DB {
+	return &database.DB{Conn: sql.OpenDB(&connector{store: &store{}})}
+}
+
+type metricRow struct {
+	name   string
+	typ    string
+	labels string
+	value  *float64
+	delta  *int64
+	state  []byte
+	// Нулевое — NULL в колонке observed_at
+	observedAt time.Time
+}
+
+type historyRow struct {
+	id         int64
+	name       string
+	typ        string
+	labels     string
+	value      float64
+	recordedAt time.Time
+}
+
+type store struct {
+	// Удерживается транзакцией от Begin до Commit/Rollback,
+	// запросом вне транзакции — на время выполнения
+	mu            sync.Mutex
+	metrics       []metricRow
+	history       []historyRow
+	nextHistoryID int64
+	// Идентификаторы применённых батчей и время применения (metric_batches)
+	batches map[string]time.Time
+}
+
+func (s *store) snapshot() *store {
+	return &store{
+		metrics:       append([]metricRow(nil), s.metrics...),
+		history:       append([]historyRow(nil), s.history...),
+		nextHistoryID: s.nextHistoryID,
+		batches:       maps.Clone(s.batches),
+	}
+}
+
+func (s *store) restore(from *store) {
+	s.metrics = from.metrics
+	s.history = from.history
+	s.nextHistoryID = from.nextHistoryID
+	s.batches = from.batches
+}
+
+type connector struct {
+	store *store
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return &conn{store: c.store}, nil
+}
+
+func (c *connector) Driver() driver.Driver {
+	return fakeDriver{}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	return nil, errors.New("fakedb: use fakedb.New")
+}
+
+type conn struct {
+	store *store
+	tx    *tx
+}
+
+func (c *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *conn) Close() error {
+	if c.tx != nil {
+		return c.tx.Rollback()
+	}
+	return nil
+}
+
+func (c *conn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	c.store.mu.Lock()
+	c.tx = &tx{conn: c, saved: c.store.snapshot()}
+	return c.tx, nil
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if _, err := c.run(query, args); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.run(query, args)
+}
+
+func (c *conn) run(query string, args []driver.NamedValue) (*rows, error) {
+	if c.tx == nil {
+		c.store.mu.Lock()
+		defer c.store.mu.Unlock()
+	}
+
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return c.store.execute(strings.Join(strings.Fields(query), " "), values)
+}
+
+type tx struct {
+	conn  *conn
+	saved *store
+}
+
+func (t *tx) Commit() error {
+	t.conn.tx = nil
+	t.conn.store.mu.Unlock()
+	return nil
+}
+
+func (t *tx) Rollback() error {
+	t.conn.store.restore(t.saved)
+	t.conn.tx = nil
+	t.conn.store.mu.Unlock()
+	return nil
+}
+
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 }
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.conn.ExecContext(context.Background(), s.query, namedValues(args))
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.conn.QueryContext(context.Background(), s.query, namedValues(args))
+}
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *rows) Columns() []string { return r.columns }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// Выполнение запроса; вызывается под store.mu
+func (s *store) execute(query string, args []driver.Value) (*rows, error) {
+	switch {
+	case strings.HasPrefix(query, "INSERT INTO metric_batches"):
+		return s.insertBatch(args)
+	case strings.HasPrefix(query, "DELETE FROM metric_batches"):
+		return s.deleteExpiredBatches(args)
+	case strings.HasPrefix(query, "INSERT INTO metrics (name, type, labels, value, delta, observed_at)"):
+		return s.upsertMetric(args)
+	case strings.HasPrefix(query, "INSERT INTO metrics (name, type, labels) VALUES"):
+		return s.lockState(args)
+	case strings.HasPrefix(query, "UPDATE metrics SET state"):
+		return s.updateState(args)
+	case strings.HasPrefix(query, "SELECT state FROM metrics WHERE"):
+		return s.selectState(args)
+	case strings.HasPrefix(query, "SELECT name, labels, state FROM metrics WHERE"):
+		return s.selectStates(args)
+	case strings.HasPrefix(query, "INSERT INTO metrics_history"):
+		return s.insertHistory(args)
+	case strings.HasPrefix(query, "DELETE FROM metrics_history"):
+		return s.deleteHistoryBefore(args)
+	case strings.HasPrefix(query, "SELECT value, observed_at FROM metrics WHERE"):
+		return s.selectStoredGauge(args)
+	case strings.HasPrefix(query, "SELECT value FROM metrics WHERE"):
+		return s.selectValue("gauge", "value", args)
+	case strings.HasPrefix(query, "SELECT delta FROM metrics WHERE"):
+		return s.selectValue("counter", "delta", args)
+	case strings.HasPrefix(query, "SELECT name, type, labels, value, delta, observed_at FROM metrics"):
+		return s.selectAll()
+	case strings.HasPrefix(query, "SELECT recorded_at, value FROM ("):
+		return s.selectHistory(args)
+	case strings.HasPrefix(query, "TRUNCATE"):
+		s.restore(&store{})
+		return &rows{}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedQuery, query)
+	}
+}
+
+// INSERT ... ON CONFLICT (type, name, labels): gauge заменяется, counter суммируется.
+// Gauge старше сохранённого не применяется, и строк не возвращается.
+func (s *store) upsertMetric(args []driver.Value) (*rows, error) {
+	if len(args) != 6 {
+		return nil, fmt.Errorf("fakedb: upsert expects 6 arguments, got %d", len(args))
+	}
+	name, typ := asString(args[0]), asString(args[1])
+	labels, err := normalizeLabels(args[2])
+	if err != nil {
+		return nil, err
+	}
+	value, err := asFloat(args[3])
+	if err != nil {
+		return nil, err
+	}
+	delta, err := asInt(args[4])
+	if err != nil {
+		return nil, err
+	}
+	observedAt, ok := args[5].(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("fakedb: invalid observation time %v", args[5])
+	}
+
+	row := s.findMetric(typ, name, labels)
+	if row == nil {
+		s.metrics = append(s.metrics, metricRow{name: name, typ: typ, labels: labels})
+		row = &s.metrics[len(s.metrics)-1]
+		row.value, row.delta, row.observedAt = value, delta, observedAt
+	} else {
+		if row.typ != "counter" && !row.observedAt.IsZero() && observedAt.Before(row.observedAt) {
+			return &rows{columns: []string{"value", "delta", "observed_at"}}, nil
+		}
+		if observedAt.After(row.observedAt) {
+			row.observedAt = observedAt
+		}
+		row.value = value
+		if delta == nil {
+			row.delta = nil
+		} else {
+			total := *delta
+			if row.delta != nil {
+				total += *row.delta
+			}
+			row.delta = &total
+		}
+	}
+
+	return &rows{
+		columns: []string{"value", "delta", "observed_at"},
+		values:  [][]driver.Value{{row.valueOf("value"), row.valueOf("delta"), row.observedAtValue()}},
+	}, nil
+}
+
+// INSERT ... ON CONFLICT DO UPDATE ... RETURNING state: строка создаётся при отсутствии.
+// Блокировка не нужна — транзакция и так удерживает всё хранилище.
+func (s *store) lockState(args []driver.Value) (*rows, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("fakedb: state lock expects 3 arguments, got %d", len(args))
+	}
+	name, typ := asString(args[0]), asString(args[1])
+	labels, err := normalizeLabels(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	row := s.findMetric(typ, name, labels)
+	if row == nil {
+		s.metrics = append(s.metrics, metricRow{name: name, typ: typ, labels: labels})
+		row = &s.metrics[len(s.metrics)-1]
+	}
+	return &rows{columns: []string{"state"}, values: [][]driver.Value{{row.stateValue()}}}, nil
+}
+
+func (s *store) updateState(args []driver.Value) (*rows, error) {
+	if len(args) != 4 {
+		return nil, fmt.Errorf("fakedb: state update expects 4 arguments, got %d", len(args))
+	}
+	labels, err := normalizeLabels(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	if row := s.findMetric(asString(args[1]), asString(args[0]), labels); row != nil {
+		row.state = []byte(asString(args[3]))
+	}
+	return &rows{}, nil
+}
+
+func (s *store) selectState(args []driver.Value) (*rows, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("fakedb: state select expects 3 arguments, got %d", len(args))
+	}
+	labels, err := normalizeLabels(args[2])
+	if err != nil {
+		return nil, err
+	}
+
+	result := &rows{columns: []string{"state"}}
+	if row := s.findMetric(asString(args[0]), asString(args[1]), labels); row != nil && row.state != nil {
+		result.values = append(result.values, []driver.Value{row.stateValue()})
+	}
+	return result, nil
+}
+
+func (s *store) selectStates(args []driver.Value) (*rows, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("fakedb: states select expects 1 argument, got %d", len(args))
+	}
+
+	result := &rows{columns: []string{"name", "labels", "state"}}
+	for _, row := range s.metrics {
+		if row.typ == asString(args[0]) && row.state != nil {
+			result.values = append(result.values, []driver.Value{row.name, []byte(row.labels), row.stateValue()})
+		}
+	}
+	return result, nil
+}
+
+func (s *store) insertHistory(args []driver.Value) (*rows, error) {
+	if len(args) != 5 {
+		return nil, fmt.Errorf("fakedb: history insert expects 5 arguments, got %d", len(args))
+	}
+	labels, err := normalizeLabels(args[2])
+	if err != nil {
+		return nil, err
+	}
+	value, err := asFloat(args[3])
+	if err != nil || value == nil {
+		return nil, fmt.Errorf("fakedb: invalid history value %v", args[3])
+	}
+	recordedAt, ok := args[4].(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("fakedb: invalid history time %v", args[4])
+	}
+
+	s.nextHistoryID++
+	s.history = append(s.history, historyRow{
+		id:         s.nextHistoryID,
+		name:       asString(args[0]),
+		typ:        asString(args[1]),
+		labels:     labels,
+		value:      *value,
+		recordedAt: recordedAt,
+	})
+	return &rows{}, nil
+}
+
+// Вставка с ON CONFLICT DO NOTHING RETURNING id: для существующего идентификатора строк нет
+func (s *store) insertBatch(args []driver.Value) (*rows, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("fakedb: batch insert expects 2 arguments, got %d", len(args))
+	}
+	appliedAt, ok := args[1].(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("fakedb: invalid batch time %v", args[1])
+	}
+
+	id := asString(args[0])
+	result := &rows{columns: []string{"id"}}
+	if _, exists := s.batches[id]; exists {
+		return result, nil
+	}
+	if s.batches == nil {
+		s.batches = make(map[string]time.Time)
+	}
+	s.batches[id] = appliedAt
+	result.values = append(result.values, []driver.Value{id})
+	return result, nil
+}
+
+func (s *store) deleteExpiredBatches(args []driver.Value) (*rows, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("fakedb: batch delete expects 1 argument, got %d", len(args))
+	}
+	before, ok := args[0].(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("fakedb: invalid batch time %v", args[0])
+	}
+
+	for id, appliedAt := range s.batches {
+		if appliedAt.Before(before) {
+			delete(s.batches, id)
+		}
+	}
+	return &rows{}, nil
+}
+
+func (s *store) deleteHistoryBefore(args []driver.Value) (*rows, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("fakedb: history delete expects 1 argument, got %d", len(args))
+	}
+	before, ok := args[0].(time.Time)
+	if !ok {
+		return nil, fmt.Errorf("fakedb: invalid history time %v", args[0])
+	}
+
+	kept := s.history[:0]
+	for _, row := range s.history {
+		if !row.recordedAt.Before(before) {
+			kept = append(kept, row)
+		}
+	}
+	s.history = kept
+	return &rows{}, nil
+}
+
+func (s *store) selectValue(typ, column string, args []driver.Value) (*rows, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("fakedb: select expects 2 arguments, got %d", len(args))
+	}
+	labels, err := normalizeLabels(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	result := &rows{columns: []string{column}}
+	if row := s.findMetric(typ, asString(args[0]), labels); row != nil {
+		result.values = append(result.values, []driver.Value{row.valueOf(column)})
+	}
+	return result, nil
+}
+
+func (s *store) selectStoredGauge(args []driver.Value) (*rows, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("fakedb: select expects 2 arguments, got %d", len(args))
+	}
+	labels, err := normalizeLabels(args[1])
+	if err != nil {
+		return nil, err
+	}
+
+	result := &rows{columns: []string{"value", "observed_at"}}
+	if row := s.findMetric("gauge", asString(args[0]), labels); row != nil {
+		result.values = append(result.values, []driver.Value{row.valueOf("value"), row.observedAtValue()})
+	}
+	return result, nil
+}
+
+func (s *store) selectAll() (*rows, error) {
+	result := &rows{columns: []string{"name", "type", "labels", "value", "delta", "observed_at"}}
+	for _, row := range s.metrics {
+		result.values = append(result.values, []driver.Value{
+			row.name, row.typ, []byte(row.labels), row.valueOf("value"), row.valueOf("delta"), row.observedAtValue(),
+		})
+	}
+	return result, nil
+}
+
+func (s *store) selectHistory(args []driver.Value) (*rows, error) {
+	if len(args) != 5 {
+		return nil, fmt.Errorf("fakedb: history select expects 5 arguments, got %d", len(args))
+	}
+	labels, err := normalizeLabels(args[2])
+	if err != nil {
+		return nil, err
+	}
+	from, okFrom := args[3].(time.Time)
+	to, okTo := args[4].(time.Time)
+	if !okFrom || !okTo {
+		return nil, fmt.Errorf("fakedb: invalid history range %v - %v", args[3], args[4])
+	}
+
+	var matched []historyRow
+	for _, row := range s.history {
+		if row.typ == asString(args[0]) && row.name == asString(args[1]) && row.labels == labels &&
+			!row.recordedAt.Before(from) {
+			matched = append(matched, row)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		if !matched[i].recordedAt.Equal(matched[j].recordedAt) {
+			return matched[i].recordedAt.Before(matched[j].recordedAt)
+		}
+		return matched[i].id < matched[j].id
+	})
+
+	// У counter в истории приращения: накопленное значение — итог за вычетом более поздних
+	if asString(args[0]) == "counter" {
+		var total float64
+		if row := s.findMetric("counter", asString(args[1]), labels); row != nil && row.delta != nil {
+			total = float64(*row.delta)
+		}
+		for i := len(matched) - 1; i >= 0; i-- {
+			delta := matched[i].value
+			matched[i].value = total
+			total -= delta
+		}
+	}
+
+	result := &rows{columns: []string{"recorded_at", "value"}}
+	for _, row := range matched {
+		if !row.recordedAt.After(to) {
+			result.values = append(result.values, []driver.Value{row.recordedAt, row.value})
+		}
+	}
+	return result, nil
+}
+
+func (s *store) findMetric(typ, name, labels string) *metricRow {
+	for i := range s.metrics {
+		row := &s.metrics[i]
+		if row.typ == typ && row.name == name && row.labels == labels {
+			return row
+		}
+	}
+	return nil
+}
+
+func (r metricRow) valueOf(column string) driver.Value {
+	switch {
+	case column == "value" && r.value != nil:
+		return *r.value
+	case column == "delta" && r.delta != nil:
+		return *r.delta
+	default:
+		return nil
+	}
+}
+
+func (r metricRow) observedAtValue() driver.Value {
+	if r.observedAt.IsZero() {
+		return nil
+	}
+	return r.observedAt
+}
+
+// Копия, чтобы вызывающий не изменил состояние через возвращённый срез
+func (r metricRow) stateValue() driver.Value {
+	if r.state == nil {
+		return nil
+	}
+	return append([]byte(nil), r.state...)
+}
+
+func asString(v driver.Value) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+func asFloat(v driver.Value) (*float64, error) {
+	switch f := v.(type) {
+	case nil:
+		return nil, nil
+	case float64:
+		return &f, nil
+	case int64:
+		value := float64(f)
+		return &value, nil
+	default:
+		return nil, fmt.Errorf("fakedb: expected double precision, got %T", v)
+	}
+}
+
+func asInt(v driver.Value) (*int64, error) {
+	switch i := v.(type) {
+	case nil:
+		return nil, nil
+	case int64:
+		return &i, nil
+	default:
+		return nil, fmt.Errorf("fakedb: expected bigint, got %T", v)
+	}
+}
+
+// Как jsonb: порядок ключей и пробелы не влияют на сравнение
+func normalizeLabels(v driver.Value) (string, error) {
+	var labels map[string]string
+	if err := json.Unmarshal([]byte(asString(v)), &labels); err != nil {
+		return "", fmt.Errorf("fakedb: invalid labels: %w", err)
+	}
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
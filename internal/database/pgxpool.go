@@ -0,0 +1,50 @@
+package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// Пул подключений pgx без database/sql: нужен для COPY при пакетной загрузке
+type Pool struct {
+	Pool *pgxpool.Pool
+}
+
+// Функция для создания пула подключений к базе
+func NewPool(connString string) (*Pool, error) {
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, connString)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create connection pool: %v", err)
+	}
+
+	// Проверим, что соединение рабочее
+	if err = pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("unable to ping database: %v", err)
+	}
+
+	return &Pool{Pool: pool}, nil
+}
+
+// Миграции goose выполняются через database/sql поверх того же пула
+func (p *Pool) RunMigrations() error {
+	conn := stdlib.OpenDBFromPool(p.Pool)
+	defer conn.Close()
+
+	return runMigrations(conn)
+}
+
+// Проверка соединения
+func (p *Pool) Ping(ctx context.Context) error {
+	return p.Pool.Ping(ctx)
+}
+
+// Закрытие пула
+func (p *Pool) Close() {
+	p.Pool.Close()
+}
@@ -0,0 +1,184 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Заголовок, которым агент помечает зашифрованное тело запроса
+const (
+	HeaderName  = "X-Encryption"
+	HeaderValue = "rsa"
+)
+
+// Первый байт зашифрованных данных определяет схему
+const (
+	modeRSA    byte = 1 // тело целиком зашифровано RSA-OAEP
+	modeHybrid byte = 2 // RSA-OAEP ключ AES-256 + AES-GCM тело
+)
+
+const aesKeySize = 32
+
+var ErrInvalidCiphertext = errors.New("invalid ciphertext")
+
+// Загрузка открытого ключа из PEM (PKIX или PKCS#1)
+func LoadPublicKey(path string) (*rsa.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка разбора открытого ключа: %w", err)
+		}
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("открытый ключ не является RSA-ключом")
+		}
+		return rsaKey, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("неподдерживаемый тип PEM-блока: %s", block.Type)
+	}
+}
+
+// Загрузка закрытого ключа из PEM (PKCS#1 или PKCS#8)
+func LoadPrivateKey(path string) (*rsa.PrivateKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка разбора закрытого ключа: %w", err)
+		}
+		rsaKey, ok := key.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("закрытый ключ не является RSA-ключом")
+		}
+		return rsaKey, nil
+	default:
+		return nil, fmt.Errorf("неподдерживаемый тип PEM-блока: %s", block.Type)
+	}
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл ключа: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("файл %s не содержит PEM-блока", path)
+	}
+	return block, nil
+}
+
+// Максимальный размер данных, которые можно зашифровать RSA-OAEP напрямую
+func maxOAEPSize(key *rsa.PublicKey) int {
+	return key.Size() - 2*sha256.Size - 2
+}
+
+// Шифрование данных открытым ключом. Небольшие данные шифруются RSA-OAEP напрямую,
+// для больших используется гибридная схема: случайный ключ AES-256 шифруется RSA-OAEP,
+// а данные — AES-GCM.
+func Encrypt(key *rsa.PublicKey, data []byte) ([]byte, error) {
+	if len(data) <= maxOAEPSize(key) {
+		encrypted, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, key, data, nil)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{modeRSA}, encrypted...), nil
+	}
+
+	aesKey := make([]byte, aesKeySize)
+	if _, err := rand.Read(aesKey); err != nil {
+		return nil, err
+	}
+
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, key, aesKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(aesKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	// Формат: mode | RSA(aesKey) | nonce | AES-GCM(data)
+	result := make([]byte, 0, 1+len(encryptedKey)+len(nonce)+len(data)+gcm.Overhead())
+	result = append(result, modeHybrid)
+	result = append(result, encryptedKey...)
+	result = append(result, nonce...)
+	return gcm.Seal(result, nonce, data, nil), nil
+}
+
+// Расшифровка данных, полученных из Encrypt
+func Decrypt(key *rsa.PrivateKey, data []byte) ([]byte, error) {
+	if len(data) < 1 {
+		return nil, ErrInvalidCiphertext
+	}
+
+	mode, payload := data[0], data[1:]
+	switch mode {
+	case modeRSA:
+		return rsa.DecryptOAEP(sha256.New(), rand.Reader, key, payload, nil)
+
+	case modeHybrid:
+		keySize := key.Size()
+		if len(payload) < keySize {
+			return nil, ErrInvalidCiphertext
+		}
+
+		aesKey, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, key, payload[:keySize], nil)
+		if err != nil {
+			return nil, err
+		}
+
+		gcm, err := newGCM(aesKey)
+		if err != nil {
+			return nil, err
+		}
+
+		rest := payload[keySize:]
+		if len(rest) < gcm.NonceSize() {
+			return nil, ErrInvalidCiphertext
+		}
+		return gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], nil)
+
+	default:
+		return nil, fmt.Errorf("%w: unknown mode %d", ErrInvalidCiphertext, mode)
+	}
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,120 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	return key
+}
+
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	path := filepath.Join(dir, name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestEncryptDecrypt_RoundTrip(t *testing.T) {
+	key := generateKey(t)
+
+	large := make([]byte, 64*1024)
+	_, err := rand.Read(large)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		data []byte
+		mode byte
+	}{
+		{name: "empty", data: []byte{}, mode: modeRSA},
+		{name: "small", data: []byte(`[{"id":"Alloc","type":"gauge","value":1}]`), mode: modeRSA},
+		{name: "max rsa", data: bytes.Repeat([]byte("a"), maxOAEPSize(&key.PublicKey)), mode: modeRSA},
+		{name: "hybrid", data: bytes.Repeat([]byte("a"), maxOAEPSize(&key.PublicKey)+1), mode: modeHybrid},
+		{name: "large", data: large, mode: modeHybrid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encrypted, err := Encrypt(&key.PublicKey, tt.data)
+			require.NoError(t, err)
+			assert.Equal(t, tt.mode, encrypted[0])
+
+			decrypted, err := Decrypt(key, encrypted)
+			require.NoError(t, err)
+			assert.Equal(t, tt.data, append([]byte{}, decrypted...))
+		})
+	}
+}
+
+func TestDecrypt_Invalid(t *testing.T) {
+	key := generateKey(t)
+
+	encrypted, err := Encrypt(&key.PublicKey, bytes.Repeat([]byte("a"), 1024))
+	require.NoError(t, err)
+
+	tampered := append([]byte{}, encrypted...)
+	tampered[len(tampered)-1] ^= 0xff
+	_, err = Decrypt(key, tampered)
+	assert.Error(t, err)
+
+	_, err = Decrypt(generateKey(t), encrypted)
+	assert.Error(t, err)
+
+	_, err = Decrypt(key, nil)
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+
+	_, err = Decrypt(key, []byte{modeHybrid, 1, 2, 3})
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+
+	_, err = Decrypt(key, []byte{42})
+	assert.ErrorIs(t, err, ErrInvalidCiphertext)
+}
+
+func TestLoadKeys(t *testing.T) {
+	key := generateKey(t)
+	dir := t.TempDir()
+
+	pkixDER, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	pkcs8DER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	publicPaths := []string{
+		writePEM(t, dir, "public.pem", "PUBLIC KEY", pkixDER),
+		writePEM(t, dir, "public_pkcs1.pem", "RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&key.PublicKey)),
+	}
+	for _, path := range publicPaths {
+		publicKey, err := LoadPublicKey(path)
+		require.NoError(t, err, path)
+		assert.True(t, key.PublicKey.Equal(publicKey), path)
+	}
+
+	privatePaths := []string{
+		writePEM(t, dir, "private.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)),
+		writePEM(t, dir, "private_pkcs8.pem", "PRIVATE KEY", pkcs8DER),
+	}
+	for _, path := range privatePaths {
+		privateKey, err := LoadPrivateKey(path)
+		require.NoError(t, err, path)
+		assert.True(t, key.Equal(privateKey), path)
+	}
+
+	_, err = LoadPublicKey(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+
+	_, err = LoadPrivateKey(publicPaths[0])
+	assert.Error(t, err)
+}
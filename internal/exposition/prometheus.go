@@ -0,0 +1,227 @@
+package exposition
+
+import (
+	"bufio"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+)
+
+// Content-Type текстового формата Prometheus
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Приведение имени метрики к виду [a-zA-Z_:][a-zA-Z0-9_:]*
+func SanitizeName(name string) string {
+	if name == "" {
+		return "_"
+	}
+
+	var b strings.Builder
+	b.Grow(len(name) + 1)
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':':
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Метки в виде {host="a",region="b"}; имена меток проверяются при приёме метрик
+func formatLabels(labels models.Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range sortedKeys(labels) {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(name)
+		b.WriteString(`="`)
+		b.WriteString(labelValueEscaper.Replace(labels[name]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Метки с дополнительной меткой: le для корзины гистограммы, quantile для summary
+func withLabel(labels, name, value string) string {
+	label := name + `="` + value + `"`
+	if labels == "" {
+		return "{" + label + "}"
+	}
+	return strings.TrimSuffix(labels, "}") + "," + label + "}"
+}
+
+// Квантили summary в выводе
+var summaryQuantiles = []float64{0.5, 0.9, 0.95, 0.99}
+
+// Метрики для вывода; ключи карт — ключи серий (models.SeriesKey)
+type Metrics struct {
+	Gauges     map[string]models.GaugeMetric
+	Counters   map[string]models.CounterMetric
+	Histograms map[string]models.HistogramMetric
+	Summaries  map[string]models.SummaryMetric
+	Sets       map[string]models.SetMetric
+}
+
+type series struct {
+	labels string
+	// Строки серии без имени семейства: метки и значение, для гистограммы ещё суффикс
+	lines []string
+}
+
+// Семейство метрик с одним именем и типом
+type family struct {
+	metricType string
+	series     []series
+	// Число отброшенных серий других типов с тем же именем
+	skipped map[string]int
+}
+
+// Запись всех метрик в текстовом формате Prometheus.
+// Семейства упорядочены по имени, серии внутри семейства — по меткам.
+// Counter выводится с суффиксом _total, поэтому не пересекается с одноимённым gauge.
+// Если после приведения имени метрики разных типов всё же совпадают, выводится только
+// первая в порядке gauge, counter, histogram, summary, set, чтобы не нарушать формат,
+// а об отброшенных сериях пишется комментарий после семейства.
+// Гистограмма выводится как name_bucket{le="..."} с накопительными счётчиками,
+// name_sum и name_count, summary — как name{quantile="..."}, name_sum и name_count,
+// set — как gauge с оценкой числа элементов.
+func WritePrometheus(w io.Writer, metrics Metrics) error {
+	families := make(map[string]*family)
+
+	add := func(name string, labels models.Labels, metricType string, lines func(labels string) []string) {
+		name = SanitizeName(name)
+		f, exists := families[name]
+		if !exists {
+			f = &family{metricType: metricType}
+			families[name] = f
+		}
+		if f.metricType != metricType {
+			if f.skipped == nil {
+				f.skipped = make(map[string]int)
+			}
+			f.skipped[metricType]++
+			return
+		}
+		formatted := formatLabels(labels)
+		f.series = append(f.series, series{labels: formatted, lines: lines(formatted)})
+	}
+
+	for _, key := range sortedKeys(metrics.Gauges) {
+		gauge := metrics.Gauges[key]
+		add(gauge.Name, gauge.Labels, "gauge", func(labels string) []string {
+			return []string{labels + " " + formatValue(gauge.Value)}
+		})
+	}
+	for _, key := range sortedKeys(metrics.Counters) {
+		counter := metrics.Counters[key]
+		add(counterName(counter.Name), counter.Labels, "counter", func(labels string) []string {
+			return []string{labels + " " + strconv.FormatInt(counter.Value, 10)}
+		})
+	}
+	for _, key := range sortedKeys(metrics.Histograms) {
+		histogram := metrics.Histograms[key]
+		add(histogram.Name, histogram.Labels, "histogram", func(labels string) []string {
+			lines := make([]string, 0, len(histogram.Counts)+2)
+			for i, count := range histogram.Cumulative() {
+				bound := "+Inf"
+				if i < len(histogram.Buckets) {
+					bound = formatValue(histogram.Buckets[i])
+				}
+				lines = append(lines, "_bucket"+withLabel(labels, "le", bound)+" "+strconv.FormatUint(count, 10))
+			}
+			return append(lines,
+				"_sum"+labels+" "+formatValue(histogram.Sum),
+				"_count"+labels+" "+strconv.FormatUint(histogram.Count, 10),
+			)
+		})
+	}
+	for _, key := range sortedKeys(metrics.Summaries) {
+		summary := metrics.Summaries[key]
+		add(summary.Name, summary.Labels, "summary", func(labels string) []string {
+			lines := make([]string, 0, len(summaryQuantiles)+2)
+			for _, q := range summaryQuantiles {
+				value, err := summary.Sketch.Quantile(q)
+				if err != nil {
+					continue
+				}
+				lines = append(lines, withLabel(labels, "quantile", formatValue(q))+" "+formatValue(value))
+			}
+			return append(lines,
+				"_sum"+labels+" "+formatValue(summary.Sketch.Sum()),
+				"_count"+labels+" "+strconv.FormatUint(summary.Sketch.Count(), 10),
+			)
+		})
+	}
+	for _, key := range sortedKeys(metrics.Sets) {
+		set := metrics.Sets[key]
+		add(set.Name, set.Labels, "gauge", func(labels string) []string {
+			return []string{labels + " " + strconv.FormatUint(set.HLL.Estimate(), 10)}
+		})
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, name := range sortedKeys(families) {
+		f := families[name]
+		sort.SliceStable(f.series, func(i, j int) bool { return f.series[i].labels < f.series[j].labels })
+
+		bw.WriteString("# TYPE " + name + " " + f.metricType + "\n")
+		seen := make(map[string]struct{}, len(f.series))
+		for _, s := range f.series {
+			// Разные исходные имена могут совпасть после приведения
+			if _, duplicate := seen[s.labels]; duplicate {
+				continue
+			}
+			seen[s.labels] = struct{}{}
+			for _, line := range s.lines {
+				bw.WriteString(name + line + "\n")
+			}
+		}
+		for _, metricType := range sortedKeys(f.skipped) {
+			bw.WriteString("# " + name + ": skipped " + strconv.Itoa(f.skipped[metricType]) + " " + metricType +
+				" series, the name is used by " + f.metricType + "\n")
+		}
+	}
+
+	return bw.Flush()
+}
+
+// Имя counter по соглашению Prometheus оканчивается на _total
+func counterName(name string) string {
+	name = SanitizeName(name)
+	if strings.HasSuffix(name, "_total") {
+		return name
+	}
+	return name + "_total"
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,151 @@
+package exposition
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+	"github.com/GarikMirzoyan/metricalert/internal/sketch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := map[string]string{
+		"HeapAlloc":       "HeapAlloc",
+		"http.requests":   "http_requests",
+		"1st-metric":      "_1st_metric",
+		"ns:sub_metric":   "ns:sub_metric",
+		"метрика":         "_______",
+		"":                "_",
+		"cpu utilization": "cpu_utilization",
+	}
+
+	for in, expected := range tests {
+		assert.Equal(t, expected, SanitizeName(in), in)
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	gauges := map[string]models.GaugeMetric{
+		"HeapAlloc": {Name: "HeapAlloc", Type: constants.GaugeName, Value: 1.5e8},
+		"Ratio":     {Name: "Ratio", Type: constants.GaugeName, Value: math.Inf(1)},
+		`Load{host="b"}`: {
+			Name: "Load", Type: constants.GaugeName, Labels: models.Labels{"host": "b"}, Value: 2,
+		},
+		`Load{host="a \"x\""}`: {
+			Name: "Load", Type: constants.GaugeName, Labels: models.Labels{"host": `a "x"`}, Value: 1,
+		},
+	}
+	counters := map[string]models.CounterMetric{
+		"Poll.Count": {Name: "Poll.Count", Type: constants.CounterName, Value: 42},
+		"HeapAlloc":  {Name: "HeapAlloc", Type: constants.CounterName, Value: 1},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WritePrometheus(&buf, Metrics{Gauges: gauges, Counters: counters}))
+
+	expected := "# TYPE HeapAlloc gauge\n" +
+		"HeapAlloc 1.5e+08\n" +
+		"# TYPE HeapAlloc_total counter\n" +
+		"HeapAlloc_total 1\n" +
+		"# TYPE Load gauge\n" +
+		"Load{host=\"a \\\"x\\\"\"} 1\n" +
+		"Load{host=\"b\"} 2\n" +
+		"# TYPE Poll_Count_total counter\n" +
+		"Poll_Count_total 42\n" +
+		"# TYPE Ratio gauge\n" +
+		"Ratio +Inf\n"
+	assert.Equal(t, expected, buf.String())
+}
+
+// Counter с уже указанным суффиксом не получает второй; совпадение имён разных типов
+// не ломает вывод, а отмечается комментарием
+func TestWritePrometheusNameCollisions(t *testing.T) {
+	gauges := map[string]models.GaugeMetric{
+		"Requests_total": {Name: "Requests_total", Type: constants.GaugeName, Value: 3},
+	}
+	counters := map[string]models.CounterMetric{
+		"Requests":        {Name: "Requests", Type: constants.CounterName, Value: 5},
+		"Errors_total":    {Name: "Errors_total", Type: constants.CounterName, Value: 1},
+		`Requests{a="b"}`: {Name: "Requests", Type: constants.CounterName, Labels: models.Labels{"a": "b"}, Value: 2},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WritePrometheus(&buf, Metrics{Gauges: gauges, Counters: counters}))
+
+	expected := "# TYPE Errors_total counter\n" +
+		"Errors_total 1\n" +
+		"# TYPE Requests_total gauge\n" +
+		"Requests_total 3\n" +
+		"# Requests_total: skipped 2 counter series, the name is used by gauge\n"
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestWritePrometheusHistogram(t *testing.T) {
+	histograms := map[string]models.HistogramMetric{
+		`Latency{path="/a"}`: {
+			Name:    "Latency",
+			Type:    constants.HistogramName,
+			Labels:  models.Labels{"path": "/a"},
+			Buckets: []float64{0.1, 0.5},
+			Counts:  []uint64{2, 1, 1},
+			Sum:     1.25,
+			Count:   4,
+		},
+		"Size": {
+			Name:    "Size",
+			Type:    constants.HistogramName,
+			Buckets: []float64{1024},
+			Counts:  []uint64{0, 3},
+			Sum:     9000,
+			Count:   3,
+		},
+	}
+	gauges := map[string]models.GaugeMetric{
+		"Size": {Name: "Size", Type: constants.GaugeName, Value: 1},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WritePrometheus(&buf, Metrics{Gauges: gauges, Histograms: histograms}))
+
+	expected := "# TYPE Latency histogram\n" +
+		"Latency_bucket{path=\"/a\",le=\"0.1\"} 2\n" +
+		"Latency_bucket{path=\"/a\",le=\"0.5\"} 3\n" +
+		"Latency_bucket{path=\"/a\",le=\"+Inf\"} 4\n" +
+		"Latency_sum{path=\"/a\"} 1.25\n" +
+		"Latency_count{path=\"/a\"} 4\n" +
+		"# TYPE Size gauge\n" +
+		"Size 1\n" +
+		"# Size: skipped 1 histogram series, the name is used by gauge\n"
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestWritePrometheusSummary(t *testing.T) {
+	s := sketch.NewDefault()
+	for range 3 {
+		require.NoError(t, s.Add(2))
+	}
+	summaries := map[string]models.SummaryMetric{
+		`Duration{host="a"}`: {
+			Name:   "Duration",
+			Type:   constants.SummaryName,
+			Labels: models.Labels{"host": "a"},
+			Sketch: s,
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WritePrometheus(&buf, Metrics{Summaries: summaries}))
+
+	expected := "# TYPE Duration summary\n" +
+		"Duration{host=\"a\",quantile=\"0.5\"} 2\n" +
+		"Duration{host=\"a\",quantile=\"0.9\"} 2\n" +
+		"Duration{host=\"a\",quantile=\"0.95\"} 2\n" +
+		"Duration{host=\"a\",quantile=\"0.99\"} 2\n" +
+		"Duration_sum{host=\"a\"} 6\n" +
+		"Duration_count{host=\"a\"} 3\n"
+	assert.Equal(t, expected, buf.String())
+}
@@ -0,0 +1,125 @@
+package grpcclient
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"time"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/GarikMirzoyan/metricalert/internal/encryption"
+	"github.com/GarikMirzoyan/metricalert/internal/grpcserver"
+	"github.com/GarikMirzoyan/metricalert/internal/hash"
+	pb "github.com/GarikMirzoyan/metricalert/internal/proto"
+	"github.com/GarikMirzoyan/metricalert/internal/retry"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Таймаут одного вызова UpdateMetrics
+const defaultTimeout = 10 * time.Second
+
+// Клиент агента для отправки батчей метрик по gRPC
+type Client struct {
+	conn    *grpc.ClientConn
+	client  pb.MetricsClient
+	key     string
+	delays  []time.Duration
+	timeout time.Duration
+}
+
+// Соединение устанавливается лениво, при первом вызове.
+// С ключом key запросы подписываются HMAC-SHA256, с открытым ключом publicKey — шифруются,
+// как и тела HTTP-запросов агента.
+func NewClient(address, key string, publicKey *rsa.PublicKey, opts ...grpc.DialOption) (*Client, error) {
+	defaults := []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	if publicKey != nil {
+		defaults = append(defaults, grpc.WithDefaultCallOptions(grpc.ForceCodec(encryptingCodec{key: publicKey})))
+	}
+	opts = append(defaults, opts...)
+
+	conn, err := grpc.NewClient(address, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать gRPC-клиент: %w", err)
+	}
+
+	return &Client{
+		conn:    conn,
+		client:  pb.NewMetricsClient(conn),
+		key:     key,
+		delays:  retry.DefaultDelays,
+		timeout: defaultTimeout,
+	}, nil
+}
+
+// Отправка батча с повторами при временной недоступности сервера
+func (c *Client) SendBatch(ctx context.Context, batchID string, batch []dto.Metrics) error {
+	req := &pb.UpdateMetricsRequest{BatchId: batchID, Metrics: make([]*pb.Metric, 0, len(batch))}
+	for _, metric := range batch {
+		req.Metrics = append(req.Metrics, pb.FromDTO(metric))
+	}
+
+	if c.key != "" {
+		payload, err := grpcserver.SignedPayload(req)
+		if err != nil {
+			return fmt.Errorf("ошибка сериализации батча: %w", err)
+		}
+		ctx = metadata.AppendToOutgoingContext(ctx, grpcserver.SignatureMetadataKey, hash.Sign(payload, c.key))
+	}
+
+	return retry.WithBackoffDelays(ctx, c.delays, func() error {
+		callCtx, cancel := context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+
+		_, err := c.client.UpdateMetrics(callCtx, req)
+		if err == nil {
+			return nil
+		}
+
+		switch status.Code(err) {
+		case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+			return fmt.Errorf("server error: %v: %w", err, retry.ErrRetriable)
+		default:
+			return fmt.Errorf("non-retriable error: %w", err)
+		}
+	})
+}
+
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Кодек клиента при заданном открытом ключе: запросы шифруются encryption.Encrypt,
+// ответы сервера приходят открытыми
+type encryptingCodec struct {
+	key *rsa.PublicKey
+}
+
+func (c encryptingCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+
+	data, err := proto.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return encryption.Encrypt(c.key, data)
+}
+
+func (c encryptingCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+func (c encryptingCodec) Name() string {
+	return "proto"
+}
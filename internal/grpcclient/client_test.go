@@ -0,0 +1,123 @@
+package grpcclient
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net"
+	"testing"
+	"time"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/GarikMirzoyan/metricalert/internal/grpcserver"
+	"github.com/GarikMirzoyan/metricalert/internal/metrics"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+	"github.com/GarikMirzoyan/metricalert/internal/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newBufconnClient(t *testing.T, storage metrics.MetricStorage) *Client {
+	return newSecuredClient(t, storage, "", nil, "", nil)
+}
+
+// Клиент и сервер со своими ключами, чтобы проверять и несовпадающие настройки
+func newSecuredClient(t *testing.T, storage metrics.MetricStorage, serverKey string, privateKey *rsa.PrivateKey, clientKey string, publicKey *rsa.PublicKey) *Client {
+	t.Helper()
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpcserver.NewServer(storage, metrics.NewDefaultParser(), serverKey, privateKey, zap.NewNop())
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	client, err := NewClient("passthrough:///bufnet", clientKey, publicKey, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return listener.DialContext(ctx)
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() { client.Close() })
+
+	return client
+}
+
+func TestSendBatch(t *testing.T) {
+	storage := metrics.NewMemStorage()
+	client := newBufconnClient(t, storage)
+
+	value := 42.5
+	delta := int64(7)
+	batch := []dto.Metrics{
+		{ID: "HeapAlloc", MType: "gauge", Value: &value},
+		{ID: "PollCount", MType: "counter", Delta: &delta},
+		{ID: "PollCount", MType: "counter", Delta: &delta, Labels: map[string]string{"host": "web-1"}},
+	}
+
+	require.NoError(t, client.SendBatch(context.Background(), "", batch))
+
+	gauge, err := storage.GetGauge("HeapAlloc", nil, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 42.5, gauge.Value)
+
+	counter, err := storage.GetCounter("PollCount", nil, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), counter.Value)
+
+	labeled, err := storage.GetCounter("PollCount", models.Labels{"host": "web-1"}, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), labeled.Value)
+}
+
+func TestSendBatchInvalidMetricIsNotRetried(t *testing.T) {
+	client := newBufconnClient(t, metrics.NewMemStorage())
+	client.delays = []time.Duration{time.Hour, time.Hour}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.SendBatch(context.Background(), "", []dto.Metrics{{ID: "", MType: "gauge"}})
+	}()
+
+	select {
+	case err := <-done:
+		assert.Error(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("невалидный батч не должен повторяться")
+	}
+}
+
+func TestSendBatchSignedAndEncrypted(t *testing.T) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	value := 42.5
+	batch := []dto.Metrics{{ID: "HeapAlloc", MType: "gauge", Value: &value}}
+
+	storage := metrics.NewMemStorage()
+	client := newSecuredClient(t, storage, "secret", privateKey, "secret", &privateKey.PublicKey)
+	require.NoError(t, client.SendBatch(context.Background(), "", batch))
+
+	gauge, err := storage.GetGauge("HeapAlloc", nil, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 42.5, gauge.Value)
+
+	tests := []struct {
+		name      string
+		clientKey string
+		publicKey *rsa.PublicKey
+	}{
+		{name: "без подписи", publicKey: &privateKey.PublicKey},
+		{name: "чужой ключ подписи", clientKey: "other", publicKey: &privateKey.PublicKey},
+		{name: "без шифрования", clientKey: "secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newSecuredClient(t, metrics.NewMemStorage(), "secret", privateKey, tt.clientKey, tt.publicKey)
+			client.delays = []time.Duration{time.Hour}
+
+			err := client.SendBatch(context.Background(), "", batch)
+			require.Error(t, err)
+			assert.False(t, retry.IsRetriableError(err))
+		})
+	}
+}
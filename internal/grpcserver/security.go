@@ -0,0 +1,120 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/rsa"
+	"fmt"
+	"strings"
+
+	"github.com/GarikMirzoyan/metricalert/internal/encryption"
+	"github.com/GarikMirzoyan/metricalert/internal/hash"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// Ключ метаданных с подписью запроса; ключи метаданных gRPC всегда в нижнем регистре
+var SignatureMetadataKey = strings.ToLower(hash.HeaderName)
+
+// Данные, по которым считается подпись: детерминированная сериализация сообщения
+func SignedPayload(message proto.Message) ([]byte, error) {
+	return proto.MarshalOptions{Deterministic: true}.Marshal(message)
+}
+
+// Проверка подписи unary-запросов, как hashmiddleware для HTTP:
+// запрос без корректной подписи отклоняется, в том числе с пустым сообщением
+func signatureUnaryInterceptor(key string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if err := verifySignature(req, key, firstValue(md.Get(SignatureMetadataKey))); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// Проверка подписи сообщений потока. Метаданные передаются один раз при открытии потока,
+// поэтому клиент указывает подписи всех сообщений заранее, по значению на сообщение в порядке отправки.
+func signatureStreamInterceptor(key string) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		md, _ := metadata.FromIncomingContext(stream.Context())
+		return handler(srv, &signedStream{ServerStream: stream, key: key, signatures: md.Get(SignatureMetadataKey)})
+	}
+}
+
+type signedStream struct {
+	grpc.ServerStream
+	key        string
+	signatures []string
+	received   int
+}
+
+func (s *signedStream) RecvMsg(m any) error {
+	if err := s.ServerStream.RecvMsg(m); err != nil {
+		return err
+	}
+
+	signature := ""
+	if s.received < len(s.signatures) {
+		signature = s.signatures[s.received]
+	}
+	s.received++
+	return verifySignature(m, s.key, signature)
+}
+
+func verifySignature(message any, key, signature string) error {
+	m, ok := message.(proto.Message)
+	if !ok {
+		return status.Error(codes.Internal, "unexpected message type")
+	}
+
+	payload, err := SignedPayload(m)
+	if err != nil {
+		return status.Errorf(codes.Internal, "error marshalling request: %v", err)
+	}
+	if !hash.Verify(payload, key, signature) {
+		return status.Error(codes.Unauthenticated, "invalid request signature")
+	}
+	return nil
+}
+
+func firstValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// Кодек сервера при заданном закрытом ключе: запросы принимаются только зашифрованными
+// той же схемой, что и тела HTTP-запросов (encryption.Encrypt), ответы не шифруются
+type decryptingCodec struct {
+	key *rsa.PrivateKey
+}
+
+func (c decryptingCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("unexpected message type %T", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (c decryptingCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("unexpected message type %T", v)
+	}
+
+	decrypted, err := encryption.Decrypt(c.key, data)
+	if err != nil {
+		return fmt.Errorf("error decrypting request: %w", err)
+	}
+	return proto.Unmarshal(decrypted, m)
+}
+
+// Имя стандартного кодека, чтобы клиенту не требовался особый content-subtype
+func (c decryptingCodec) Name() string {
+	return "proto"
+}
@@ -0,0 +1,256 @@
+package grpcserver
+
+import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"io"
+	"sort"
+	"time"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/GarikMirzoyan/metricalert/internal/idempotency"
+	"github.com/GarikMirzoyan/metricalert/internal/metrics"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+	pb "github.com/GarikMirzoyan/metricalert/internal/proto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Реализация gRPC-сервиса поверх того же MetricStorage, что и HTTP-хендлеры
+type MetricsServer struct {
+	pb.UnimplementedMetricsServer
+	storage metrics.MetricStorage
+	parser  *metrics.Parser
+	batches *idempotency.Cache
+}
+
+func NewMetricsServer(storage metrics.MetricStorage, parser *metrics.Parser) *MetricsServer {
+	return &MetricsServer{storage: storage, parser: parser, batches: idempotency.NewCache(idempotency.DefaultCapacity)}
+}
+
+// gRPC-сервер с зарегистрированным сервисом метрик и логированием вызовов.
+// С ключом key запросы проверяются по подписи HMAC-SHA256 в метаданных,
+// с закрытым ключом privateKey принимаются только зашифрованные запросы — как и в HTTP.
+func NewServer(storage metrics.MetricStorage, parser *metrics.Parser, key string, privateKey *rsa.PrivateKey, logger *zap.Logger) *grpc.Server {
+	unary := []grpc.UnaryServerInterceptor{loggingUnaryInterceptor(logger)}
+	stream := []grpc.StreamServerInterceptor{loggingStreamInterceptor(logger)}
+	if key != "" {
+		unary = append(unary, signatureUnaryInterceptor(key))
+		stream = append(stream, signatureStreamInterceptor(key))
+	}
+
+	opts := []grpc.ServerOption{grpc.ChainUnaryInterceptor(unary...), grpc.ChainStreamInterceptor(stream...)}
+	if privateKey != nil {
+		opts = append(opts, grpc.ForceServerCodec(decryptingCodec{key: privateKey}))
+	}
+
+	server := grpc.NewServer(opts...)
+	pb.RegisterMetricsServer(server, NewMetricsServer(storage, parser))
+	return server
+}
+
+func (s *MetricsServer) UpdateMetrics(ctx context.Context, req *pb.UpdateMetricsRequest) (*pb.UpdateMetricsResponse, error) {
+	updated, err := s.updateBatch(ctx, req.GetBatchId(), req.GetMetrics())
+	if err != nil {
+		return nil, err
+	}
+	return &pb.UpdateMetricsResponse{Metrics: updated}, nil
+}
+
+func (s *MetricsServer) GetMetric(ctx context.Context, req *pb.GetMetricRequest) (*pb.GetMetricResponse, error) {
+	metric, err := s.parser.NewMetricFromDTO(dto.Metrics{
+		ID:     req.GetId(),
+		MType:  req.GetType(),
+		Labels: req.GetLabels(),
+	})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	response, err := s.storage.GetJSON(metric, ctx)
+	if err == nil && req.Quantile != nil {
+		response, err = metrics.WithQuantile(response, req.GetQuantile())
+	}
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.GetMetricResponse{Metric: pb.FromDTO(response)}, nil
+}
+
+func (s *MetricsServer) ListMetrics(ctx context.Context, _ *pb.ListMetricsRequest) (*pb.ListMetricsResponse, error) {
+	gauges, counters, err := s.storage.GetAll(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	histograms, err := s.storage.GetAllHistograms(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	summaries, err := s.storage.GetAllSummaries(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	sets, err := s.storage.GetAllSets(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	infos, err := s.storage.GetAllInfos(ctx)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	list := make([]*pb.Metric, 0, len(gauges)+len(counters)+len(histograms)+len(summaries)+len(sets)+len(infos))
+	for _, key := range sortedKeys(gauges) {
+		gauge := gauges[key]
+		list = append(list, pb.FromDTO(dto.Metrics{
+			ID:     gauge.Name,
+			MType:  string(gauge.Type),
+			Value:  &gauge.Value,
+			Labels: gauge.Labels,
+		}))
+	}
+	for _, key := range sortedKeys(counters) {
+		counter := counters[key]
+		list = append(list, pb.FromDTO(dto.Metrics{
+			ID:     counter.Name,
+			MType:  string(counter.Type),
+			Delta:  &counter.Value,
+			Labels: counter.Labels,
+		}))
+	}
+	for _, key := range sortedKeys(histograms) {
+		list = append(list, pb.FromDTO(metrics.HistogramDTO(histograms[key])))
+	}
+	for _, key := range sortedKeys(summaries) {
+		list = append(list, pb.FromDTO(metrics.SummaryDTO(summaries[key])))
+	}
+	for _, key := range sortedKeys(sets) {
+		list = append(list, pb.FromDTO(metrics.SetDTO(sets[key])))
+	}
+	for _, key := range sortedKeys(infos) {
+		info := infos[key]
+		list = append(list, pb.FromDTO(dto.Metrics{
+			ID:     info.Name,
+			MType:  string(info.Type),
+			Text:   &info.Value,
+			Labels: info.Labels,
+		}))
+	}
+
+	return &pb.ListMetricsResponse{Metrics: list}, nil
+}
+
+// Каждый батч из потока применяется сразу, как отдельный запрос /updates/
+func (s *MetricsServer) PushMetrics(stream grpc.ClientStreamingServer[pb.UpdateMetricsRequest, pb.PushMetricsResponse]) error {
+	var accepted int64
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return stream.SendAndClose(&pb.PushMetricsResponse{Accepted: accepted})
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.updateBatch(stream.Context(), req.GetBatchId(), req.GetMetrics()); err != nil {
+			return err
+		}
+		accepted += int64(len(req.GetMetrics()))
+	}
+}
+
+// Батч с уже применённым batchID пропускается, чтобы ретраи не увеличивали счётчики повторно
+func (s *MetricsServer) updateBatch(ctx context.Context, batchID string, batch []*pb.Metric) ([]*pb.Metric, error) {
+	metricsList := make([]models.Metric, 0, len(batch))
+	for _, m := range batch {
+		metric, err := s.parser.NewMetricFromDTO(pb.ToDTO(m))
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid metric: %v", err)
+		}
+		metricsList = append(metricsList, metric)
+	}
+
+	if batchID != "" {
+		switch s.batches.Begin(batchID) {
+		case idempotency.StatusDuplicate:
+			return nil, nil
+		case idempotency.StatusInProgress:
+			return nil, status.Error(codes.Aborted, "batch is being processed")
+		}
+	}
+
+	response, err := s.storage.UpdateBatchOnce(batchID, metricsList, ctx)
+	if errors.Is(err, metrics.ErrDuplicateBatch) {
+		s.batches.Done(batchID)
+		return nil, nil
+	}
+	if err != nil {
+		if batchID != "" {
+			s.batches.Abort(batchID)
+		}
+		return nil, toStatus(err)
+	}
+	if batchID != "" {
+		s.batches.Done(batchID)
+	}
+
+	updated := make([]*pb.Metric, 0, len(response))
+	for _, key := range sortedKeys(response) {
+		updated = append(updated, pb.FromDTO(response[key]))
+	}
+	return updated, nil
+}
+
+// Перевод ошибок хранилища в gRPC-статусы
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, metrics.ErrMetricNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, metrics.ErrInvalidMetricType),
+		errors.Is(err, metrics.ErrInvalidMetricID),
+		errors.Is(err, metrics.ErrInvalidMetricValue),
+		errors.Is(err, metrics.ErrInvalidMetricDelta),
+		errors.Is(err, metrics.ErrInvalidTimestamp):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func loggingUnaryInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logger.Info("Handled gRPC request",
+			zap.String("method", info.FullMethod),
+			zap.Duration("duration", time.Since(start)),
+			zap.String("code", status.Code(err).String()),
+		)
+		return resp, err
+	}
+}
+
+func loggingStreamInterceptor(logger *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		err := handler(srv, stream)
+		logger.Info("Handled gRPC stream",
+			zap.String("method", info.FullMethod),
+			zap.Duration("duration", time.Since(start)),
+			zap.String("code", status.Code(err).String()),
+		)
+		return err
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,333 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/GarikMirzoyan/metricalert/internal/hash"
+	"github.com/GarikMirzoyan/metricalert/internal/metrics"
+	pb "github.com/GarikMirzoyan/metricalert/internal/proto"
+	"github.com/GarikMirzoyan/metricalert/internal/sketch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+func newTestClient(t *testing.T, storage metrics.MetricStorage) pb.MetricsClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := NewServer(storage, metrics.NewDefaultParser(), "", nil, zap.NewNop())
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewMetricsClient(conn)
+}
+
+func gauge(id string, value float64) *pb.Metric {
+	return &pb.Metric{Id: id, Type: "gauge", Value: &value}
+}
+
+func counter(id string, delta int64) *pb.Metric {
+	return &pb.Metric{Id: id, Type: "counter", Delta: &delta}
+}
+
+func TestUpdateAndGetMetric(t *testing.T) {
+	client := newTestClient(t, metrics.NewMemStorage())
+	ctx := context.Background()
+
+	_, err := client.UpdateMetrics(ctx, &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{
+		gauge("Alloc", 12.5),
+		counter("PollCount", 3),
+	}})
+	require.NoError(t, err)
+
+	resp, err := client.UpdateMetrics(ctx, &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{counter("PollCount", 2)}})
+	require.NoError(t, err)
+	require.Len(t, resp.GetMetrics(), 1)
+
+	got, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "PollCount", Type: "counter"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), got.GetMetric().GetDelta())
+
+	got, err = client.GetMetric(ctx, &pb.GetMetricRequest{Id: "Alloc", Type: "gauge"})
+	require.NoError(t, err)
+	assert.Equal(t, 12.5, got.GetMetric().GetValue())
+}
+
+func TestUpdateMetricsWithLabels(t *testing.T) {
+	client := newTestClient(t, metrics.NewMemStorage())
+	ctx := context.Background()
+
+	metric := gauge("cpu", 0.5)
+	metric.Labels = map[string]string{"host": "web-1"}
+	_, err := client.UpdateMetrics(ctx, &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{metric}})
+	require.NoError(t, err)
+
+	got, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "cpu", Type: "gauge", Labels: map[string]string{"host": "web-1"}})
+	require.NoError(t, err)
+	assert.Equal(t, 0.5, got.GetMetric().GetValue())
+	assert.Equal(t, map[string]string{"host": "web-1"}, got.GetMetric().GetLabels())
+
+	_, err = client.GetMetric(ctx, &pb.GetMetricRequest{Id: "cpu", Type: "gauge"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestUpdateMetricsValidation(t *testing.T) {
+	client := newTestClient(t, metrics.NewMemStorage())
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		metric *pb.Metric
+	}{
+		{name: "empty id", metric: gauge("", 1)},
+		{name: "unknown type", metric: &pb.Metric{Id: "x", Type: "histogram"}},
+		{name: "invalid label", metric: &pb.Metric{Id: "x", Type: "gauge", Labels: map[string]string{"1bad": "v"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.UpdateMetrics(ctx, &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{tt.metric}})
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
+
+func TestGetMetricNotFound(t *testing.T) {
+	client := newTestClient(t, metrics.NewMemStorage())
+
+	_, err := client.GetMetric(context.Background(), &pb.GetMetricRequest{Id: "missing", Type: "gauge"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = client.GetMetric(context.Background(), &pb.GetMetricRequest{Id: "missing", Type: "unknown"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestListMetrics(t *testing.T) {
+	client := newTestClient(t, metrics.NewMemStorage())
+	ctx := context.Background()
+
+	_, err := client.UpdateMetrics(ctx, &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{
+		gauge("b", 2),
+		gauge("a", 1),
+		counter("c", 3),
+	}})
+	require.NoError(t, err)
+
+	resp, err := client.ListMetrics(ctx, &pb.ListMetricsRequest{})
+	require.NoError(t, err)
+
+	var ids []string
+	for _, m := range resp.GetMetrics() {
+		ids = append(ids, m.GetType()+"/"+m.GetId())
+	}
+	assert.Equal(t, []string{"gauge/a", "gauge/b", "counter/c"}, ids)
+}
+
+func TestPushMetrics(t *testing.T) {
+	client := newTestClient(t, metrics.NewMemStorage())
+	ctx := context.Background()
+
+	stream, err := client.PushMetrics(ctx)
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, stream.Send(&pb.UpdateMetricsRequest{Metrics: []*pb.Metric{
+			counter("PollCount", 1),
+			gauge("Alloc", float64(i)),
+		}}))
+	}
+
+	resp, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), resp.GetAccepted())
+
+	got, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "PollCount", Type: "counter"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), got.GetMetric().GetDelta())
+
+	got, err = client.GetMetric(ctx, &pb.GetMetricRequest{Id: "Alloc", Type: "gauge"})
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), got.GetMetric().GetValue())
+}
+
+func TestUpdateMetricsDuplicateBatchID(t *testing.T) {
+	client := newTestClient(t, metrics.NewMemStorage())
+	ctx := context.Background()
+
+	req := &pb.UpdateMetricsRequest{BatchId: "batch-1", Metrics: []*pb.Metric{counter("PollCount", 4)}}
+	_, err := client.UpdateMetrics(ctx, req)
+	require.NoError(t, err)
+	_, err = client.UpdateMetrics(ctx, req)
+	require.NoError(t, err)
+
+	got, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "PollCount", Type: "counter"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), got.GetMetric().GetDelta())
+}
+
+func TestUpdateHistogram(t *testing.T) {
+	client := newTestClient(t, metrics.NewMemStorage())
+	ctx := context.Background()
+
+	sum := 1.5
+	histogram := &pb.Metric{Id: "Latency", Type: "histogram", Buckets: []float64{0.1, 1}, Counts: []uint64{1, 2, 0}, Sum: &sum}
+	for range 2 {
+		_, err := client.UpdateMetrics(ctx, &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{histogram}})
+		require.NoError(t, err)
+	}
+
+	resp, err := client.ListMetrics(ctx, &pb.ListMetricsRequest{})
+	require.NoError(t, err)
+	require.Len(t, resp.GetMetrics(), 1)
+
+	listed := resp.GetMetrics()[0]
+	assert.Equal(t, []float64{0.1, 1}, listed.GetBuckets())
+	assert.Equal(t, []uint64{2, 4, 0}, listed.GetCounts())
+	assert.Equal(t, 3.0, listed.GetSum())
+	assert.Equal(t, uint64(6), listed.GetCount())
+}
+
+func TestGetSummaryQuantile(t *testing.T) {
+	client := newTestClient(t, metrics.NewMemStorage())
+	ctx := context.Background()
+
+	for _, value := range []float64{1, 2, 3, 4} {
+		_, err := client.UpdateMetrics(ctx, &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{
+			{Id: "Duration", Type: "summary", Value: &value},
+		}})
+		require.NoError(t, err)
+	}
+
+	q := 1.0
+	resp, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "Duration", Type: "summary", Quantile: &q})
+	require.NoError(t, err)
+	assert.Equal(t, 4.0, resp.GetMetric().GetValue())
+	assert.Equal(t, uint64(4), resp.GetMetric().GetSketch().GetCount())
+
+	// Состояние из ответа можно отправить обратно: скетчи складываются
+	_, err = client.UpdateMetrics(ctx, &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{resp.GetMetric()}})
+	require.NoError(t, err)
+
+	list, err := client.ListMetrics(ctx, &pb.ListMetricsRequest{})
+	require.NoError(t, err)
+	require.Len(t, list.GetMetrics(), 1)
+	assert.Equal(t, uint64(8), list.GetMetrics()[0].GetCount())
+
+	q = 2
+	_, err = client.GetMetric(ctx, &pb.GetMetricRequest{Id: "Duration", Type: "summary", Quantile: &q})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestUpdateSetFromAgents(t *testing.T) {
+	client := newTestClient(t, metrics.NewMemStorage())
+	ctx := context.Background()
+
+	// Первый агент передаёт элементы, второй — собранное у себя состояние HyperLogLog
+	_, err := client.UpdateMetrics(ctx, &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{
+		{Id: "Users", Type: "set", Members: []string{"alice", "bob"}},
+	}})
+	require.NoError(t, err)
+
+	h := sketch.NewDefaultHLL()
+	h.Add("bob")
+	h.Add("carol")
+	state := h.State()
+	_, err = client.UpdateMetrics(ctx, &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{
+		{Id: "Users", Type: "set", Hll: &pb.HyperLogLog{Precision: uint32(state.Precision), Registers: state.Registers}},
+	}})
+	require.NoError(t, err)
+
+	resp, err := client.GetMetric(ctx, &pb.GetMetricRequest{Id: "Users", Type: "set"})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), resp.GetMetric().GetCount())
+
+	_, err = client.UpdateMetrics(ctx, &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{
+		{Id: "Users", Type: "set", Hll: &pb.HyperLogLog{Precision: 300}},
+	}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestSignatureRequired(t *testing.T) {
+	listener := bufconn.Listen(1024 * 1024)
+	server := NewServer(metrics.NewMemStorage(), metrics.NewDefaultParser(), "secret", nil, zap.NewNop())
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	client := pb.NewMetricsClient(conn)
+
+	sign := func(req proto.Message, key string) string {
+		payload, err := SignedPayload(req)
+		require.NoError(t, err)
+		return hash.Sign(payload, key)
+	}
+	req := &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{counter("PollCount", 1)}}
+
+	_, err = client.UpdateMetrics(context.Background(), req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), SignatureMetadataKey, sign(req, "other"))
+	_, err = client.UpdateMetrics(ctx, req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), SignatureMetadataKey, sign(req, "secret"))
+	_, err = client.UpdateMetrics(ctx, req)
+	require.NoError(t, err)
+
+	// Пустое сообщение тоже требует подписи
+	_, err = client.UpdateMetrics(context.Background(), &pb.UpdateMetricsRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	list := &pb.ListMetricsRequest{}
+	_, err = client.ListMetrics(context.Background(), list)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), SignatureMetadataKey, sign(list, "secret"))
+	_, err = client.ListMetrics(ctx, list)
+	require.NoError(t, err)
+
+	// В потоке подпись указывается для каждого сообщения; сообщение без подписи прерывает поток
+	second := &pb.UpdateMetricsRequest{Metrics: []*pb.Metric{gauge("Alloc", 2)}}
+	ctx = metadata.AppendToOutgoingContext(context.Background(), SignatureMetadataKey, sign(req, "secret"), SignatureMetadataKey, sign(second, "secret"))
+	stream, err := client.PushMetrics(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(req))
+	require.NoError(t, stream.Send(second))
+	response, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), response.GetAccepted())
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), SignatureMetadataKey, sign(req, "secret"))
+	stream, err = client.PushMetrics(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(req))
+	_ = stream.Send(second)
+	_, err = stream.CloseAndRecv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/GarikMirzoyan/metricalert/internal/alerts"
+)
+
+type AlertsHandler struct {
+	engine *alerts.Engine
+}
+
+func NewAlertsHandlers(engine *alerts.Engine) *AlertsHandler {
+	AlertsHandler := &AlertsHandler{engine: engine}
+
+	return AlertsHandler
+}
+
+// Список активных алертов (pending и firing), с ?all=true — включая разрешённые
+func (h *AlertsHandler) ListAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	var list []alerts.Alert
+	if r.URL.Query().Get("all") == "true" {
+		list = h.engine.Alerts()
+	} else {
+		list = h.engine.ActiveAlerts()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		http.Error(w, "Ошибка получения данных", http.StatusInternalServerError)
+	}
+}
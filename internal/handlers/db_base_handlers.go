@@ -9,10 +9,10 @@ import (
 )
 
 type DBBaseHandler struct {
-	DBConn database.DBConn
+	DBConn database.Pinger
 }
 
-func NewDBBaseHandlers(DBConn database.DBConn) *DBBaseHandler {
+func NewDBBaseHandlers(DBConn database.Pinger) *DBBaseHandler {
 	DBBaseHandler := &DBBaseHandler{DBConn: DBConn}
 
 	return DBBaseHandler
@@ -6,23 +6,64 @@ import (
 	"fmt"
 	"html/template"
 	"net/http"
+	"strconv"
 	"strings"
 
 	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/idempotency"
 	"github.com/GarikMirzoyan/metricalert/internal/metrics"
 	"github.com/GarikMirzoyan/metricalert/internal/models"
+	"github.com/GarikMirzoyan/metricalert/internal/query"
 	"github.com/GarikMirzoyan/metricalert/internal/utils"
 	"github.com/go-chi/chi"
+	"go.uber.org/zap"
 )
 
 // Handlers содержит зависимости
 type Handler struct {
-	ms   metrics.MetricStorage
-	tmpl *template.Template
+	ms             metrics.MetricStorage
+	parser         *metrics.Parser
+	tmpl           *template.Template
+	batches        *idempotency.Cache
+	queryMaxPoints int
+	logger         *zap.Logger
+}
+
+// Настройки хендлеров из конфигурации сервера
+type Options struct {
+	Parser         *metrics.Parser // nil — metrics.NewDefaultParser()
+	QueryMaxPoints int             // ограничение числа точек ответа /query; 0 — query.DefaultMaxPoints
+	Logger         *zap.Logger     // nil — без логирования
 }
 
 func NewHandlers(ms metrics.MetricStorage) *Handler {
-	DBHandler := &Handler{ms: ms, tmpl: utils.InitTemplate()}
+	return NewHandlersWithOptions(ms, Options{})
+}
+
+func NewHandlersWithParser(ms metrics.MetricStorage, parser *metrics.Parser) *Handler {
+	return NewHandlersWithOptions(ms, Options{Parser: parser})
+}
+
+func NewHandlersWithOptions(ms metrics.MetricStorage, options Options) *Handler {
+	if options.Parser == nil {
+		options.Parser = metrics.NewDefaultParser()
+	}
+	if options.QueryMaxPoints < 1 {
+		options.QueryMaxPoints = query.DefaultMaxPoints
+	}
+	if options.Logger == nil {
+		options.Logger = zap.NewNop()
+	}
+
+	DBHandler := &Handler{
+		ms:             ms,
+		parser:         options.Parser,
+		tmpl:           utils.InitTemplate(),
+		batches:        idempotency.NewCache(idempotency.DefaultCapacity),
+		queryMaxPoints: options.QueryMaxPoints,
+		logger:         options.Logger,
+	}
 
 	return DBHandler
 }
@@ -32,7 +73,22 @@ func (h *Handler) UpdateHandler(w http.ResponseWriter, r *http.Request) {
 	metricName := chi.URLParam(r, "name")
 	metricValue := chi.URLParam(r, "value")
 
-	metric, err := metrics.NewMetric(metricType, metricName, metricValue)
+	labels, err := labelsFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	metric, err := h.parser.NewMetric(metricType, metricName, metricValue, labels)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	timestamp, err := timestampFromQuery(r)
+	if err == nil {
+		err = metrics.SetObservationTime(metric, timestamp)
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -41,11 +97,13 @@ func (h *Handler) UpdateHandler(w http.ResponseWriter, r *http.Request) {
 	err = h.ms.Update(metric, r.Context())
 
 	if err != nil {
-		switch err {
-		case metrics.ErrInvalidMetricType:
+		switch {
+		case errors.Is(err, metrics.ErrInvalidMetricType):
 			http.Error(w, "Invalid metric type", http.StatusBadRequest)
-		case metrics.ErrInvalidMetricValue:
+		case errors.Is(err, metrics.ErrInvalidMetricValue):
 			http.Error(w, "Invalid metric value", http.StatusBadRequest)
+		case errors.Is(err, metrics.ErrInvalidTimestamp):
+			http.Error(w, err.Error(), http.StatusBadRequest)
 		default:
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
@@ -66,13 +124,10 @@ func (h *Handler) UpdateHandlerJSON(w http.ResponseWriter, r *http.Request) {
 	var request dto.Metrics
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
 	}
 
-	if request.ID == "" {
-		http.Error(w, "metric ID is required", http.StatusBadRequest)
-	}
-
-	metric, err := metrics.NewMetricFromDTO(request)
+	metric, err := h.parser.NewMetricFromDTO(request)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -81,8 +136,8 @@ func (h *Handler) UpdateHandlerJSON(w http.ResponseWriter, r *http.Request) {
 	response, err := h.ms.UpdateJSON(metric, r.Context())
 	if err != nil {
 		switch {
-		case errors.Is(err, metrics.ErrInvalidMetricValue):
-			http.Error(w, "Value is required for gauge", http.StatusBadRequest)
+		case errors.Is(err, metrics.ErrInvalidMetricValue), errors.Is(err, metrics.ErrInvalidTimestamp):
+			http.Error(w, err.Error(), http.StatusBadRequest)
 		case errors.Is(err, metrics.ErrInvalidMetricDelta):
 			http.Error(w, "Value is required for delta", http.StatusBadRequest)
 		case errors.Is(err, metrics.ErrInvalidMetricType):
@@ -111,14 +166,31 @@ func (h *Handler) GetValueHandler(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Имя метрики не передано", http.StatusBadRequest)
 		return
 	}
+	if err := models.ValidateMetricName(metricName); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	value, err := h.ms.GetValue(metricType, metricName, r.Context())
+	labels, err := labelsFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var value string
+	if r.URL.Query().Has("quantile") {
+		value, err = h.quantileValue(metricType, metricName, labels, r)
+	} else {
+		value, err = h.ms.GetValue(metricType, metricName, labels, r.Context())
+	}
 	if err != nil {
 		switch {
 		case errors.Is(err, metrics.ErrMetricNotFound):
 			http.Error(w, "Metric not found", http.StatusNotFound)
 		case errors.Is(err, metrics.ErrInvalidMetricType):
 			http.Error(w, "Invalid metric type", http.StatusBadRequest)
+		case errors.Is(err, metrics.ErrInvalidMetricValue):
+			http.Error(w, err.Error(), http.StatusBadRequest)
 		default:
 			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
@@ -139,27 +211,31 @@ func (h *Handler) GetValueHandlerJSON(w http.ResponseWriter, r *http.Request) {
 	var request dto.Metrics
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
 	}
 
-	if request.ID == "" {
-		http.Error(w, "invalid metric type", http.StatusBadRequest)
-	}
-
-	metric, err := metrics.NewMetricFromDTO(request)
+	metric, err := h.parser.NewMetricFromDTO(request)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	response, err := h.ms.GetJSON(metric, r.Context())
+	if err == nil && request.Quantile != nil {
+		response, err = metrics.WithQuantile(response, *request.Quantile)
+	}
 	if err != nil {
 		switch {
 		case errors.Is(err, metrics.ErrMetricNotFound):
 			http.Error(w, "Metric not found", http.StatusNotFound)
 		case errors.Is(err, metrics.ErrInvalidMetricType):
 			http.Error(w, "Invalid metric type", http.StatusBadRequest)
+		case errors.Is(err, metrics.ErrInvalidMetricValue):
+			http.Error(w, err.Error(), http.StatusBadRequest)
 		default:
-			http.Error(w, fmt.Sprintf("Internal server error: %v", err), http.StatusInternalServerError)
+			// Текст ошибки хранилища или драйвера клиенту не отдаётся
+			h.logger.Error("Error getting metric", zap.String("id", request.ID), zap.Error(err))
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
 		}
 		return
 	}
@@ -171,6 +247,24 @@ func (h *Handler) GetValueHandlerJSON(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Квантиль summary из параметра quantile, например /value/summary/latency?quantile=0.99
+func (h *Handler) quantileValue(metricType, metricName string, labels models.Labels, r *http.Request) (string, error) {
+	if constants.MetricType(metricType) != constants.SummaryName {
+		return "", fmt.Errorf("%w: quantile is supported only for summary", metrics.ErrInvalidMetricType)
+	}
+
+	q, err := strconv.ParseFloat(r.URL.Query().Get("quantile"), 64)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid quantile: %w", metrics.ErrInvalidMetricValue, err)
+	}
+
+	metric, err := h.ms.GetSummary(metricName, labels, r.Context())
+	if err != nil {
+		return "", err
+	}
+	return metrics.SummaryQuantile(metric, q)
+}
+
 func (h *Handler) RootHandler(w http.ResponseWriter, r *http.Request) {
 	gauges, counters, err := h.ms.GetAll(r.Context())
 	if err != nil {
@@ -178,12 +272,44 @@ func (h *Handler) RootHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	histograms, err := h.ms.GetAllHistograms(r.Context())
+	if err != nil {
+		http.Error(w, "Ошибка при получении метрик", http.StatusInternalServerError)
+		return
+	}
+
+	summaries, err := h.ms.GetAllSummaries(r.Context())
+	if err != nil {
+		http.Error(w, "Ошибка при получении метрик", http.StatusInternalServerError)
+		return
+	}
+
+	sets, err := h.ms.GetAllSets(r.Context())
+	if err != nil {
+		http.Error(w, "Ошибка при получении метрик", http.StatusInternalServerError)
+		return
+	}
+
+	infos, err := h.ms.GetAllInfos(r.Context())
+	if err != nil {
+		http.Error(w, "Ошибка при получении метрик", http.StatusInternalServerError)
+		return
+	}
+
 	data := struct {
-		Gauges   map[string]models.GaugeMetric
-		Counters map[string]models.CounterMetric
+		Gauges     map[string]models.GaugeMetric
+		Counters   map[string]models.CounterMetric
+		Histograms map[string]models.HistogramMetric
+		Summaries  map[string]models.SummaryMetric
+		Sets       map[string]models.SetMetric
+		Infos      map[string]models.InfoMetric
 	}{
-		Gauges:   gauges,
-		Counters: counters,
+		Gauges:     gauges,
+		Counters:   counters,
+		Histograms: histograms,
+		Summaries:  summaries,
+		Sets:       sets,
+		Infos:      infos,
 	}
 
 	w.Header().Set("Content-Type", "text/html")
@@ -208,7 +334,7 @@ func (h *Handler) BatchMetricsUpdateHandler(w http.ResponseWriter, r *http.Reque
 	// Преобразуем DTO в map[string]models.Metric
 	var metricsList []models.Metric
 	for _, dto := range metricsDTO {
-		metric, err := metrics.NewMetricFromDTO(dto)
+		metric, err := h.parser.NewMetricFromDTO(dto)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Invalid metric: %v", err), http.StatusBadRequest)
 			return
@@ -216,12 +342,46 @@ func (h *Handler) BatchMetricsUpdateHandler(w http.ResponseWriter, r *http.Reque
 		metricsList = append(metricsList, metric)
 	}
 
+	// Повтор уже применённого батча не должен второй раз увеличить счётчики.
+	// Кэш отсекает повторы без обращения к хранилищу, хранилище — повторы после перезапуска.
+	batchID := r.Header.Get(idempotency.HeaderName)
+	if batchID != "" {
+		switch h.batches.Begin(batchID) {
+		case idempotency.StatusDuplicate:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("{}\n"))
+			return
+		case idempotency.StatusInProgress:
+			http.Error(w, "Batch is being processed", http.StatusConflict)
+			return
+		}
+	}
+
 	// Обновляем метрики
-	response, err := h.ms.UpdateBatchJSON(metricsList, r.Context())
+	response, err := h.ms.UpdateBatchOnce(batchID, metricsList, r.Context())
+	if errors.Is(err, metrics.ErrDuplicateBatch) {
+		h.batches.Done(batchID)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("{}\n"))
+		return
+	}
 	if err != nil {
+		if batchID != "" {
+			h.batches.Abort(batchID)
+		}
+		// Например, гистограмма с другими границами корзин или время вне окна
+		if errors.Is(err, metrics.ErrInvalidMetricValue) || errors.Is(err, metrics.ErrInvalidTimestamp) {
+			http.Error(w, fmt.Sprintf("Invalid metric: %v", err), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 		return
 	}
+	if batchID != "" {
+		h.batches.Done(batchID)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -229,3 +389,38 @@ func (h *Handler) BatchMetricsUpdateHandler(w http.ResponseWriter, r *http.Reque
 		http.Error(w, "Error encoding response", http.StatusInternalServerError)
 	}
 }
+
+// Время наблюдения из параметра ?timestamp= в миллисекундах Unix; nil, если не передано
+func timestampFromQuery(r *http.Request) (*int64, error) {
+	if !r.URL.Query().Has("timestamp") {
+		return nil, nil
+	}
+
+	timestamp, err := strconv.ParseInt(r.URL.Query().Get("timestamp"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", metrics.ErrInvalidTimestamp, err)
+	}
+	return &timestamp, nil
+}
+
+// Метки из параметров запроса вида ?label=host:web-1&label=region:eu
+func labelsFromQuery(r *http.Request) (models.Labels, error) {
+	values := r.URL.Query()["label"]
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	labels := make(models.Labels, len(values))
+	for _, value := range values {
+		name, labelValue, found := strings.Cut(value, ":")
+		if !found {
+			return nil, fmt.Errorf("%w: expected name:value, got %q", models.ErrInvalidLabels, value)
+		}
+		labels[name] = labelValue
+	}
+
+	if err := labels.Validate(); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
@@ -0,0 +1,432 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+
+	"github.com/GarikMirzoyan/metricalert/internal/idempotency"
+	"github.com/GarikMirzoyan/metricalert/internal/metrics"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newTestRouter(h *Handler) *chi.Mux {
+	r := chi.NewRouter()
+	r.Post("/update/{type}/{name}/{value}", h.UpdateHandler)
+	r.Post("/update/", h.UpdateHandlerJSON)
+	r.Post("/updates/", h.BatchMetricsUpdateHandler)
+	r.Get("/value/{type}/{name}", h.GetValueHandler)
+	r.Post("/value/", h.GetValueHandlerJSON)
+	return r
+}
+
+func doRequest(t *testing.T, r http.Handler, method, url, body string) (int, string) {
+	req := httptest.NewRequest(method, url, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	respBody, err := io.ReadAll(w.Body)
+	require.NoError(t, err)
+	return w.Code, string(respBody)
+}
+
+func TestLabels_BatchUpdateAndValue(t *testing.T) {
+	r := newTestRouter(NewHandlers(metrics.NewMemStorage()))
+
+	batch := `[
+		{"id":"HeapAlloc","type":"gauge","value":1,"labels":{"host":"web-1"}},
+		{"id":"HeapAlloc","type":"gauge","value":2,"labels":{"host":"web-2"}},
+		{"id":"HeapAlloc","type":"gauge","value":3}
+	]`
+	code, _ := doRequest(t, r, http.MethodPost, "/updates/", batch)
+	require.Equal(t, http.StatusOK, code)
+
+	code, body := doRequest(t, r, http.MethodGet, "/value/gauge/HeapAlloc?label=host:web-2", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "2", body)
+
+	code, body = doRequest(t, r, http.MethodGet, "/value/gauge/HeapAlloc", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "3", body)
+
+	code, body = doRequest(t, r, http.MethodPost, "/value/", `{"id":"HeapAlloc","type":"gauge","labels":{"host":"web-1"}}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"id":"HeapAlloc","type":"gauge","value":1,"labels":{"host":"web-1"}}`, body)
+
+	code, _ = doRequest(t, r, http.MethodGet, "/value/gauge/HeapAlloc?label=host:web-3", "")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestLabels_Invalid(t *testing.T) {
+	r := newTestRouter(NewHandlers(metrics.NewMemStorage()))
+
+	code, _ := doRequest(t, r, http.MethodPost, "/update/counter/Requests/1?label=host", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = doRequest(t, r, http.MethodPost, "/updates/", `[{"id":"A","type":"gauge","value":1,"labels":{"bad-name":"x"}}]`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestMetricName_Invalid(t *testing.T) {
+	r := newTestRouter(NewHandlers(metrics.NewMemStorage()))
+
+	code, _ := doRequest(t, r, http.MethodPost, "/update/gauge/HeapAlloc/1?label=host:a", "")
+	require.Equal(t, http.StatusOK, code)
+
+	// Имя с символами ключа серии отклоняется во всех обработчиках
+	code, _ = doRequest(t, r, http.MethodPost, "/update/gauge/HeapAlloc%7Bhost=%22a%22%7D/2", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = doRequest(t, r, http.MethodGet, "/value/gauge/HeapAlloc%7Bhost=%22a%22%7D", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = doRequest(t, r, http.MethodPost, "/update/", `{"id":"HeapAlloc{host=\"a\"}","type":"gauge","value":2}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = doRequest(t, r, http.MethodPost, "/updates/", `[{"id":"Heap\"Alloc","type":"gauge","value":2}]`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, body := doRequest(t, r, http.MethodGet, "/value/gauge/HeapAlloc?label=host:a", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "1", body)
+}
+
+func TestBatchUpdate_DuplicateBatchIDAppliedOnce(t *testing.T) {
+	storage := metrics.NewMemStorage()
+	r := newTestRouter(NewHandlers(storage))
+
+	send := func(batchID string) int {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(`[{"id":"PollCount","type":"counter","delta":5}]`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(idempotency.HeaderName, batchID)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, send("batch-1"))
+	// Повтор после потерянного ответа
+	assert.Equal(t, http.StatusOK, send("batch-1"))
+	assert.Equal(t, http.StatusOK, send("batch-2"))
+
+	// Повтор после перезапуска: кэш хендлеров пуст, батч отсекает хранилище
+	r = newTestRouter(NewHandlers(storage))
+	assert.Equal(t, http.StatusOK, send("batch-1"))
+
+	code, body := doRequest(t, r, http.MethodGet, "/value/counter/PollCount", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "10", body)
+}
+
+func TestHistogram_ObservationsAndExposition(t *testing.T) {
+	h := NewHandlers(metrics.NewMemStorage())
+	r := newTestRouter(h)
+	r.Get("/metrics", h.PrometheusHandler)
+
+	for _, value := range []string{"0.03", "0.04", "7"} {
+		code, _ := doRequest(t, r, http.MethodPost, "/update/histogram/Latency/"+value, "")
+		require.Equal(t, http.StatusOK, code)
+	}
+
+	code, body := doRequest(t, r, http.MethodGet, "/value/histogram/Latency", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "3", body)
+
+	code, body = doRequest(t, r, http.MethodGet, "/metrics", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "# TYPE Latency histogram\n")
+	assert.Contains(t, body, "Latency_bucket{le=\"0.025\"} 0\n")
+	assert.Contains(t, body, "Latency_bucket{le=\"0.05\"} 2\n")
+	assert.Contains(t, body, "Latency_bucket{le=\"+Inf\"} 3\n")
+	assert.Contains(t, body, "Latency_count 3\n")
+
+	// Корзины в запросе должны совпадать с корзинами серии
+	code, _ = doRequest(t, r, http.MethodPost, "/updates/", `[{"id":"Latency","type":"histogram","buckets":[1],"counts":[1,0],"sum":0.5}]`)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = doRequest(t, r, http.MethodPost, "/update/histogram/Latency/NaN", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestHistogram_ParserBuckets(t *testing.T) {
+	_, err := metrics.NewParser([]float64{1, 0.5})
+	require.Error(t, err)
+
+	parser, err := metrics.NewParser([]float64{1, 10})
+	require.NoError(t, err)
+
+	// Корзины задаются хендлерам, а не глобально: у второго хендлера корзины по умолчанию
+	h := NewHandlersWithParser(metrics.NewMemStorage(), parser)
+	r := newTestRouter(h)
+	r.Get("/metrics", h.PrometheusHandler)
+	defaults := NewHandlers(metrics.NewMemStorage())
+	rd := newTestRouter(defaults)
+	rd.Get("/metrics", defaults.PrometheusHandler)
+
+	for _, router := range []*chi.Mux{r, rd} {
+		code, _ := doRequest(t, router, http.MethodPost, "/update/histogram/Latency/5", "")
+		require.Equal(t, http.StatusOK, code)
+	}
+
+	_, body := doRequest(t, r, http.MethodGet, "/metrics", "")
+	assert.Contains(t, body, "Latency_bucket{le=\"10\"} 1\n")
+	assert.NotContains(t, body, "le=\"0.05\"")
+
+	_, body = doRequest(t, rd, http.MethodGet, "/metrics", "")
+	assert.Contains(t, body, "Latency_bucket{le=\"0.05\"} 0\n")
+}
+
+func TestSummary_Quantiles(t *testing.T) {
+	h := NewHandlers(metrics.NewMemStorage())
+	r := newTestRouter(h)
+	r.Get("/metrics", h.PrometheusHandler)
+
+	observations := make([]string, 0, 100)
+	for i := 1; i <= 100; i++ {
+		observations = append(observations, fmt.Sprintf(`{"id":"Duration","type":"summary","value":%d}`, i))
+	}
+	code, _ := doRequest(t, r, http.MethodPost, "/updates/", "["+strings.Join(observations, ",")+"]")
+	require.Equal(t, http.StatusOK, code)
+
+	code, _ = doRequest(t, r, http.MethodPost, "/update/summary/Duration/100", "")
+	require.Equal(t, http.StatusOK, code)
+
+	code, body := doRequest(t, r, http.MethodGet, "/value/summary/Duration?quantile=0.99", "")
+	require.Equal(t, http.StatusOK, code)
+	p99, err := strconv.ParseFloat(body, 64)
+	require.NoError(t, err)
+	assert.InEpsilon(t, 100, p99, 0.01)
+
+	code, body = doRequest(t, r, http.MethodPost, "/value/", `{"id":"Duration","type":"summary","quantile":0.5}`)
+	require.Equal(t, http.StatusOK, code)
+	var response dto.Metrics
+	require.NoError(t, json.Unmarshal([]byte(body), &response))
+	require.NotNil(t, response.Value)
+	assert.InEpsilon(t, 51, *response.Value, 0.01)
+	require.NotNil(t, response.Count)
+	assert.Equal(t, uint64(101), *response.Count)
+
+	code, body = doRequest(t, r, http.MethodGet, "/metrics", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "# TYPE Duration summary\n")
+	assert.Contains(t, body, "Duration_count 101\n")
+
+	for _, url := range []string{
+		"/value/summary/Duration?quantile=1.5",
+		"/value/summary/Duration?quantile=abc",
+		"/value/gauge/Duration?quantile=0.5",
+	} {
+		code, _ = doRequest(t, r, http.MethodGet, url, "")
+		assert.Equal(t, http.StatusBadRequest, code, url)
+	}
+
+	code, _ = doRequest(t, r, http.MethodGet, "/value/summary/Unknown?quantile=0.5", "")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+func TestSet_DistinctMembers(t *testing.T) {
+	h := NewHandlers(metrics.NewMemStorage())
+	r := newTestRouter(h)
+	r.Get("/metrics", h.PrometheusHandler)
+
+	for _, user := range []string{"alice", "bob", "alice"} {
+		code, _ := doRequest(t, r, http.MethodPost, "/update/set/Users/"+user+"?label=path:/login", "")
+		require.Equal(t, http.StatusOK, code)
+	}
+
+	// Второй агент присылает элементы батчем
+	code, _ := doRequest(t, r, http.MethodPost, "/updates/",
+		`[{"id":"Users","type":"set","labels":{"path":"/login"},"members":["bob","carol"]}]`)
+	require.Equal(t, http.StatusOK, code)
+
+	code, body := doRequest(t, r, http.MethodGet, "/value/set/Users?label=path:/login", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "3", body)
+
+	code, body = doRequest(t, r, http.MethodGet, "/metrics", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "# TYPE Users gauge\n")
+	assert.Contains(t, body, "Users{path=\"/login\"} 3\n")
+
+	code, _ = doRequest(t, r, http.MethodPost, "/updates/", `[{"id":"Users","type":"set"}]`)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestInfo_PathAndJSON(t *testing.T) {
+	h := NewHandlers(metrics.NewMemStorage())
+	r := newTestRouter(h)
+	r.Get("/", h.RootHandler)
+	r.Post("/update/", h.UpdateHandlerJSON)
+
+	code, _ := doRequest(t, r, http.MethodPost, "/update/info/Version/1.2.3", "")
+	require.Equal(t, http.StatusOK, code)
+
+	code, body := doRequest(t, r, http.MethodPost, "/update/", `{"id":"Leader","type":"info","text":"<true>"}`)
+	require.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"id":"Leader","type":"info","text":"<true>"}`, body)
+
+	code, body = doRequest(t, r, http.MethodGet, "/value/info/Version", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "1.2.3", body)
+
+	code, body = doRequest(t, r, http.MethodGet, "/", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "Version: 1.2.3")
+	assert.Contains(t, body, "Leader: &lt;true&gt;")
+
+	code, _ = doRequest(t, r, http.MethodPost, "/update/info/Version/"+strings.Repeat("x", 300), "")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestTimestamp_PathAndPolicy(t *testing.T) {
+	storage := metrics.NewMemStorageWithOptions(metrics.Options{
+		Timestamps: metrics.TimestampPolicy{Mode: metrics.TimestampClamp, MaxAge: time.Hour, MaxFuture: time.Minute},
+	})
+	r := newTestRouter(NewHandlers(storage))
+	now := time.Now()
+	ts := func(offset time.Duration) string {
+		return strconv.FormatInt(now.Add(offset).UnixMilli(), 10)
+	}
+
+	code, _ := doRequest(t, r, http.MethodPost, "/update/gauge/Temperature/20?timestamp="+ts(-time.Minute), "")
+	require.Equal(t, http.StatusOK, code)
+
+	// Более раннее наблюдение, пришедшее позже, не заменяет значение
+	code, _ = doRequest(t, r, http.MethodPost, "/update/gauge/Temperature/10?timestamp="+ts(-2*time.Minute), "")
+	require.Equal(t, http.StatusOK, code)
+
+	code, body := doRequest(t, r, http.MethodGet, "/value/gauge/Temperature", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "20", body)
+
+	// Наблюдение старше допустимого прижимается к границе окна, батч применяется целиком
+	batch := fmt.Sprintf(`[{"id":"Temperature","type":"gauge","value":30},{"id":"Requests","type":"counter","delta":1,"timestamp":%s}]`, ts(-2*time.Hour))
+	code, body = doRequest(t, r, http.MethodPost, "/updates/", batch)
+	require.Equal(t, http.StatusOK, code)
+
+	var responses map[string]dto.Metrics
+	require.NoError(t, json.Unmarshal([]byte(body), &responses))
+	require.NotNil(t, responses["counter Requests"].Timestamp)
+	assert.GreaterOrEqual(t, *responses["counter Requests"].Timestamp, now.Add(-time.Hour).UnixMilli())
+
+	code, body = doRequest(t, r, http.MethodGet, "/value/gauge/Temperature", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "30", body)
+
+	code, body = doRequest(t, r, http.MethodGet, "/value/counter/Requests", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "1", body)
+
+	// Метка из будущего прижимается к now+MaxFuture
+	code, body = doRequest(t, r, http.MethodPost, "/update/", fmt.Sprintf(`{"id":"Temperature","type":"gauge","value":50,"timestamp":%s}`, ts(time.Hour)))
+	require.Equal(t, http.StatusOK, code)
+
+	var response dto.Metrics
+	require.NoError(t, json.Unmarshal([]byte(body), &response))
+	require.NotNil(t, response.Timestamp)
+	assert.LessOrEqual(t, *response.Timestamp, time.Now().Add(time.Minute).UnixMilli())
+
+	code, body = doRequest(t, r, http.MethodGet, "/value/gauge/Temperature", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "50", body)
+
+	for _, timestamp := range []string{"not-a-number", "-5"} {
+		code, _ = doRequest(t, r, http.MethodPost, "/update/gauge/Temperature/60?timestamp="+timestamp, "")
+		assert.Equal(t, http.StatusBadRequest, code, timestamp)
+	}
+}
+
+// Батч агента, часы которого убежали вперёд больше чем на MaxFuture, по умолчанию
+// сохраняется: метки прижимаются к границе окна, а не отклоняют весь батч
+func TestTimestamp_SkewedAgentBatchStored(t *testing.T) {
+	storage := metrics.NewMemStorageWithOptions(metrics.Options{
+		Timestamps: metrics.TimestampPolicy{MaxFuture: time.Minute},
+	})
+	r := newTestRouter(NewHandlers(storage))
+	skewed := time.Now().Add(10 * time.Minute).UnixMilli()
+
+	batch := fmt.Sprintf(`[{"id":"Alloc","type":"gauge","value":42,"timestamp":%d},{"id":"PollCount","type":"counter","delta":5,"timestamp":%d}]`, skewed, skewed)
+	code, body := doRequest(t, r, http.MethodPost, "/updates/", batch)
+	require.Equal(t, http.StatusOK, code, body)
+
+	var responses map[string]dto.Metrics
+	require.NoError(t, json.Unmarshal([]byte(body), &responses))
+	require.NotNil(t, responses["gauge Alloc"].Timestamp)
+	assert.LessOrEqual(t, *responses["gauge Alloc"].Timestamp, time.Now().Add(time.Minute).UnixMilli())
+
+	code, body = doRequest(t, r, http.MethodGet, "/value/gauge/Alloc", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "42", body)
+
+	code, body = doRequest(t, r, http.MethodGet, "/value/counter/PollCount", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "5", body)
+}
+
+func TestTimestamp_RejectOutsideWindow(t *testing.T) {
+	storage := metrics.NewMemStorageWithOptions(metrics.Options{
+		Timestamps: metrics.TimestampPolicy{Mode: metrics.TimestampReject, MaxAge: time.Hour, MaxFuture: time.Minute},
+	})
+	r := newTestRouter(NewHandlers(storage))
+	now := time.Now()
+	ts := func(offset time.Duration) string {
+		return strconv.FormatInt(now.Add(offset).UnixMilli(), 10)
+	}
+
+	code, _ := doRequest(t, r, http.MethodPost, "/update/gauge/Temperature/20?timestamp="+ts(-time.Minute), "")
+	require.Equal(t, http.StatusOK, code)
+
+	code, _ = doRequest(t, r, http.MethodPost, "/update/gauge/Temperature/30?timestamp="+ts(-2*time.Hour), "")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = doRequest(t, r, http.MethodPost, "/update/", fmt.Sprintf(`{"id":"Temperature","type":"gauge","value":50,"timestamp":%s}`, ts(time.Hour)))
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	// Батч с одной меткой вне окна не применяется целиком
+	batch := fmt.Sprintf(`[{"id":"Requests","type":"counter","delta":1},{"id":"Temperature","type":"gauge","value":40,"timestamp":%s}]`, ts(time.Hour))
+	code, _ = doRequest(t, r, http.MethodPost, "/updates/", batch)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, body := doRequest(t, r, http.MethodGet, "/value/gauge/Temperature", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "20", body)
+
+	code, _ = doRequest(t, r, http.MethodGet, "/value/counter/Requests", "")
+	assert.Equal(t, http.StatusNotFound, code)
+}
+
+// Хранилище, у которого чтение завершается ошибкой базы
+type failingStorage struct {
+	metrics.MetricStorage
+}
+
+func (failingStorage) GetJSON(metric models.Metric, ctx context.Context) (dto.Metrics, error) {
+	return dto.Metrics{}, errors.New(`pq: password authentication failed for user "metrics"`)
+}
+
+func TestGetValueJSON_InternalErrorNotExposed(t *testing.T) {
+	core, logs := observer.New(zap.ErrorLevel)
+	r := newTestRouter(NewHandlersWithOptions(failingStorage{}, Options{Logger: zap.New(core)}))
+
+	code, body := doRequest(t, r, http.MethodPost, "/value/", `{"id":"Alloc","type":"gauge"}`)
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, "Internal server error\n", body)
+
+	require.Equal(t, 1, logs.Len())
+	assert.Contains(t, logs.All()[0].ContextMap()["error"], "password authentication failed")
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/GarikMirzoyan/metricalert/internal/exposition"
+)
+
+// Все метрики в текстовом формате Prometheus
+func (h *Handler) PrometheusHandler(w http.ResponseWriter, r *http.Request) {
+	gauges, counters, err := h.ms.GetAll(r.Context())
+	if err != nil {
+		http.Error(w, "Ошибка при получении метрик", http.StatusInternalServerError)
+		return
+	}
+	histograms, err := h.ms.GetAllHistograms(r.Context())
+	if err != nil {
+		http.Error(w, "Ошибка при получении метрик", http.StatusInternalServerError)
+		return
+	}
+	summaries, err := h.ms.GetAllSummaries(r.Context())
+	if err != nil {
+		http.Error(w, "Ошибка при получении метрик", http.StatusInternalServerError)
+		return
+	}
+	sets, err := h.ms.GetAllSets(r.Context())
+	if err != nil {
+		http.Error(w, "Ошибка при получении метрик", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", exposition.ContentType)
+	w.WriteHeader(http.StatusOK)
+	_ = exposition.WritePrometheus(w, exposition.Metrics{
+		Gauges:     gauges,
+		Counters:   counters,
+		Histograms: histograms,
+		Summaries:  summaries,
+		Sets:       sets,
+	})
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/metrics"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+	"github.com/GarikMirzoyan/metricalert/internal/query"
+)
+
+const (
+	defaultQueryRange = time.Hour
+	defaultQueryStep  = time.Minute
+)
+
+// GET /query?id=HeapAlloc&type=gauge&from=...&to=...&step=30s&fn=avg[&label=host:web-1]
+func (h *Handler) QueryHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+
+	id := params.Get("id")
+	if id == "" {
+		http.Error(w, "metric ID is required", http.StatusBadRequest)
+		return
+	}
+	if err := models.ValidateMetricName(id); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	metricType := constants.MetricType(params.Get("type"))
+
+	labels, err := labelsFromQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	to, err := parseQueryTime(params.Get("to"), now)
+	if err != nil {
+		http.Error(w, "Invalid 'to' parameter", http.StatusBadRequest)
+		return
+	}
+	from, err := parseQueryTime(params.Get("from"), to.Add(-defaultQueryRange))
+	if err != nil {
+		http.Error(w, "Invalid 'from' parameter", http.StatusBadRequest)
+		return
+	}
+
+	step := defaultQueryStep
+	if s := params.Get("step"); s != "" {
+		if step, err = time.ParseDuration(s); err != nil {
+			http.Error(w, "Invalid 'step' parameter", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := query.ValidateRange(from, to, step, h.queryMaxPoints); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	fnName := params.Get("fn")
+	if fnName == "" {
+		fnName = string(query.FuncLast)
+	}
+	fn, err := query.ParseFunc(fnName, metricType)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Для rate нужен предыдущий шаг, чтобы посчитать прирост в первом шаге
+	samples, err := h.ms.GetHistory(string(metricType), id, labels, from.Add(-step), to, r.Context())
+	if err != nil {
+		switch {
+		case errors.Is(err, metrics.ErrInvalidMetricType):
+			http.Error(w, "Invalid metric type", http.StatusBadRequest)
+		default:
+			http.Error(w, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	response := dto.QueryResponse{
+		ID:     id,
+		MType:  string(metricType),
+		Labels: labels,
+		Func:   string(fn),
+		Step:   step.String(),
+		Points: query.Aggregate(samples, from, to, step, fn),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		http.Error(w, "Ошибка получения данных", http.StatusInternalServerError)
+	}
+}
+
+// Предел unix-секунд, которые помещаются в наносекунды int64
+const maxQueryUnixSeconds = math.MaxInt64 / float64(time.Second)
+
+// Время в формате RFC3339 или unix-секундах
+func parseQueryTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		if math.IsNaN(seconds) || math.Abs(seconds) >= maxQueryUnixSeconds {
+			return time.Time{}, fmt.Errorf("unix time %q is out of range", value)
+		}
+		return time.Unix(0, int64(seconds*float64(time.Second))), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/metrics"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryHandler(t *testing.T) {
+	storage := metrics.NewMemStorage()
+	for _, v := range []float64{1, 2, 6} {
+		err := storage.UpdateGauge(&models.GaugeMetric{Name: "HeapAlloc", Type: constants.GaugeName, Value: v}, context.Background())
+		require.NoError(t, err)
+	}
+	handler := NewHandlers(storage)
+
+	req := httptest.NewRequest(http.MethodGet, "/query?id=HeapAlloc&type=gauge&step=1h&fn=max", nil)
+	w := httptest.NewRecorder()
+	handler.QueryHandler(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var response dto.QueryResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, "HeapAlloc", response.ID)
+	assert.Equal(t, "max", response.Func)
+	require.Len(t, response.Points, 1)
+	assert.Equal(t, 6.0, response.Points[0].Value)
+}
+
+func TestQueryHandler_BadRequest(t *testing.T) {
+	handler := NewHandlers(metrics.NewMemStorage())
+
+	urls := []string{
+		"/query?type=gauge",
+		"/query?id=HeapAlloc&type=gauge&fn=rate",
+		"/query?id=HeapAlloc&type=gauge&step=abc",
+		"/query?id=HeapAlloc&type=gauge&from=yesterday",
+		"/query?id=HeapAlloc&type=gauge&from=NaN",
+		"/query?id=HeapAlloc&type=gauge&to=Inf",
+		"/query?id=HeapAlloc&type=gauge&from=-Inf",
+		"/query?id=HeapAlloc&type=gauge&from=-1e19&to=1e19&step=1ns",
+		"/query?id=HeapAlloc&type=gauge&to=9223372037",
+		"/query?id=HeapAlloc&type=unknown",
+	}
+
+	for _, url := range urls {
+		req := httptest.NewRequest(http.MethodGet, url, nil)
+		w := httptest.NewRecorder()
+		handler.QueryHandler(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, url)
+	}
+}
+
+func TestQueryHandler_TooManyPoints(t *testing.T) {
+	query := func(handler *Handler, url string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.QueryHandler(w, httptest.NewRequest(http.MethodGet, url, nil))
+		return w
+	}
+
+	// Час с шагом 100ms — 36001 точка, больше ограничения по умолчанию
+	w := query(NewHandlers(metrics.NewMemStorage()), "/query?id=HeapAlloc&type=gauge&step=100ms")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "too many points requested")
+
+	// Час с шагом в минуту — 61 точка
+	limited := NewHandlersWithOptions(metrics.NewMemStorage(), Options{QueryMaxPoints: 61})
+	assert.Equal(t, http.StatusOK, query(limited, "/query?id=HeapAlloc&type=gauge&step=1m").Code)
+	assert.Equal(t, http.StatusBadRequest, query(limited, "/query?id=HeapAlloc&type=gauge&step=59s").Code)
+}
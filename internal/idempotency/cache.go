@@ -0,0 +1,92 @@
+package idempotency
+
+import "sync"
+
+// Заголовок HTTP-запроса /updates/ с идентификатором батча
+const HeaderName = "X-Batch-ID"
+
+// Число запоминаемых идентификаторов батчей по умолчанию
+const DefaultCapacity = 10000
+
+type state int
+
+const (
+	stateInProgress state = iota
+	stateDone
+)
+
+// Ограниченный кэш идентификаторов применённых батчей.
+// Нужен, чтобы повтор батча после потерянного ответа не увеличил счётчики второй раз,
+// и не даёт одновременно применять один батч. Кэш живёт в памяти процесса; повторы
+// после перезапуска отсекает хранилище (metrics.MetricStorage.UpdateBatchOnce).
+type Cache struct {
+	mu       sync.Mutex
+	capacity int
+	states   map[string]state
+	order    []string
+}
+
+func NewCache(capacity int) *Cache {
+	if capacity < 1 {
+		capacity = DefaultCapacity
+	}
+	return &Cache{
+		capacity: capacity,
+		states:   make(map[string]state),
+	}
+}
+
+// Результат попытки начать обработку батча
+type Status int
+
+const (
+	// Батч новый, его нужно применить и вызвать Done или Abort
+	StatusNew Status = iota
+	// Батч уже применён
+	StatusDuplicate
+	// Батч с тем же идентификатором сейчас обрабатывается
+	StatusInProgress
+)
+
+func (c *Cache) Begin(id string) Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if st, ok := c.states[id]; ok {
+		if st == stateDone {
+			return StatusDuplicate
+		}
+		return StatusInProgress
+	}
+
+	c.states[id] = stateInProgress
+	return StatusNew
+}
+
+// Батч успешно применён
+func (c *Cache) Done(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.states[id]; !ok {
+		return
+	}
+	c.states[id] = stateDone
+	c.order = append(c.order, id)
+
+	// Вытесняем самые старые идентификаторы
+	for len(c.order) > c.capacity {
+		delete(c.states, c.order[0])
+		c.order = c.order[1:]
+	}
+}
+
+// Применение не удалось, батч можно прислать повторно
+func (c *Cache) Abort(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.states[id] == stateInProgress {
+		delete(c.states, id)
+	}
+}
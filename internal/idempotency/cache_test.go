@@ -0,0 +1,39 @@
+package idempotency
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	cache := NewCache(2)
+
+	assert.Equal(t, StatusNew, cache.Begin("a"))
+	assert.Equal(t, StatusInProgress, cache.Begin("a"))
+	cache.Done("a")
+	assert.Equal(t, StatusDuplicate, cache.Begin("a"))
+
+	// После неудачного применения батч можно прислать снова
+	assert.Equal(t, StatusNew, cache.Begin("b"))
+	cache.Abort("b")
+	assert.Equal(t, StatusNew, cache.Begin("b"))
+	cache.Done("b")
+
+	// Abort для уже применённого батча ничего не меняет
+	cache.Abort("a")
+	assert.Equal(t, StatusDuplicate, cache.Begin("a"))
+}
+
+func TestCacheEvictsOldest(t *testing.T) {
+	cache := NewCache(2)
+
+	for _, id := range []string{"a", "b", "c"} {
+		assert.Equal(t, StatusNew, cache.Begin(id))
+		cache.Done(id)
+	}
+
+	assert.Equal(t, StatusNew, cache.Begin("a"))
+	assert.Equal(t, StatusDuplicate, cache.Begin("b"))
+	assert.Equal(t, StatusDuplicate, cache.Begin("c"))
+}
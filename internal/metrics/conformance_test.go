@@ -0,0 +1,67 @@
+package metrics_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/GarikMirzoyan/metricalert/internal/database"
+	"github.com/GarikMirzoyan/metricalert/internal/database/fakedb"
+	"github.com/GarikMirzoyan/metricalert/internal/metrics"
+	"github.com/GarikMirzoyan/metricalert/internal/metrics/storagetest"
+	"github.com/GarikMirzoyan/metricalert/internal/repositories"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemStorageConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, options metrics.Options) metrics.MetricStorage {
+		return metrics.NewMemStorageWithOptions(options)
+	})
+}
+
+// DBStorage поверх MetricRepository с драйвером в памяти, без PostgreSQL
+func TestDBStorageConformanceFake(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, options metrics.Options) metrics.MetricStorage {
+		db := fakedb.New()
+		t.Cleanup(db.Close)
+		return metrics.NewDBStorageWithOptions(repositories.NewMetricRepository(db), options)
+	})
+}
+
+// Проверка на реальной базе: DATABASE_DSN=postgres://... go test ./internal/metrics/
+// Драйвер в памяти не покрывает pgxpool (COPY и слияние через metrics_staging), поэтому
+// в CI проверка обязательна (шаг с PostgreSQL в .github/workflows/mertricstest.yml)
+func TestDBStorageConformance(t *testing.T) {
+	dsn := os.Getenv("DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("DATABASE_DSN не задан")
+	}
+
+	conn, err := database.NewDBConnection(dsn)
+	require.NoError(t, err)
+	t.Cleanup(conn.Close)
+	require.NoError(t, conn.RunMigrations())
+
+	pool, err := database.NewPool(dsn)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	truncate := func(t *testing.T) {
+		_, err := conn.Exec(context.Background(), "TRUNCATE metrics, metrics_history, metric_batches")
+		require.NoError(t, err)
+	}
+
+	t.Run("sql", func(t *testing.T) {
+		storagetest.Run(t, func(t *testing.T, options metrics.Options) metrics.MetricStorage {
+			truncate(t)
+			return metrics.NewDBStorageWithOptions(repositories.NewMetricRepository(conn), options)
+		})
+	})
+
+	t.Run("pgxpool", func(t *testing.T) {
+		storagetest.Run(t, func(t *testing.T, options metrics.Options) metrics.MetricStorage {
+			truncate(t)
+			return metrics.NewDBStorageWithOptions(repositories.NewPgxMetricRepository(pool.Pool), options)
+		})
+	})
+}
@@ -3,10 +3,12 @@ package metrics
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"math/rand/v2"
 	"net/http"
 	"runtime"
@@ -16,8 +18,12 @@ import (
 	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
 	agentConfig "github.com/GarikMirzoyan/metricalert/internal/agent/config"
 	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/encryption"
+	"github.com/GarikMirzoyan/metricalert/internal/hash"
+	"github.com/GarikMirzoyan/metricalert/internal/idempotency"
 	"github.com/GarikMirzoyan/metricalert/internal/models"
 	"github.com/GarikMirzoyan/metricalert/internal/retry"
+	"github.com/GarikMirzoyan/metricalert/internal/sketch"
 )
 
 type Gauge float64
@@ -32,7 +38,43 @@ var (
 	ErrInvalidMetricID    = errors.New("metric ID is required")
 )
 
-func NewMetric(metricType, metricName, metricValue string) (models.Metric, error) {
+// Разбор входящих метрик с настройками сервера; передаётся в HTTP-хендлеры и gRPC-сервер
+type Parser struct {
+	// Границы корзин для гистограмм из отдельных наблюдений (/update/histogram/{name}/{value})
+	histogramBuckets []float64
+}
+
+func NewParser(histogramBuckets []float64) (*Parser, error) {
+	if len(histogramBuckets) == 0 {
+		return nil, fmt.Errorf("%w: no buckets", models.ErrInvalidHistogram)
+	}
+	if err := models.ValidateBuckets(histogramBuckets); err != nil {
+		return nil, err
+	}
+	return &Parser{histogramBuckets: append([]float64(nil), histogramBuckets...)}, nil
+}
+
+// Разбор с корзинами гистограмм по умолчанию
+func NewDefaultParser() *Parser {
+	return &Parser{histogramBuckets: models.DefaultHistogramBuckets}
+}
+
+func NewMetric(metricType, metricName, metricValue string, labels models.Labels) (models.Metric, error) {
+	return NewDefaultParser().NewMetric(metricType, metricName, metricValue, labels)
+}
+
+// Общая валидация входящих метрик для HTTP и gRPC
+func NewMetricFromDTO(metricDTO dto.Metrics) (models.Metric, error) {
+	return NewDefaultParser().NewMetricFromDTO(metricDTO)
+}
+
+func (p *Parser) NewMetric(metricType, metricName, metricValue string, labels models.Labels) (models.Metric, error) {
+	if err := models.ValidateMetricName(metricName); err != nil {
+		return nil, err
+	}
+	if err := labels.Validate(); err != nil {
+		return nil, err
+	}
 
 	switch constants.MetricType(metricType) {
 	case constants.GaugeName:
@@ -40,48 +82,295 @@ func NewMetric(metricType, metricName, metricValue string) (models.Metric, error
 		if err != nil {
 			return nil, fmt.Errorf("invalid gauge value: %w", err)
 		}
-		return &models.GaugeMetric{Name: metricName, Type: constants.GaugeName, Value: val}, nil
+		return &models.GaugeMetric{Name: metricName, Type: constants.GaugeName, Labels: labels, Value: val}, nil
 
 	case constants.CounterName:
 		val, err := strconv.ParseInt(metricValue, 10, 64)
 		if err != nil {
 			return nil, fmt.Errorf("invalid counter value: %w", err)
 		}
-		return &models.CounterMetric{Name: metricName, Type: constants.GaugeName, Value: val}, nil
+		return &models.CounterMetric{Name: metricName, Type: constants.CounterName, Labels: labels, Value: val}, nil
+
+	case constants.HistogramName:
+		val, err := strconv.ParseFloat(metricValue, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid histogram value: %w", err)
+		}
+		return p.newObservation(metricName, labels, val)
+
+	case constants.SummaryName:
+		val, err := strconv.ParseFloat(metricValue, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid summary value: %w", err)
+		}
+		return newSummaryObservation(metricName, labels, val)
+
+	case constants.SetName:
+		// Значение в пути — элемент множества
+		return setFromDTO(dto.Metrics{ID: metricName, Members: []string{metricValue}}, labels)
+
+	case constants.InfoName:
+		return newInfo(metricName, labels, metricValue)
 
 	default:
 		return nil, fmt.Errorf("unknown metric type: %s", metricType)
 	}
 }
 
-func NewMetricFromDTO(metricDTO dto.Metrics) (models.Metric, error) {
+func (p *Parser) NewMetricFromDTO(metricDTO dto.Metrics) (models.Metric, error) {
+	if metricDTO.ID == "" {
+		return nil, ErrInvalidMetricID
+	}
+	if err := models.ValidateMetricName(metricDTO.ID); err != nil {
+		return nil, err
+	}
+
+	labels := models.Labels(metricDTO.Labels)
+	if err := labels.Validate(); err != nil {
+		return nil, err
+	}
+	if len(labels) == 0 {
+		labels = nil
+	}
+
+	observedAt, err := observationTime(metricDTO.Timestamp)
+	if err != nil {
+		return nil, err
+	}
+
 	switch constants.MetricType(metricDTO.MType) {
 	case constants.GaugeName:
 		return &models.GaugeMetric{
-			Name: metricDTO.ID,
-			Type: constants.GaugeName,
+			Name:   metricDTO.ID,
+			Type:   constants.GaugeName,
+			Labels: labels,
 			Value: func() float64 {
 				if metricDTO.Value != nil {
 					return *metricDTO.Value
 				}
 				return 0
 			}(),
+			Timestamp: observedAt,
 		}, nil
 
 	case constants.CounterName:
 		return &models.CounterMetric{
-			Name: metricDTO.ID,
-			Type: constants.CounterName,
+			Name:   metricDTO.ID,
+			Type:   constants.CounterName,
+			Labels: labels,
 			Value: func() int64 {
 				if metricDTO.Delta != nil {
 					return *metricDTO.Delta
 				}
 				return 0
 			}(),
+			Timestamp: observedAt,
 		}, nil
 
+	case constants.HistogramName:
+		return p.histogramFromDTO(metricDTO, labels)
+
+	case constants.SummaryName:
+		return summaryFromDTO(metricDTO, labels)
+
+	case constants.SetName:
+		return setFromDTO(metricDTO, labels)
+
+	case constants.InfoName:
+		var text string
+		if metricDTO.Text != nil {
+			text = *metricDTO.Text
+		}
+		return newInfo(metricDTO.ID, labels, text)
+
 	default:
-		return nil, fmt.Errorf("unknown metric type: %s", metricDTO.MType)
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMetricType, metricDTO.MType)
+	}
+}
+
+// Гистограмма из DTO: либо готовые корзины (buckets, counts, sum, count), либо одно
+// наблюдение в value. Без них получается пустая гистограмма — для запроса /value/.
+func (p *Parser) histogramFromDTO(metricDTO dto.Metrics, labels models.Labels) (models.Metric, error) {
+	if metricDTO.Counts == nil && metricDTO.Buckets == nil {
+		if metricDTO.Value != nil {
+			return p.newObservation(metricDTO.ID, labels, *metricDTO.Value)
+		}
+		return &models.HistogramMetric{Name: metricDTO.ID, Type: constants.HistogramName, Labels: labels}, nil
+	}
+
+	metric := &models.HistogramMetric{
+		Name:    metricDTO.ID,
+		Type:    constants.HistogramName,
+		Labels:  labels,
+		Buckets: metricDTO.Buckets,
+		Counts:  metricDTO.Counts,
+	}
+	if metricDTO.Sum != nil {
+		metric.Sum = *metricDTO.Sum
+	}
+	if metricDTO.Count != nil {
+		metric.Count = *metricDTO.Count
+	} else {
+		for _, count := range metric.Counts {
+			metric.Count += count
+		}
+	}
+
+	if err := metric.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidMetricValue, err)
+	}
+	return metric, nil
+}
+
+// Гистограмма с одним наблюдением в корзинах из настроек сервера
+func (p *Parser) newObservation(name string, labels models.Labels, value float64) (*models.HistogramMetric, error) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, fmt.Errorf("%w: histogram observation %v", ErrInvalidMetricValue, value)
+	}
+	metric := models.NewHistogram(name, labels, p.histogramBuckets)
+	metric.Observe(value)
+	return metric, nil
+}
+
+// Summary из DTO: либо состояние скетча (sketch), например накопленное агентом,
+// либо одно наблюдение в value. Без них — пустая summary для запроса /value/.
+func summaryFromDTO(metricDTO dto.Metrics, labels models.Labels) (models.Metric, error) {
+	if metricDTO.Sketch == nil {
+		if metricDTO.Value != nil {
+			return newSummaryObservation(metricDTO.ID, labels, *metricDTO.Value)
+		}
+		return &models.SummaryMetric{Name: metricDTO.ID, Type: constants.SummaryName, Labels: labels}, nil
+	}
+
+	s, err := sketch.FromState(metricDTO.Sketch)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidMetricValue, err)
+	}
+	return &models.SummaryMetric{Name: metricDTO.ID, Type: constants.SummaryName, Labels: labels, Sketch: s}, nil
+}
+
+func newSummaryObservation(name string, labels models.Labels, value float64) (*models.SummaryMetric, error) {
+	s := sketch.NewDefault()
+	if err := s.Add(value); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidMetricValue, err)
+	}
+	return &models.SummaryMetric{Name: name, Type: constants.SummaryName, Labels: labels, Sketch: s}, nil
+}
+
+// Обновление summary должно содержать хотя бы одно наблюдение
+func validateSummary(metric *models.SummaryMetric) error {
+	if metric.Sketch == nil || metric.Sketch.Count() == 0 {
+		return fmt.Errorf("%w: summary without observations", ErrInvalidMetricValue)
+	}
+	return nil
+}
+
+// Квантиль q summary в текстовом виде, как для /value/summary/{name}?quantile=q
+func SummaryQuantile(metric models.SummaryMetric, q float64) (string, error) {
+	value, err := metric.Sketch.Quantile(q)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidMetricValue, err)
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64), nil
+}
+
+// Значение квантиля q в ответе с состоянием summary
+func WithQuantile(response dto.Metrics, q float64) (dto.Metrics, error) {
+	if response.Sketch == nil {
+		return dto.Metrics{}, ErrInvalidMetricType
+	}
+	s, err := sketch.FromState(response.Sketch)
+	if err != nil {
+		return dto.Metrics{}, err
+	}
+
+	value, err := s.Quantile(q)
+	if err != nil {
+		return dto.Metrics{}, fmt.Errorf("%w: %w", ErrInvalidMetricValue, err)
+	}
+	response.Quantile, response.Value = &q, &value
+	return response, nil
+}
+
+func newInfo(name string, labels models.Labels, value string) (*models.InfoMetric, error) {
+	metric := &models.InfoMetric{Name: name, Type: constants.InfoName, Labels: labels, Value: value}
+	if err := metric.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidMetricValue, err)
+	}
+	return metric, nil
+}
+
+// Set из DTO: элементы (members) и/или состояние HyperLogLog (hll), например
+// собранное агентом. Без них — пустой set для запроса /value/.
+func setFromDTO(metricDTO dto.Metrics, labels models.Labels) (models.Metric, error) {
+	metric := &models.SetMetric{Name: metricDTO.ID, Type: constants.SetName, Labels: labels}
+	if metricDTO.HLL == nil && len(metricDTO.Members) == 0 {
+		return metric, nil
+	}
+
+	metric.HLL = sketch.NewDefaultHLL()
+	if metricDTO.HLL != nil {
+		h, err := sketch.HLLFromState(metricDTO.HLL)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidMetricValue, err)
+		}
+		metric.HLL = h
+	}
+	for _, member := range metricDTO.Members {
+		metric.HLL.Add(member)
+	}
+	return metric, nil
+}
+
+// Обновление set должно содержать хотя бы один элемент
+func validateSet(metric *models.SetMetric) error {
+	if metric.HLL == nil || metric.HLL.Empty() {
+		return fmt.Errorf("%w: set without members", ErrInvalidMetricValue)
+	}
+	return nil
+}
+
+// Полное состояние set в DTO; в count — оценка числа элементов
+func SetDTO(metric models.SetMetric) dto.Metrics {
+	estimate := metric.HLL.Estimate()
+	return dto.Metrics{
+		ID:     metric.Name,
+		MType:  string(constants.SetName),
+		Labels: metric.Labels,
+		Count:  &estimate,
+		HLL:    metric.HLL.State(),
+	}
+}
+
+// Полное состояние summary в DTO; в value — медиана
+func SummaryDTO(metric models.SummaryMetric) dto.Metrics {
+	sum, count := metric.Sketch.Sum(), metric.Sketch.Count()
+	response := dto.Metrics{
+		ID:     metric.Name,
+		MType:  string(constants.SummaryName),
+		Labels: metric.Labels,
+		Sum:    &sum,
+		Count:  &count,
+		Sketch: metric.Sketch.State(),
+	}
+	if median, err := metric.Sketch.Quantile(0.5); err == nil {
+		q := 0.5
+		response.Quantile, response.Value = &q, &median
+	}
+	return response
+}
+
+// Полное состояние гистограммы в DTO
+func HistogramDTO(metric models.HistogramMetric) dto.Metrics {
+	sum, count := metric.Sum, metric.Count
+	return dto.Metrics{
+		ID:      metric.Name,
+		MType:   string(constants.HistogramName),
+		Labels:  metric.Labels,
+		Buckets: metric.Buckets,
+		Counts:  metric.Counts,
+		Sum:     &sum,
+		Count:   &count,
 	}
 }
 
@@ -133,20 +422,18 @@ func SendMetric(metric dto.Metrics, config agentConfig.Config) {
 		return
 	}
 
-	compressedBody, err := compressGzip(body)
+	payload, headers, err := prepareRequestBody(body, config)
 	if err != nil {
-		log.Printf("ошибка сжатия данных: %v", err)
+		log.Printf("ошибка подготовки тела запроса: %v", err)
 		return
 	}
 
-	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(compressedBody))
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(payload))
 	if err != nil {
 		log.Printf("ошибка создания HTTP-запроса: %v", err)
 		return
 	}
-
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Content-Encoding", "gzip")
+	req.Header = headers
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
@@ -160,29 +447,31 @@ func SendMetric(metric dto.Metrics, config agentConfig.Config) {
 	}
 }
 
-func SendBatchMetrics(metrics []dto.Metrics, config agentConfig.Config) {
+// Отправка батча с повторами. batchID передаётся в заголовке, чтобы сервер
+// не применил повторно уже принятый батч; пустой batchID не отправляется.
+// Отмена ctx прерывает ожидание между повторами.
+func SendBatchMetrics(metrics []dto.Metrics, batchID string, config agentConfig.Config, ctx context.Context) error {
 	url := fmt.Sprintf("%s/updates/", config.Address)
 
 	body, err := json.Marshal(metrics)
 	if err != nil {
-		log.Printf("ошибка маршалинга JSON при отправке батча метрик: %v", err)
-		return
+		return fmt.Errorf("ошибка маршалинга JSON при отправке батча метрик: %w", err)
 	}
 
-	compressedBody, err := compressGzip(body)
+	payload, headers, err := prepareRequestBody(body, config)
 	if err != nil {
-		log.Printf("ошибка сжатия тела запроса: %v", err)
-		return
+		return fmt.Errorf("ошибка подготовки тела запроса: %w", err)
+	}
+	if batchID != "" {
+		headers.Set(idempotency.HeaderName, batchID)
 	}
 
-	err = retry.WithBackoff(func() error {
-		req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(compressedBody))
+	return retry.WithBackoff(ctx, func() error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewBuffer(payload))
 		if err != nil {
 			return err // ошибка создания запроса — не retriable
 		}
-
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("Content-Encoding", "gzip")
+		req.Header = headers.Clone()
 
 		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
@@ -190,9 +479,9 @@ func SendBatchMetrics(metrics []dto.Metrics, config agentConfig.Config) {
 		}
 		defer resp.Body.Close()
 
-		if resp.StatusCode >= 500 {
-			// временные ошибки сервера (например, 500, 503)
-			return fmt.Errorf("server error: %s", resp.Status)
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusConflict {
+			// временные ошибки сервера (например, 500, 503) или батч ещё обрабатывается
+			return fmt.Errorf("server error: %s: %w", resp.Status, retry.ErrRetriable)
 		}
 		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
 			// другие ошибки (например, 400) — не повторяем
@@ -201,10 +490,33 @@ func SendBatchMetrics(metrics []dto.Metrics, config agentConfig.Config) {
 
 		return nil // успех
 	})
+}
+
+// Сжатие, шифрование и подпись тела запроса.
+// Подпись считается последней — по телу в том виде, в котором оно уйдёт на сервер.
+func prepareRequestBody(body []byte, config agentConfig.Config) ([]byte, http.Header, error) {
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/json")
+	headers.Set("Content-Encoding", "gzip")
 
+	payload, err := compressGzip(body)
 	if err != nil {
-		log.Printf("не удалось отправить батч метрик после повторов: %v", err)
+		return nil, nil, fmt.Errorf("ошибка сжатия данных: %w", err)
 	}
+
+	if config.PublicKey != nil {
+		payload, err = encryption.Encrypt(config.PublicKey, payload)
+		if err != nil {
+			return nil, nil, fmt.Errorf("ошибка шифрования данных: %w", err)
+		}
+		headers.Set(encryption.HeaderName, encryption.HeaderValue)
+	}
+
+	if config.Key != "" {
+		headers.Set(hash.HeaderName, hash.Sign(payload, config.Key))
+	}
+
+	return payload, headers, nil
 }
 
 // Функция для сжатия данных в формате gzip
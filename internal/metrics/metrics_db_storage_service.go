@@ -2,22 +2,38 @@ package metrics
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
 	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
 	"github.com/GarikMirzoyan/metricalert/internal/constants"
 	"github.com/GarikMirzoyan/metricalert/internal/models"
 	"github.com/GarikMirzoyan/metricalert/internal/repositories"
+	"github.com/GarikMirzoyan/metricalert/internal/sketch"
+	"github.com/GarikMirzoyan/metricalert/internal/utils"
 )
 
 type DBStorage struct {
-	metricRepository *repositories.MetricRepository
+	metricRepository repositories.Repository
+	timestamps       TimestampPolicy
+	batchTTL         time.Duration
+	historyRetention time.Duration
 }
 
-func NewDBStorage(metricRepository *repositories.MetricRepository) *DBStorage {
+func NewDBStorage(metricRepository repositories.Repository) *DBStorage {
+	return NewDBStorageWithOptions(metricRepository, Options{})
+}
+
+// HistorySize не используется: история в базе ограничена только сроком хранения
+func NewDBStorageWithOptions(metricRepository repositories.Repository, options Options) *DBStorage {
 	return &DBStorage{
 		metricRepository: metricRepository,
+		timestamps:       options.Timestamps,
+		batchTTL:         options.BatchTTL,
+		historyRetention: options.HistoryRetention,
 	}
 }
 
@@ -27,8 +43,16 @@ func (ms *DBStorage) Update(metric models.Metric, ctx context.Context) error {
 		return ms.UpdateGauge(m, ctx)
 	case *models.CounterMetric:
 		return ms.UpdateCounter(m, ctx)
+	case *models.HistogramMetric:
+		return ms.UpdateHistogram(m, ctx)
+	case *models.SummaryMetric:
+		return ms.UpdateSummary(m, ctx)
+	case *models.SetMetric:
+		return ms.UpdateSet(m, ctx)
+	case *models.InfoMetric:
+		return ms.UpdateInfo(m, ctx)
 	default:
-		return fmt.Errorf("invalid metric type")
+		return ErrInvalidMetricType
 	}
 }
 
@@ -40,36 +64,50 @@ func (ms *DBStorage) UpdateJSON(metric models.Metric, ctx context.Context) (dto.
 
 	// Создание ответа
 	response := dto.Metrics{
-		ID:    metric.GetName(),
-		MType: string(metric.GetType()),
+		ID:     metric.GetName(),
+		MType:  string(metric.GetType()),
+		Labels: metric.GetLabels(),
 	}
 
 	switch m := metric.(type) {
 	case *models.GaugeMetric:
-		value, ok := metric.GetValue().(float64)
-		if !ok {
-			return dto.Metrics{}, fmt.Errorf("invalid type assertion: expected *float64")
+		if err := ms.UpdateGauge(m, ctx); err != nil {
+			return dto.Metrics{}, err
 		}
+		response.Value = &m.Value
+		response.Timestamp = timestampMillis(m.Timestamp)
 
-		err := ms.metricRepository.Update(m, ctx)
-		if err != nil {
+	case *models.CounterMetric:
+		// После обновления в m.Value накопленное значение, как и в MemStorage
+		if err := ms.UpdateCounter(m, ctx); err != nil {
 			return dto.Metrics{}, err
 		}
+		response.Delta = &m.Value
+		response.Timestamp = timestampMillis(m.Timestamp)
 
-		response.Value = &value
+	case *models.HistogramMetric:
+		if err := ms.UpdateHistogram(m, ctx); err != nil {
+			return dto.Metrics{}, err
+		}
+		response = HistogramDTO(*m)
 
-	case *models.CounterMetric:
-		delta, ok := metric.GetValue().(int64)
-		if !ok {
-			return dto.Metrics{}, fmt.Errorf("invalid type assertion: expected *int64")
+	case *models.SummaryMetric:
+		if err := ms.UpdateSummary(m, ctx); err != nil {
+			return dto.Metrics{}, err
 		}
+		response = SummaryDTO(*m)
 
-		err := ms.metricRepository.Update(m, ctx)
-		if err != nil {
+	case *models.SetMetric:
+		if err := ms.UpdateSet(m, ctx); err != nil {
 			return dto.Metrics{}, err
 		}
+		response = SetDTO(*m)
 
-		response.Delta = &delta
+	case *models.InfoMetric:
+		if err := ms.UpdateInfo(m, ctx); err != nil {
+			return dto.Metrics{}, err
+		}
+		response.Text = &m.Value
 
 	default:
 		return dto.Metrics{}, ErrInvalidMetricType
@@ -78,21 +116,51 @@ func (ms *DBStorage) UpdateJSON(metric models.Metric, ctx context.Context) (dto.
 	return response, nil
 }
 
-func (ms *DBStorage) GetValue(metricType, metricName string, ctx context.Context) (string, error) {
+// Значение метрики в текстовом виде; для histogram — число наблюдений, для summary — медиана,
+// для set — оценка числа элементов
+func (ms *DBStorage) GetValue(metricType, metricName string, labels models.Labels, ctx context.Context) (string, error) {
 	switch constants.MetricType(metricType) {
 	case constants.GaugeName:
-		value, err := ms.metricRepository.GetGaugeValue(metricName, ctx)
+		metric, err := ms.GetGauge(metricName, labels, ctx)
 		if err != nil {
-			return "", fmt.Errorf("произошла ошибка при получении Gauge: %w", err)
+			return "", err
 		}
-		return fmt.Sprintf("%v", value), nil
+		return utils.FormatNumber(metric.Value), nil
 
 	case constants.CounterName:
-		value, err := ms.metricRepository.GetCounterValue(metricName, ctx)
+		metric, err := ms.GetCounter(metricName, labels, ctx)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(metric.Value, 10), nil
+
+	case constants.HistogramName:
+		metric, err := ms.GetHistogram(metricName, labels, ctx)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatUint(metric.Count, 10), nil
+
+	case constants.SummaryName:
+		metric, err := ms.GetSummary(metricName, labels, ctx)
+		if err != nil {
+			return "", err
+		}
+		return SummaryQuantile(metric, 0.5)
+
+	case constants.SetName:
+		metric, err := ms.GetSet(metricName, labels, ctx)
 		if err != nil {
-			return "", fmt.Errorf("произошла ошибка при получении Counter: %w", err)
+			return "", err
 		}
-		return fmt.Sprintf("%v", value), nil
+		return strconv.FormatUint(metric.HLL.Estimate(), 10), nil
+
+	case constants.InfoName:
+		metric, err := ms.GetInfo(metricName, labels, ctx)
+		if err != nil {
+			return "", err
+		}
+		return metric.Value, nil
 
 	default:
 		return "", ErrInvalidMetricType
@@ -102,13 +170,14 @@ func (ms *DBStorage) GetValue(metricType, metricName string, ctx context.Context
 func (ms *DBStorage) GetJSON(metric models.Metric, ctx context.Context) (dto.Metrics, error) {
 	// Создание ответа
 	response := dto.Metrics{
-		ID:    metric.GetName(),
-		MType: string(metric.GetType()),
+		ID:     metric.GetName(),
+		MType:  string(metric.GetType()),
+		Labels: metric.GetLabels(),
 	}
 
 	switch m := metric.(type) {
 	case *models.GaugeMetric:
-		metric, err := ms.GetGauge(m.GetName(), ctx)
+		metric, err := ms.GetGauge(m.GetName(), m.GetLabels(), ctx)
 
 		if err != nil {
 			return dto.Metrics{}, err
@@ -123,7 +192,7 @@ func (ms *DBStorage) GetJSON(metric models.Metric, ctx context.Context) (dto.Met
 		return response, nil
 
 	case *models.CounterMetric:
-		metric, err := ms.GetCounter(m.GetName(), ctx)
+		metric, err := ms.GetCounter(m.GetName(), m.GetLabels(), ctx)
 
 		if err != nil {
 			return dto.Metrics{}, err
@@ -135,6 +204,39 @@ func (ms *DBStorage) GetJSON(metric models.Metric, ctx context.Context) (dto.Met
 		}
 		response.Delta = &delta
 
+		return response, nil
+
+	case *models.HistogramMetric:
+		metric, err := ms.GetHistogram(m.GetName(), m.GetLabels(), ctx)
+		if err != nil {
+			return dto.Metrics{}, err
+		}
+
+		return HistogramDTO(metric), nil
+
+	case *models.SummaryMetric:
+		metric, err := ms.GetSummary(m.GetName(), m.GetLabels(), ctx)
+		if err != nil {
+			return dto.Metrics{}, err
+		}
+
+		return SummaryDTO(metric), nil
+
+	case *models.SetMetric:
+		metric, err := ms.GetSet(m.GetName(), m.GetLabels(), ctx)
+		if err != nil {
+			return dto.Metrics{}, err
+		}
+
+		return SetDTO(metric), nil
+
+	case *models.InfoMetric:
+		metric, err := ms.GetInfo(m.GetName(), m.GetLabels(), ctx)
+		if err != nil {
+			return dto.Metrics{}, err
+		}
+		response.Text = &metric.Value
+
 		return response, nil
 	default:
 		return dto.Metrics{}, ErrInvalidMetricType
@@ -143,57 +245,132 @@ func (ms *DBStorage) GetJSON(metric models.Metric, ctx context.Context) (dto.Met
 
 func (ms *DBStorage) UpdateGauge(metric *models.GaugeMetric, ctx context.Context) error {
 	if metric.Name == "" {
-		return fmt.Errorf("gauge metric name is empty")
+		return ErrInvalidMetricID
+	}
+
+	// Более старое наблюдение не применяется — репозиторий запишет в metric сохранённое значение
+	if err := ms.resolveTimestamps([]models.Metric{metric}); err != nil {
+		return err
 	}
 	return ms.metricRepository.Update(metric, ctx)
 }
 
 func (ms *DBStorage) UpdateCounter(metric *models.CounterMetric, ctx context.Context) error {
 	if metric.Name == "" {
-		return fmt.Errorf("counter metric name is empty")
+		return ErrInvalidMetricID
 	}
 
-	err := ms.metricRepository.Update(metric, ctx)
-	if err != nil {
+	// Репозиторий запишет в metric накопленное значение
+	if err := ms.resolveTimestamps([]models.Metric{metric}); err != nil {
 		return err
 	}
+	return ms.metricRepository.Update(metric, ctx)
+}
+
+func (ms *DBStorage) UpdateHistogram(metric *models.HistogramMetric, ctx context.Context) error {
+	if metric.Name == "" {
+		return ErrInvalidMetricID
+	}
+	if err := metric.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidMetricValue, err)
+	}
+
+	return invalidState(ms.metricRepository.Update(metric, ctx))
+}
 
-	currentValue, err := ms.metricRepository.GetCounterValue(metric.Name, ctx)
-	if err != nil && !errors.Is(err, ErrMetricNotFound) {
+func (ms *DBStorage) UpdateSummary(metric *models.SummaryMetric, ctx context.Context) error {
+	if metric.Name == "" {
+		return ErrInvalidMetricID
+	}
+	if err := validateSummary(metric); err != nil {
 		return err
 	}
 
-	metric.Value = currentValue
+	return invalidState(ms.metricRepository.Update(metric, ctx))
+}
 
-	return nil
+func (ms *DBStorage) UpdateSet(metric *models.SetMetric, ctx context.Context) error {
+	if metric.Name == "" {
+		return ErrInvalidMetricID
+	}
+	if err := validateSet(metric); err != nil {
+		return err
+	}
+
+	return invalidState(ms.metricRepository.Update(metric, ctx))
 }
 
-func (ms *DBStorage) GetGauge(name string, ctx context.Context) (models.GaugeMetric, error) {
-	val, err := ms.metricRepository.GetGaugeValue(name, ctx)
+func (ms *DBStorage) UpdateInfo(metric *models.InfoMetric, ctx context.Context) error {
+	if metric.Name == "" {
+		return ErrInvalidMetricID
+	}
+	if err := metric.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidMetricValue, err)
+	}
+
+	return ms.metricRepository.Update(metric, ctx)
+}
+
+func (ms *DBStorage) GetGauge(name string, labels models.Labels, ctx context.Context) (models.GaugeMetric, error) {
+	val, err := ms.metricRepository.GetGaugeValue(name, labels, ctx)
 	if err != nil {
-		return models.GaugeMetric{}, fmt.Errorf("get gauge failed: %w", ErrMetricNotFound)
+		return models.GaugeMetric{}, fmt.Errorf("get gauge failed: %w", notFound(err))
 	}
 
 	return models.GaugeMetric{
-		Name:  name,
-		Type:  constants.GaugeName,
-		Value: val,
+		Name:   name,
+		Type:   constants.GaugeName,
+		Labels: labels,
+		Value:  val,
 	}, nil
 }
 
-func (ms *DBStorage) GetCounter(name string, ctx context.Context) (models.CounterMetric, error) {
-	val, err := ms.metricRepository.GetCounterValue(name, ctx)
+func (ms *DBStorage) GetCounter(name string, labels models.Labels, ctx context.Context) (models.CounterMetric, error) {
+	val, err := ms.metricRepository.GetCounterValue(name, labels, ctx)
 	if err != nil {
-		return models.CounterMetric{}, fmt.Errorf("get counter failed: %w", ErrMetricNotFound)
+		return models.CounterMetric{}, fmt.Errorf("get counter failed: %w", notFound(err))
 	}
 
 	return models.CounterMetric{
-		Name:  name,
-		Type:  constants.CounterName,
-		Value: val,
+		Name:   name,
+		Type:   constants.CounterName,
+		Labels: labels,
+		Value:  val,
 	}, nil
 }
 
+func (ms *DBStorage) GetHistogram(name string, labels models.Labels, ctx context.Context) (models.HistogramMetric, error) {
+	metric, err := ms.metricRepository.GetHistogram(name, labels, ctx)
+	if err != nil {
+		return models.HistogramMetric{}, fmt.Errorf("get histogram failed: %w", notFound(err))
+	}
+	return metric, nil
+}
+
+func (ms *DBStorage) GetSummary(name string, labels models.Labels, ctx context.Context) (models.SummaryMetric, error) {
+	metric, err := ms.metricRepository.GetSummary(name, labels, ctx)
+	if err != nil {
+		return models.SummaryMetric{}, fmt.Errorf("get summary failed: %w", notFound(err))
+	}
+	return metric, nil
+}
+
+func (ms *DBStorage) GetSet(name string, labels models.Labels, ctx context.Context) (models.SetMetric, error) {
+	metric, err := ms.metricRepository.GetSet(name, labels, ctx)
+	if err != nil {
+		return models.SetMetric{}, fmt.Errorf("get set failed: %w", notFound(err))
+	}
+	return metric, nil
+}
+
+func (ms *DBStorage) GetInfo(name string, labels models.Labels, ctx context.Context) (models.InfoMetric, error) {
+	metric, err := ms.metricRepository.GetInfo(name, labels, ctx)
+	if err != nil {
+		return models.InfoMetric{}, fmt.Errorf("get info failed: %w", notFound(err))
+	}
+	return metric, nil
+}
+
 func (ms *DBStorage) GetAll(ctx context.Context) (map[string]models.GaugeMetric, map[string]models.CounterMetric, error) {
 	gauges, counters, err := ms.metricRepository.GetAllMetrics(ctx)
 	if err != nil {
@@ -203,35 +380,145 @@ func (ms *DBStorage) GetAll(ctx context.Context) (map[string]models.GaugeMetric,
 	return gauges, counters, nil
 }
 
-func (ms *DBStorage) UpdateBatchJSON(metrics []models.Metric, ctx context.Context) (map[string]dto.Metrics, error) {
-	responses := make(map[string]dto.Metrics)
+func (ms *DBStorage) GetAllHistograms(ctx context.Context) (map[string]models.HistogramMetric, error) {
+	return ms.metricRepository.GetAllHistograms(ctx)
+}
 
-	if err := ms.metricRepository.BatchUpdate(metrics, ctx); err != nil {
-		return nil, err
+func (ms *DBStorage) GetAllSummaries(ctx context.Context) (map[string]models.SummaryMetric, error) {
+	return ms.metricRepository.GetAllSummaries(ctx)
+}
+
+func (ms *DBStorage) GetAllSets(ctx context.Context) (map[string]models.SetMetric, error) {
+	return ms.metricRepository.GetAllSets(ctx)
+}
+
+func (ms *DBStorage) GetAllInfos(ctx context.Context) (map[string]models.InfoMetric, error) {
+	return ms.metricRepository.GetAllInfos(ctx)
+}
+
+func (ms *DBStorage) GetHistory(metricType, name string, labels models.Labels, from, to time.Time, ctx context.Context) ([]models.Sample, error) {
+	switch constants.MetricType(metricType) {
+	case constants.GaugeName, constants.CounterName:
+		return ms.metricRepository.GetHistory(constants.MetricType(metricType), name, labels, from, to, ctx)
+	default:
+		return nil, ErrInvalidMetricType
 	}
+}
 
+func (ms *DBStorage) PruneHistory(ctx context.Context) error {
+	if ms.historyRetention <= 0 {
+		return nil
+	}
+	return ms.metricRepository.DeleteHistoryBefore(time.Now().Add(-ms.historyRetention), ctx)
+}
+
+func (ms *DBStorage) UpdateBatchJSON(metrics []models.Metric, ctx context.Context) (map[string]dto.Metrics, error) {
+	return ms.UpdateBatchOnce("", metrics, ctx)
+}
+
+func (ms *DBStorage) UpdateBatchOnce(batchID string, metrics []models.Metric, ctx context.Context) (map[string]dto.Metrics, error) {
 	for _, metric := range metrics {
-		id := metric.GetName()
-		if id == "" {
+		if metric.GetName() == "" {
 			return nil, ErrInvalidMetricID
 		}
+		switch m := metric.(type) {
+		case *models.GaugeMetric, *models.CounterMetric:
+		case *models.HistogramMetric:
+			if err := m.Validate(); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidMetricValue, err)
+			}
+		case *models.SummaryMetric:
+			if err := validateSummary(m); err != nil {
+				return nil, err
+			}
+		case *models.SetMetric:
+			if err := validateSet(m); err != nil {
+				return nil, err
+			}
+		case *models.InfoMetric:
+			if err := m.Validate(); err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrInvalidMetricValue, err)
+			}
+		default:
+			return nil, ErrInvalidMetricType
+		}
+	}
 
+	if err := ms.resolveTimestamps(metrics); err != nil {
+		return nil, err
+	}
+	if err := ms.metricRepository.BatchUpdateOnce(batchID, ms.batchTTL, metrics, ctx); err != nil {
+		if errors.Is(err, repositories.ErrDuplicateBatch) {
+			return nil, ErrDuplicateBatch
+		}
+		return nil, invalidState(err)
+	}
+
+	responses := make(map[string]dto.Metrics)
+	for _, metric := range metrics {
 		response := dto.Metrics{
-			ID:    id,
-			MType: string(metric.GetType()),
+			ID:     metric.GetName(),
+			MType:  string(metric.GetType()),
+			Labels: metric.GetLabels(),
 		}
 
 		switch m := metric.(type) {
 		case *models.GaugeMetric:
 			response.Value = &m.Value
+			response.Timestamp = timestampMillis(m.Timestamp)
 		case *models.CounterMetric:
 			response.Delta = &m.Value
-		default:
-			return nil, ErrInvalidMetricType
+			response.Timestamp = timestampMillis(m.Timestamp)
+		case *models.HistogramMetric:
+			response = HistogramDTO(*m)
+		case *models.SummaryMetric:
+			response = SummaryDTO(*m)
+		case *models.SetMetric:
+			response = SetDTO(*m)
+		case *models.InfoMetric:
+			response.Text = &m.Value
 		}
 
-		responses[id] = response
+		responses[models.TypedSeriesKey(string(metric.GetType()), metric.GetName(), metric.GetLabels())] = response
 	}
 
 	return responses, nil
 }
+
+// Время наблюдения gauge и counter по окну хранилища; без метки — время получения,
+// с точностью до миллисекунд, как в MemStorage. Метка вне окна в режиме
+// TimestampReject отклоняет весь батч до записи в базу.
+func (ms *DBStorage) resolveTimestamps(metrics []models.Metric) error {
+	now := time.Now().Truncate(time.Millisecond)
+	for _, metric := range metrics {
+		var err error
+		switch m := metric.(type) {
+		case *models.GaugeMetric:
+			m.Timestamp, err = ms.timestamps.observedAt(m.Timestamp, now)
+		case *models.CounterMetric:
+			m.Timestamp, err = ms.timestamps.observedAt(m.Timestamp, now)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Отсутствие строки в базе — ErrMetricNotFound; остальные ошибки возвращаются как есть.
+// pgx.ErrNoRows тоже распознаётся как sql.ErrNoRows.
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrMetricNotFound
+	}
+	return err
+}
+
+// Несовпадение корзин histogram или параметров скетчей summary и set при слиянии —
+// ошибка входных данных, а не базы
+func invalidState(err error) error {
+	if errors.Is(err, models.ErrInvalidHistogram) || errors.Is(err, sketch.ErrIncompatible) {
+		return fmt.Errorf("%w: %w", ErrInvalidMetricValue, err)
+	}
+	return err
+}
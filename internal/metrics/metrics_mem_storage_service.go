@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strconv"
 	"sync"
 	"time"
@@ -15,20 +16,69 @@ import (
 	"github.com/GarikMirzoyan/metricalert/internal/constants"
 	"github.com/GarikMirzoyan/metricalert/internal/models"
 	serverConfig "github.com/GarikMirzoyan/metricalert/internal/server/config"
+	"github.com/GarikMirzoyan/metricalert/internal/sketch"
 	"github.com/GarikMirzoyan/metricalert/internal/utils"
 	"go.uber.org/zap"
 )
 
+// Размер истории одной метрики по умолчанию
+const DefaultHistorySize = 1000
+
+// Период записи снимка в режиме синхронной записи
+const compactionInterval = time.Minute
+
+// Подменяется в тестах для имитации сбоя между записью временного файла и переименованием
+var renameFile = os.Rename
+
 type MemStorage struct {
-	gauges   map[string]models.GaugeMetric
-	counters map[string]models.CounterMetric
-	mu       sync.Mutex
+	gauges         map[string]models.GaugeMetric
+	counters       map[string]models.CounterMetric
+	histograms     map[string]models.HistogramMetric
+	summaries      map[string]models.SummaryMetric
+	sets           map[string]models.SetMetric
+	infos          map[string]models.InfoMetric
+	gaugeHistory   map[string]*ringBuffer
+	counterHistory map[string]*ringBuffer
+	historySize    int
+	// Срок хранения истории; 0 — ограничение только числом значений
+	historyRetention time.Duration
+	timestamps       TimestampPolicy
+	// Идентификаторы применённых батчей и время применения; сохраняются в журнале и снимке
+	batches  map[string]time.Time
+	batchTTL time.Duration
+	// Журнал обновлений после последнего снимка; nil, если файл метрик не используется
+	wal *wal
+	mu  sync.Mutex
 }
 
 func NewMemStorage() *MemStorage {
+	return NewMemStorageWithHistory(DefaultHistorySize)
+}
+
+// Хранилище, в котором для каждой метрики хранится не более historySize последних значений
+func NewMemStorageWithHistory(historySize int) *MemStorage {
+	return NewMemStorageWithOptions(Options{HistorySize: historySize})
+}
+
+// Нулевой HistorySize — DefaultHistorySize
+func NewMemStorageWithOptions(options Options) *MemStorage {
+	if options.HistorySize < 1 {
+		options.HistorySize = DefaultHistorySize
+	}
 	return &MemStorage{
-		gauges:   make(map[string]models.GaugeMetric),
-		counters: make(map[string]models.CounterMetric),
+		gauges:           make(map[string]models.GaugeMetric),
+		counters:         make(map[string]models.CounterMetric),
+		histograms:       make(map[string]models.HistogramMetric),
+		summaries:        make(map[string]models.SummaryMetric),
+		sets:             make(map[string]models.SetMetric),
+		infos:            make(map[string]models.InfoMetric),
+		gaugeHistory:     make(map[string]*ringBuffer),
+		counterHistory:   make(map[string]*ringBuffer),
+		historySize:      options.HistorySize,
+		historyRetention: options.HistoryRetention,
+		timestamps:       options.Timestamps,
+		batches:          make(map[string]time.Time),
+		batchTTL:         options.BatchTTL,
 	}
 }
 
@@ -38,8 +88,16 @@ func (ms *MemStorage) Update(metric models.Metric, ctx context.Context) error {
 		return ms.UpdateGauge(m, ctx)
 	case *models.CounterMetric:
 		return ms.UpdateCounter(m, ctx)
+	case *models.HistogramMetric:
+		return ms.UpdateHistogram(m, ctx)
+	case *models.SummaryMetric:
+		return ms.UpdateSummary(m, ctx)
+	case *models.SetMetric:
+		return ms.UpdateSet(m, ctx)
+	case *models.InfoMetric:
+		return ms.UpdateInfo(m, ctx)
 	default:
-		return fmt.Errorf("invalid metric type")
+		return ErrInvalidMetricType
 	}
 }
 
@@ -51,8 +109,9 @@ func (ms *MemStorage) UpdateJSON(metric models.Metric, ctx context.Context) (dto
 
 	// Создание ответа
 	response := dto.Metrics{
-		ID:    metric.GetName(),
-		MType: string(metric.GetType()),
+		ID:     metric.GetName(),
+		MType:  string(metric.GetType()),
+		Labels: metric.GetLabels(),
 	}
 
 	switch m := metric.(type) {
@@ -65,6 +124,7 @@ func (ms *MemStorage) UpdateJSON(metric models.Metric, ctx context.Context) (dto
 			return dto.Metrics{}, fmt.Errorf("invalid type assertion: expected *float64")
 		}
 		response.Value = &value
+		response.Timestamp = timestampMillis(m.Timestamp)
 
 	case *models.CounterMetric:
 		if err := ms.UpdateCounter(m, ctx); err != nil {
@@ -75,6 +135,31 @@ func (ms *MemStorage) UpdateJSON(metric models.Metric, ctx context.Context) (dto
 			return dto.Metrics{}, fmt.Errorf("invalid type assertion: expected *int64")
 		}
 		response.Delta = &delta
+		response.Timestamp = timestampMillis(m.Timestamp)
+
+	case *models.HistogramMetric:
+		if err := ms.UpdateHistogram(m, ctx); err != nil {
+			return dto.Metrics{}, fmt.Errorf("failed to update histogram: %w", err)
+		}
+		response = HistogramDTO(*m)
+
+	case *models.SummaryMetric:
+		if err := ms.UpdateSummary(m, ctx); err != nil {
+			return dto.Metrics{}, fmt.Errorf("failed to update summary: %w", err)
+		}
+		response = SummaryDTO(*m)
+
+	case *models.SetMetric:
+		if err := ms.UpdateSet(m, ctx); err != nil {
+			return dto.Metrics{}, fmt.Errorf("failed to update set: %w", err)
+		}
+		response = SetDTO(*m)
+
+	case *models.InfoMetric:
+		if err := ms.UpdateInfo(m, ctx); err != nil {
+			return dto.Metrics{}, fmt.Errorf("failed to update info: %w", err)
+		}
+		response.Text = &m.Value
 
 	default:
 		return dto.Metrics{}, ErrInvalidMetricType
@@ -83,22 +168,52 @@ func (ms *MemStorage) UpdateJSON(metric models.Metric, ctx context.Context) (dto
 	return response, nil
 }
 
-func (ms *MemStorage) GetValue(metricType, metricName string, ctx context.Context) (string, error) {
+// Значение метрики в текстовом виде; для histogram — число наблюдений, для summary — медиана,
+// для set — оценка числа элементов
+func (ms *MemStorage) GetValue(metricType, metricName string, labels models.Labels, ctx context.Context) (string, error) {
 	switch constants.MetricType(metricType) {
 	case constants.GaugeName:
-		metric, err := ms.GetGauge(metricName, ctx)
+		metric, err := ms.GetGauge(metricName, labels, ctx)
 		if err != nil {
 			return "", err
 		}
 		return utils.FormatNumber(metric.Value), nil
 
 	case constants.CounterName:
-		metric, err := ms.GetCounter(metricName, ctx)
+		metric, err := ms.GetCounter(metricName, labels, ctx)
 		if err != nil {
 			return "", err
 		}
 		return strconv.FormatInt(metric.Value, 10), nil
 
+	case constants.HistogramName:
+		metric, err := ms.GetHistogram(metricName, labels, ctx)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatUint(metric.Count, 10), nil
+
+	case constants.SummaryName:
+		metric, err := ms.GetSummary(metricName, labels, ctx)
+		if err != nil {
+			return "", err
+		}
+		return SummaryQuantile(metric, 0.5)
+
+	case constants.SetName:
+		metric, err := ms.GetSet(metricName, labels, ctx)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatUint(metric.HLL.Estimate(), 10), nil
+
+	case constants.InfoName:
+		metric, err := ms.GetInfo(metricName, labels, ctx)
+		if err != nil {
+			return "", err
+		}
+		return metric.Value, nil
+
 	default:
 		return "", ErrInvalidMetricType
 	}
@@ -107,13 +222,14 @@ func (ms *MemStorage) GetValue(metricType, metricName string, ctx context.Contex
 func (ms *MemStorage) GetJSON(metric models.Metric, ctx context.Context) (dto.Metrics, error) {
 	// Создание ответа
 	response := dto.Metrics{
-		ID:    metric.GetName(),
-		MType: string(metric.GetType()),
+		ID:     metric.GetName(),
+		MType:  string(metric.GetType()),
+		Labels: metric.GetLabels(),
 	}
 
 	switch m := metric.(type) {
 	case *models.GaugeMetric:
-		metric, err := ms.GetGauge(m.GetName(), ctx)
+		metric, err := ms.GetGauge(m.GetName(), m.GetLabels(), ctx)
 		if err != nil {
 			return dto.Metrics{}, err
 		}
@@ -129,7 +245,7 @@ func (ms *MemStorage) GetJSON(metric models.Metric, ctx context.Context) (dto.Me
 		return response, nil
 
 	case *models.CounterMetric:
-		metric, err := ms.GetCounter(m.GetName(), ctx)
+		metric, err := ms.GetCounter(m.GetName(), m.GetLabels(), ctx)
 
 		if err != nil {
 			return dto.Metrics{}, err
@@ -142,6 +258,39 @@ func (ms *MemStorage) GetJSON(metric models.Metric, ctx context.Context) (dto.Me
 		}
 		response.Delta = &delta
 
+		return response, nil
+
+	case *models.HistogramMetric:
+		metric, err := ms.GetHistogram(m.GetName(), m.GetLabels(), ctx)
+		if err != nil {
+			return dto.Metrics{}, err
+		}
+
+		return HistogramDTO(metric), nil
+
+	case *models.SummaryMetric:
+		metric, err := ms.GetSummary(m.GetName(), m.GetLabels(), ctx)
+		if err != nil {
+			return dto.Metrics{}, err
+		}
+
+		return SummaryDTO(metric), nil
+
+	case *models.SetMetric:
+		metric, err := ms.GetSet(m.GetName(), m.GetLabels(), ctx)
+		if err != nil {
+			return dto.Metrics{}, err
+		}
+
+		return SetDTO(metric), nil
+
+	case *models.InfoMetric:
+		metric, err := ms.GetInfo(m.GetName(), m.GetLabels(), ctx)
+		if err != nil {
+			return dto.Metrics{}, err
+		}
+		response.Text = &metric.Value
+
 		return response, nil
 	default:
 		return dto.Metrics{}, ErrInvalidMetricType
@@ -150,47 +299,497 @@ func (ms *MemStorage) GetJSON(metric models.Metric, ctx context.Context) (dto.Me
 
 func (ms *MemStorage) UpdateGauge(metric *models.GaugeMetric, ctx context.Context) error {
 	if metric.Name == "" {
-		return fmt.Errorf("metric name is empty")
+		return ErrInvalidMetricID
 	}
 
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	ms.gauges[metric.Name] = *metric
-	return nil
+	return ms.commitLocked([]models.Metric{metric})
 }
 
 func (ms *MemStorage) UpdateCounter(metric *models.CounterMetric, ctx context.Context) error {
 	if metric.Name == "" {
-		return fmt.Errorf("metric name is empty")
+		return ErrInvalidMetricID
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.commitLocked([]models.Metric{metric})
+}
+
+func (ms *MemStorage) UpdateHistogram(metric *models.HistogramMetric, ctx context.Context) error {
+	if metric.Name == "" {
+		return ErrInvalidMetricID
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.commitLocked([]models.Metric{metric})
+}
+
+func (ms *MemStorage) UpdateSummary(metric *models.SummaryMetric, ctx context.Context) error {
+	if metric.Name == "" {
+		return ErrInvalidMetricID
 	}
 
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	if existing, exists := ms.counters[metric.Name]; exists {
-		metric.Value += existing.Value
+	return ms.commitLocked([]models.Metric{metric})
+}
+
+func (ms *MemStorage) UpdateSet(metric *models.SetMetric, ctx context.Context) error {
+	if metric.Name == "" {
+		return ErrInvalidMetricID
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.commitLocked([]models.Metric{metric})
+}
+
+func (ms *MemStorage) UpdateInfo(metric *models.InfoMetric, ctx context.Context) error {
+	if metric.Name == "" {
+		return ErrInvalidMetricID
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	return ms.commitLocked([]models.Metric{metric})
+}
+
+// Применение обновлений; вызывается под ms.mu.
+// Итоговые значения сначала попадают в журнал и только потом в память, поэтому
+// в режиме синхронной записи подтверждённое обновление переживает перезапуск.
+// Для counter в metric.Value записывается накопленное значение, histogram,
+// summary и set заменяются итоговым состоянием.
+// Gauge с более ранним временем наблюдения, чем у текущего значения, не применяется:
+// в metric записываются текущие значение и время.
+func (ms *MemStorage) commitLocked(metrics []models.Metric) error {
+	return ms.commitBatchLocked(metrics, "")
+}
+
+// Применение батча; непустой batchID записывается в журнал вместе с метриками
+func (ms *MemStorage) commitBatchLocked(metrics []models.Metric, batchID string) error {
+	// Время получения для метрик без метки; в журнале время хранится в миллисекундах
+	now := time.Now().Truncate(time.Millisecond)
+	records := make([]dto.Metrics, 0, len(metrics))
+	// Метрики, для которых есть запись в records, в том же порядке
+	applied := make([]models.Metric, 0, len(metrics))
+	var stale []*models.GaugeMetric
+	observed := make(map[string]time.Time)
+	totals := make(map[string]int64)
+	histograms := make(map[string]models.HistogramMetric)
+	summaries := make(map[string]models.SummaryMetric)
+	sets := make(map[string]models.SetMetric)
+
+	for _, metric := range metrics {
+		switch m := metric.(type) {
+		case *models.GaugeMetric:
+			key := models.SeriesKey(m.Name, m.Labels)
+			observedAt, err := ms.timestamps.observedAt(m.Timestamp, now)
+			if err != nil {
+				return err
+			}
+			latest, exists := observed[key]
+			if !exists {
+				var current models.GaugeMetric
+				current, exists = ms.gauges[key]
+				latest = current.Timestamp
+			}
+			if exists && observedAt.Before(latest) {
+				stale = append(stale, m)
+				continue
+			}
+			observed[key] = observedAt
+
+			value := m.Value
+			records = append(records, dto.Metrics{
+				ID:        m.Name,
+				MType:     string(constants.GaugeName),
+				Value:     &value,
+				Labels:    m.Labels,
+				Timestamp: timestampMillis(observedAt),
+			})
+		case *models.CounterMetric:
+			observedAt, err := ms.timestamps.observedAt(m.Timestamp, now)
+			if err != nil {
+				return err
+			}
+
+			key := models.SeriesKey(m.Name, m.Labels)
+			total, exists := totals[key]
+			if !exists {
+				total = ms.counters[key].Value
+			}
+			total += m.Value
+			totals[key] = total
+
+			records = append(records, dto.Metrics{
+				ID:        m.Name,
+				MType:     string(constants.CounterName),
+				Delta:     &total,
+				Labels:    m.Labels,
+				Timestamp: timestampMillis(observedAt),
+			})
+		case *models.HistogramMetric:
+			if err := m.Validate(); err != nil {
+				return fmt.Errorf("%w: %w", ErrInvalidMetricValue, err)
+			}
+
+			key := models.SeriesKey(m.Name, m.Labels)
+			current, exists := histograms[key]
+			if !exists {
+				current, exists = ms.histograms[key]
+			}
+
+			var merged models.HistogramMetric
+			if exists {
+				merged = current.Clone()
+				if err := merged.Merge(*m); err != nil {
+					return fmt.Errorf("%w: %w", ErrInvalidMetricValue, err)
+				}
+			} else {
+				merged = m.Clone()
+			}
+			merged.Name, merged.Labels = m.Name, m.Labels
+			histograms[key] = merged
+
+			records = append(records, HistogramDTO(merged))
+		case *models.SummaryMetric:
+			if err := validateSummary(m); err != nil {
+				return err
+			}
+
+			key := models.SeriesKey(m.Name, m.Labels)
+			current, exists := summaries[key]
+			if !exists {
+				current, exists = ms.summaries[key]
+			}
+
+			var merged models.SummaryMetric
+			if exists {
+				merged = current.Clone()
+				if err := merged.Sketch.Merge(m.Sketch); err != nil {
+					return fmt.Errorf("%w: %w", ErrInvalidMetricValue, err)
+				}
+			} else {
+				merged = m.Clone()
+			}
+			merged.Name, merged.Labels = m.Name, m.Labels
+			summaries[key] = merged
+
+			records = append(records, SummaryDTO(merged))
+		case *models.SetMetric:
+			if err := validateSet(m); err != nil {
+				return err
+			}
+
+			key := models.SeriesKey(m.Name, m.Labels)
+			current, exists := sets[key]
+			if !exists {
+				current, exists = ms.sets[key]
+			}
+
+			var merged models.SetMetric
+			if exists {
+				merged = current.Clone()
+				if err := merged.HLL.Merge(m.HLL); err != nil {
+					return fmt.Errorf("%w: %w", ErrInvalidMetricValue, err)
+				}
+			} else {
+				merged = m.Clone()
+			}
+			merged.Name, merged.Labels = m.Name, m.Labels
+			sets[key] = merged
+
+			records = append(records, SetDTO(merged))
+		case *models.InfoMetric:
+			if err := m.Validate(); err != nil {
+				return fmt.Errorf("%w: %w", ErrInvalidMetricValue, err)
+			}
+
+			text := m.Value
+			records = append(records, dto.Metrics{
+				ID:     m.Name,
+				MType:  string(constants.InfoName),
+				Text:   &text,
+				Labels: m.Labels,
+			})
+		default:
+			return ErrInvalidMetricType
+		}
+		applied = append(applied, metric)
+	}
+
+	var batch *batchRecord
+	if batchID != "" {
+		batch = &batchRecord{ID: batchID, AppliedAt: now.UnixMilli()}
+	}
+	if ms.wal != nil {
+		if err := ms.wal.Append(records, batch); err != nil {
+			return fmt.Errorf("ошибка записи в журнал метрик: %w", err)
+		}
+	}
+	if batch != nil {
+		ms.batches[batchID] = now
+	}
+
+	for i, record := range records {
+		if err := ms.restoreLocked(record); err != nil {
+			return err
+		}
+
+		switch m := applied[i].(type) {
+		case *models.GaugeMetric:
+			m.Timestamp = ms.gauges[models.SeriesKey(m.Name, m.Labels)].Timestamp
+		case *models.CounterMetric:
+			m.Value = *record.Delta
+			m.Timestamp = ms.counters[models.SeriesKey(m.Name, m.Labels)].Timestamp
+		case *models.HistogramMetric:
+			m.Buckets = append([]float64(nil), record.Buckets...)
+			m.Counts = append([]uint64(nil), record.Counts...)
+			m.Sum, m.Count = *record.Sum, *record.Count
+		case *models.SummaryMetric:
+			m.Sketch = ms.summaries[models.SeriesKey(m.Name, m.Labels)].Sketch.Clone()
+		case *models.SetMetric:
+			m.HLL = ms.sets[models.SeriesKey(m.Name, m.Labels)].HLL.Clone()
+		}
+	}
+
+	for _, m := range stale {
+		current := ms.gauges[models.SeriesKey(m.Name, m.Labels)]
+		m.Value, m.Timestamp = current.Value, current.Timestamp
 	}
-	ms.counters[metric.Name] = *metric
 	return nil
 }
 
-func (ms *MemStorage) GetGauge(name string, ctx context.Context) (models.GaugeMetric, error) {
-	metric, exists := ms.gauges[name]
+// Установка итогового значения метрики из файла, журнала или батча; вызывается под ms.mu
+func (ms *MemStorage) restoreLocked(record dto.Metrics) error {
+	key := models.SeriesKey(record.ID, record.Labels)
+	// Записи без времени наблюдения остались от прежних версий; в историю они
+	// попадают со временем восстановления
+	var observedAt time.Time
+	sampledAt := time.Now()
+	if record.Timestamp != nil {
+		observedAt = time.UnixMilli(*record.Timestamp)
+		sampledAt = observedAt
+	}
+
+	switch constants.MetricType(record.MType) {
+	case constants.GaugeName:
+		if record.Value == nil {
+			return nil
+		}
+		ms.gauges[key] = models.GaugeMetric{
+			Name:      record.ID,
+			Type:      constants.GaugeName,
+			Labels:    models.Labels(record.Labels),
+			Value:     *record.Value,
+			Timestamp: observedAt,
+		}
+		ms.recordHistory(ms.gaugeHistory, key, *record.Value, sampledAt)
+
+	case constants.CounterName:
+		if record.Delta == nil {
+			return nil
+		}
+		// Время counter — самое позднее из наблюдений. В записи накопленное значение,
+		// в историю попадает приращение: накопленные значения вычисляет GetHistory.
+		previous := ms.counters[key]
+		latest := observedAt
+		if previous.Timestamp.After(latest) {
+			latest = previous.Timestamp
+		}
+		ms.counters[key] = models.CounterMetric{
+			Name:      record.ID,
+			Type:      constants.CounterName,
+			Labels:    models.Labels(record.Labels),
+			Value:     *record.Delta,
+			Timestamp: latest,
+		}
+		ms.recordHistory(ms.counterHistory, key, float64(*record.Delta-previous.Value), sampledAt)
+
+	case constants.HistogramName:
+		if record.Counts == nil {
+			return nil
+		}
+		metric, err := NewDefaultParser().histogramFromDTO(record, models.Labels(record.Labels))
+		if err != nil {
+			return fmt.Errorf("некорректная гистограмма %s: %w", key, err)
+		}
+		ms.histograms[key] = *metric.(*models.HistogramMetric)
+
+	case constants.SummaryName:
+		if record.Sketch == nil {
+			return nil
+		}
+		s, err := sketch.FromState(record.Sketch)
+		if err != nil {
+			return fmt.Errorf("некорректная summary %s: %w", key, err)
+		}
+		ms.summaries[key] = models.SummaryMetric{
+			Name:   record.ID,
+			Type:   constants.SummaryName,
+			Labels: models.Labels(record.Labels),
+			Sketch: s,
+		}
+
+	case constants.SetName:
+		if record.HLL == nil {
+			return nil
+		}
+		h, err := sketch.HLLFromState(record.HLL)
+		if err != nil {
+			return fmt.Errorf("некорректный set %s: %w", key, err)
+		}
+		ms.sets[key] = models.SetMetric{
+			Name:   record.ID,
+			Type:   constants.SetName,
+			Labels: models.Labels(record.Labels),
+			HLL:    h,
+		}
+
+	case constants.InfoName:
+		if record.Text == nil {
+			return nil
+		}
+		ms.infos[key] = models.InfoMetric{
+			Name:   record.ID,
+			Type:   constants.InfoName,
+			Labels: models.Labels(record.Labels),
+			Value:  *record.Text,
+		}
+
+	default:
+		return fmt.Errorf("неизвестный тип метрики: %s", record.MType)
+	}
+	return nil
+}
+
+// Запись значения в историю метрики по времени наблюдения; вызывается под ms.mu.
+// Для counter записывается приращение.
+func (ms *MemStorage) recordHistory(history map[string]*ringBuffer, key string, value float64, observedAt time.Time) {
+	buffer, exists := history[key]
+	if !exists {
+		buffer = newRingBuffer(ms.historySize)
+		history[key] = buffer
+	}
+	buffer.Add(models.Sample{Timestamp: observedAt, Value: value})
+}
+
+func (ms *MemStorage) GetHistory(metricType, name string, labels models.Labels, from, to time.Time, ctx context.Context) ([]models.Sample, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	key := models.SeriesKey(name, labels)
+	switch constants.MetricType(metricType) {
+	case constants.GaugeName:
+		if buffer := ms.gaugeHistory[key]; buffer != nil {
+			return buffer.Range(from, to), nil
+		}
+	case constants.CounterName:
+		if buffer := ms.counterHistory[key]; buffer != nil {
+			return buffer.RunningTotals(float64(ms.counters[key].Value), from, to), nil
+		}
+	default:
+		return nil, ErrInvalidMetricType
+	}
+	return []models.Sample{}, nil
+}
+
+func (ms *MemStorage) PruneHistory(ctx context.Context) error {
+	if ms.historyRetention <= 0 {
+		return nil
+	}
+
+	before := time.Now().Add(-ms.historyRetention)
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	for _, history := range []map[string]*ringBuffer{ms.gaugeHistory, ms.counterHistory} {
+		for key, buffer := range history {
+			// Буфер серии без значений в пределах срока удаляется целиком
+			if buffer.DropBefore(before) == 0 {
+				delete(history, key)
+			}
+		}
+	}
+	return nil
+}
+
+func (ms *MemStorage) GetGauge(name string, labels models.Labels, ctx context.Context) (models.GaugeMetric, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	metric, exists := ms.gauges[models.SeriesKey(name, labels)]
 	if !exists {
 		return models.GaugeMetric{}, ErrMetricNotFound
 	}
 	return metric, nil
 }
 
-func (ms *MemStorage) GetCounter(name string, ctx context.Context) (models.CounterMetric, error) {
-	metric, exists := ms.counters[name]
+func (ms *MemStorage) GetCounter(name string, labels models.Labels, ctx context.Context) (models.CounterMetric, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	metric, exists := ms.counters[models.SeriesKey(name, labels)]
 	if !exists {
 		return models.CounterMetric{}, ErrMetricNotFound
 	}
 	return metric, nil
 }
 
+func (ms *MemStorage) GetHistogram(name string, labels models.Labels, ctx context.Context) (models.HistogramMetric, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	metric, exists := ms.histograms[models.SeriesKey(name, labels)]
+	if !exists {
+		return models.HistogramMetric{}, ErrMetricNotFound
+	}
+	return metric.Clone(), nil
+}
+
+func (ms *MemStorage) GetSummary(name string, labels models.Labels, ctx context.Context) (models.SummaryMetric, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	metric, exists := ms.summaries[models.SeriesKey(name, labels)]
+	if !exists {
+		return models.SummaryMetric{}, ErrMetricNotFound
+	}
+	return metric.Clone(), nil
+}
+
+func (ms *MemStorage) GetSet(name string, labels models.Labels, ctx context.Context) (models.SetMetric, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	metric, exists := ms.sets[models.SeriesKey(name, labels)]
+	if !exists {
+		return models.SetMetric{}, ErrMetricNotFound
+	}
+	return metric.Clone(), nil
+}
+
+func (ms *MemStorage) GetInfo(name string, labels models.Labels, ctx context.Context) (models.InfoMetric, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	metric, exists := ms.infos[models.SeriesKey(name, labels)]
+	if !exists {
+		return models.InfoMetric{}, ErrMetricNotFound
+	}
+	return metric, nil
+}
+
 func (ms *MemStorage) GetAll(ctx context.Context) (map[string]models.GaugeMetric, map[string]models.CounterMetric, error) {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
@@ -209,112 +808,243 @@ func (ms *MemStorage) GetAll(ctx context.Context) (map[string]models.GaugeMetric
 	return gauges, counters, nil
 }
 
+func (ms *MemStorage) GetAllHistograms(ctx context.Context) (map[string]models.HistogramMetric, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	histograms := make(map[string]models.HistogramMetric, len(ms.histograms))
+	for key, metric := range ms.histograms {
+		histograms[key] = metric.Clone()
+	}
+	return histograms, nil
+}
+
+func (ms *MemStorage) GetAllSummaries(ctx context.Context) (map[string]models.SummaryMetric, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	summaries := make(map[string]models.SummaryMetric, len(ms.summaries))
+	for key, metric := range ms.summaries {
+		summaries[key] = metric.Clone()
+	}
+	return summaries, nil
+}
+
+func (ms *MemStorage) GetAllSets(ctx context.Context) (map[string]models.SetMetric, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	sets := make(map[string]models.SetMetric, len(ms.sets))
+	for key, metric := range ms.sets {
+		sets[key] = metric.Clone()
+	}
+	return sets, nil
+}
+
+func (ms *MemStorage) GetAllInfos(ctx context.Context) (map[string]models.InfoMetric, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	infos := make(map[string]models.InfoMetric, len(ms.infos))
+	for key, metric := range ms.infos {
+		infos[key] = metric
+	}
+	return infos, nil
+}
+
 func (ms *MemStorage) UpdateBatchJSON(metrics []models.Metric, ctx context.Context) (map[string]dto.Metrics, error) {
-	responses := make(map[string]dto.Metrics)
+	return ms.UpdateBatchOnce("", metrics, ctx)
+}
 
+func (ms *MemStorage) UpdateBatchOnce(batchID string, metrics []models.Metric, ctx context.Context) (map[string]dto.Metrics, error) {
 	for _, metric := range metrics {
-		id := metric.GetName()
-		if id == "" {
+		if metric.GetName() == "" {
 			return nil, ErrInvalidMetricID
 		}
+	}
+
+	// Весь батч применяется и записывается в журнал одной операцией
+	ms.mu.Lock()
+	var err error
+	if ms.batchAppliedLocked(batchID, time.Now()) {
+		err = ErrDuplicateBatch
+	} else {
+		err = ms.commitBatchLocked(metrics, batchID)
+	}
+	ms.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
 
+	responses := make(map[string]dto.Metrics)
+	for _, metric := range metrics {
 		response := dto.Metrics{
-			ID:    id,
-			MType: string(metric.GetType()),
+			ID:     metric.GetName(),
+			MType:  string(metric.GetType()),
+			Labels: metric.GetLabels(),
 		}
 
 		switch m := metric.(type) {
 		case *models.GaugeMetric:
-			ms.UpdateGauge(m, ctx)
 			response.Value = &m.Value
+			response.Timestamp = timestampMillis(m.Timestamp)
 		case *models.CounterMetric:
-			ms.UpdateCounter(m, ctx)
 			response.Delta = &m.Value
-		default:
-			return nil, ErrInvalidMetricType
+			response.Timestamp = timestampMillis(m.Timestamp)
+		case *models.HistogramMetric:
+			response = HistogramDTO(*m)
+		case *models.SummaryMetric:
+			response = SummaryDTO(*m)
+		case *models.SetMetric:
+			response = SetDTO(*m)
+		case *models.InfoMetric:
+			response.Text = &m.Value
 		}
 
-		responses[id] = response
+		responses[models.TypedSeriesKey(string(metric.GetType()), metric.GetName(), metric.GetLabels())] = response
 	}
 
 	return responses, nil
 }
 
+// Восстановление метрик: сначала файл метрик, затем журнал обновлений поверх него
 func (ms *MemStorage) LoadMetricsFromFile(config serverConfig.Config) error {
 	if !config.Restore {
 		return nil
 	}
 
-	file, err := os.Open(config.FileStoragePath)
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if err := ms.loadSnapshotLocked(config.FileStoragePath); err != nil {
+		return err
+	}
+	return replayWAL(walPath(config.FileStoragePath), ms.restoreRecordLocked)
+}
+
+// Батч с этим идентификатором уже применён и ещё не забыт; вызывается под ms.mu
+func (ms *MemStorage) batchAppliedLocked(batchID string, now time.Time) bool {
+	if batchID == "" {
+		return false
+	}
+	appliedAt, ok := ms.batches[batchID]
+	return ok && !ms.batchExpired(appliedAt, now)
+}
+
+// Идентификаторы хранятся batchTTL; 0 — без ограничения
+func (ms *MemStorage) batchExpired(appliedAt, now time.Time) bool {
+	return ms.batchTTL > 0 && now.Sub(appliedAt) > ms.batchTTL
+}
+
+// Строка снимка или журнала: метрика или отметка применённого батча; вызывается под ms.mu
+func (ms *MemStorage) restoreRecordLocked(record fileRecord) error {
+	if record.Batch != nil {
+		ms.batches[record.Batch.ID] = time.UnixMilli(record.Batch.AppliedAt)
+		return nil
+	}
+	if record.Metrics == nil {
+		return nil
+	}
+	return ms.restoreLocked(*record.Metrics)
+}
+
+func (ms *MemStorage) loadSnapshotLocked(path string) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// Первый запуск: снимка ещё нет, но метрики могут быть в журнале
+		return nil
+	}
 	if err != nil {
 		return fmt.Errorf("не удалось открыть файл для чтения метрик: %w", err)
 	}
 	defer file.Close()
 
 	decoder := json.NewDecoder(file)
-	ctx := context.Background()
 
 	for {
-		var metricDTO dto.Metrics
-		if err := decoder.Decode(&metricDTO); err != nil {
+		var record fileRecord
+		if err := decoder.Decode(&record); err != nil {
 			if errors.Is(err, io.EOF) {
-				break // всё успешно прочитано
+				return nil // всё успешно прочитано
 			}
 			return fmt.Errorf("ошибка при декодировании JSON: %w", err)
 		}
 
-		switch constants.MetricType(metricDTO.MType) {
-		case constants.GaugeName:
-			if metricDTO.Value != nil {
-				metric := &models.GaugeMetric{
-					Name:  metricDTO.ID,
-					Type:  constants.GaugeName,
-					Value: *metricDTO.Value,
-				}
-				if err := ms.UpdateGauge(metric, ctx); err != nil {
-					return fmt.Errorf("ошибка при обновлении gauge метрики: %w", err)
-				}
-			}
-
-		case constants.CounterName:
-			if metricDTO.Delta != nil {
-				metric := &models.CounterMetric{
-					Name:  metricDTO.ID,
-					Type:  constants.CounterName,
-					Value: *metricDTO.Delta,
-				}
-				if err := ms.UpdateCounter(metric, ctx); err != nil {
-					return fmt.Errorf("ошибка при обновлении counter метрики: %w", err)
-				}
-			}
-
-		default:
-			return fmt.Errorf("неизвестный тип метрики: %s", metricDTO.MType)
+		if err := ms.restoreRecordLocked(record); err != nil {
+			return err
 		}
 	}
-
-	return nil
 }
 
 func (ms *MemStorage) SaveMetricsToFile(config serverConfig.Config) error {
 	ms.mu.Lock()
 	defer ms.mu.Unlock()
 
-	// Создание файла
-	file, err := os.Create(config.FileStoragePath)
+	return ms.saveLocked(config.FileStoragePath)
+}
+
+// Запись снимка всех метрик; после неё журнал больше не нужен и очищается
+func (ms *MemStorage) saveLocked(path string) error {
+	if err := ms.writeSnapshotLocked(path); err != nil {
+		return err
+	}
+
+	if ms.wal != nil {
+		if err := ms.wal.Reset(); err != nil {
+			return fmt.Errorf("ошибка при очистке журнала метрик: %w", err)
+		}
+		return nil
+	}
+
+	// Журнал, оставшийся от предыдущего запуска, уже учтён в снимке
+	if err := os.Remove(walPath(path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("ошибка при удалении журнала метрик: %w", err)
+	}
+	return nil
+}
+
+// Снимок пишется во временный файл и переименовывается поверх старого,
+// поэтому при сбое на диске остаётся либо старый, либо новый снимок целиком
+func (ms *MemStorage) writeSnapshotLocked(path string) error {
+	tmpPath := path + ".tmp"
+	file, err := os.Create(tmpPath)
 	if err != nil {
 		return fmt.Errorf("не удалось создать файл для записи метрик: %w", err)
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
+	if err := ms.encodeSnapshotLocked(file); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("ошибка при сохранении файла метрик: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("ошибка при сохранении файла метрик: %w", err)
+	}
+
+	if err := renameFile(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("ошибка при замене файла метрик: %w", err)
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+func (ms *MemStorage) encodeSnapshotLocked(w io.Writer) error {
+	encoder := json.NewEncoder(w)
 
 	// Сохраняем метрики Gauge
 	for name, gauge := range ms.gauges {
 		metric := dto.Metrics{
-			ID:    name,
-			MType: string(constants.GaugeName),
-			Value: &gauge.Value,
+			ID:        gauge.Name,
+			MType:     string(constants.GaugeName),
+			Value:     &gauge.Value,
+			Labels:    gauge.Labels,
+			Timestamp: timestampMillis(gauge.Timestamp),
 		}
 		if err := encoder.Encode(metric); err != nil {
 			return fmt.Errorf("ошибка при записи метрики %s в файл: %w", name, err)
@@ -324,36 +1054,147 @@ func (ms *MemStorage) SaveMetricsToFile(config serverConfig.Config) error {
 	// Сохраняем метрики Counter
 	for name, counter := range ms.counters {
 		metric := dto.Metrics{
-			ID:    name,
-			MType: string(constants.CounterName),
-			Delta: &counter.Value,
+			ID:        counter.Name,
+			MType:     string(constants.CounterName),
+			Delta:     &counter.Value,
+			Labels:    counter.Labels,
+			Timestamp: timestampMillis(counter.Timestamp),
+		}
+		if err := encoder.Encode(metric); err != nil {
+			return fmt.Errorf("ошибка при записи метрики %s в файл: %w", name, err)
+		}
+	}
+
+	// Сохраняем метрики Histogram
+	for name, histogram := range ms.histograms {
+		if err := encoder.Encode(HistogramDTO(histogram)); err != nil {
+			return fmt.Errorf("ошибка при записи метрики %s в файл: %w", name, err)
+		}
+	}
+
+	// Сохраняем метрики Summary
+	for name, summary := range ms.summaries {
+		if err := encoder.Encode(SummaryDTO(summary)); err != nil {
+			return fmt.Errorf("ошибка при записи метрики %s в файл: %w", name, err)
+		}
+	}
+
+	// Сохраняем метрики Set
+	for name, set := range ms.sets {
+		if err := encoder.Encode(SetDTO(set)); err != nil {
+			return fmt.Errorf("ошибка при записи метрики %s в файл: %w", name, err)
+		}
+	}
+
+	// Сохраняем метрики Info
+	for name, info := range ms.infos {
+		metric := dto.Metrics{
+			ID:     info.Name,
+			MType:  string(constants.InfoName),
+			Text:   &info.Value,
+			Labels: info.Labels,
 		}
 		if err := encoder.Encode(metric); err != nil {
 			return fmt.Errorf("ошибка при записи метрики %s в файл: %w", name, err)
 		}
 	}
 
+	// Сохраняем идентификаторы батчей, забывая устаревшие
+	now := time.Now()
+	for id, appliedAt := range ms.batches {
+		if ms.batchExpired(appliedAt, now) {
+			delete(ms.batches, id)
+			continue
+		}
+		record := fileRecord{Batch: &batchRecord{ID: id, AppliedAt: appliedAt.UnixMilli()}}
+		if err := encoder.Encode(record); err != nil {
+			return fmt.Errorf("ошибка при записи батча %s в файл: %w", id, err)
+		}
+	}
+
 	return nil
 }
 
-// Функция для периодического сохранения метрик
-func (ms *MemStorage) StartMetricSaving(config serverConfig.Config, logger *zap.Logger) {
-	if config.StoreInterval == 0 {
-		// 🔁 Однократное сохранение при запуске
-		if err := ms.SaveMetricsToFile(config); err != nil {
-			logger.Error("ошибка при однократном сохранении метрик", zap.Error(err))
-		}
-		return
+// fsync каталога, чтобы переименование файла пережило сбой
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть каталог файла метрик: %w", err)
 	}
+	defer d.Close()
 
-	ticker := time.NewTicker(config.StoreInterval)
-	defer ticker.Stop()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("ошибка при сохранении каталога файла метрик: %w", err)
+	}
+	return nil
+}
 
-	logger.Info("запущено периодическое сохранение метрик", zap.Duration("interval", config.StoreInterval))
+// Включение журнала обновлений. Вызывается после LoadMetricsFromFile: текущее
+// состояние сразу записывается снимком, а журнал начинается с чистого листа.
+// При STORE_INTERVAL=0 каждое обновление попадает на диск (fsync) до ответа клиенту.
+func (ms *MemStorage) EnableWAL(config serverConfig.Config) error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
 
-	for range ticker.C {
-		if err := ms.SaveMetricsToFile(config); err != nil {
-			logger.Error("ошибка при сохранении метрик", zap.Error(err))
+	if ms.wal != nil {
+		return nil
+	}
+
+	w, err := openWAL(walPath(config.FileStoragePath), config.StoreInterval == 0)
+	if err != nil {
+		return err
+	}
+	ms.wal = w
+
+	if err := ms.saveLocked(config.FileStoragePath); err != nil {
+		ms.wal = nil
+		w.Close()
+		return err
+	}
+	return nil
+}
+
+// Функция для периодического сохранения метрик; завершается при отмене контекста.
+// В режиме синхронной записи периодически сжимает журнал в файл метрик,
+// в периодическом — дополнительно сбрасывает журнал на диск раз в walSyncInterval.
+func (ms *MemStorage) StartMetricSaving(config serverConfig.Config, logger *zap.Logger, ctx context.Context) {
+	interval := config.StoreInterval
+	var walSync <-chan time.Time
+	if interval == 0 {
+		interval = compactionInterval
+		logger.Info("включена синхронная запись метрик", zap.Duration("compaction_interval", interval))
+	} else {
+		logger.Info("запущено периодическое сохранение метрик", zap.Duration("interval", interval), zap.Duration("wal_sync_interval", walSyncInterval))
+		walTicker := time.NewTicker(walSyncInterval)
+		defer walTicker.Stop()
+		walSync = walTicker.C
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-walSync:
+			if err := ms.syncWAL(); err != nil {
+				logger.Error("ошибка при сбросе журнала метрик на диск", zap.Error(err))
+			}
+		case <-ticker.C:
+			if err := ms.SaveMetricsToFile(config); err != nil {
+				logger.Error("ошибка при сохранении метрик", zap.Error(err))
+			}
 		}
 	}
 }
+
+func (ms *MemStorage) syncWAL() error {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	if ms.wal == nil {
+		return nil
+	}
+	return ms.wal.Sync()
+}
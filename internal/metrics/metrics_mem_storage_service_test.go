@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+	serverConfig "github.com/GarikMirzoyan/metricalert/internal/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestMemStorage_GetHistory(t *testing.T) {
+	ms := NewMemStorageWithHistory(2)
+	ctx := context.Background()
+	from := time.Now().Add(-time.Minute)
+
+	for _, v := range []float64{1, 2, 3} {
+		require.NoError(t, ms.UpdateGauge(&models.GaugeMetric{Name: "Alloc", Type: constants.GaugeName, Value: v}, ctx))
+	}
+	for _, d := range []int64{5, 7} {
+		require.NoError(t, ms.UpdateCounter(&models.CounterMetric{Name: "PollCount", Type: constants.CounterName, Value: d}, ctx))
+	}
+
+	to := time.Now().Add(time.Minute)
+
+	gauges, err := ms.GetHistory("gauge", "Alloc", nil, from, to, ctx)
+	require.NoError(t, err)
+	require.Len(t, gauges, 2)
+	assert.Equal(t, 2.0, gauges[0].Value)
+	assert.Equal(t, 3.0, gauges[1].Value)
+
+	// Для counter в историю попадает накопленное значение
+	counters, err := ms.GetHistory("counter", "PollCount", nil, from, to, ctx)
+	require.NoError(t, err)
+	require.Len(t, counters, 2)
+	assert.Equal(t, 5.0, counters[0].Value)
+	assert.Equal(t, 12.0, counters[1].Value)
+
+	missing, err := ms.GetHistory("gauge", "Unknown", nil, from, to, ctx)
+	require.NoError(t, err)
+	assert.Empty(t, missing)
+
+	_, err = ms.GetHistory("unknown", "Alloc", nil, from, to, ctx)
+	assert.ErrorIs(t, err, ErrInvalidMetricType)
+}
+
+func TestMemStorage_LabelsDefineSeries(t *testing.T) {
+	ms := NewMemStorage()
+	ctx := context.Background()
+
+	web1 := models.Labels{"host": "web-1"}
+	web2 := models.Labels{"host": "web-2"}
+
+	for _, labels := range []models.Labels{web1, web2, web1} {
+		metric := &models.CounterMetric{Name: "Requests", Type: constants.CounterName, Labels: labels, Value: 1}
+		require.NoError(t, ms.UpdateCounter(metric, ctx))
+	}
+
+	value, err := ms.GetValue("counter", "Requests", web1, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "2", value)
+
+	value, err = ms.GetValue("counter", "Requests", models.Labels{"host": "web-2"}, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "1", value)
+
+	_, err = ms.GetValue("counter", "Requests", nil, ctx)
+	assert.ErrorIs(t, err, ErrMetricNotFound)
+
+	_, counters, err := ms.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, counters, 2)
+	assert.Equal(t, web2, counters[`Requests{host="web-2"}`].Labels)
+}
+
+func TestMemStorage_StartMetricSavingStopsOnCancel(t *testing.T) {
+	ms := NewMemStorage()
+	config := serverConfig.Config{
+		StoreInterval:   10 * time.Millisecond,
+		FileStoragePath: filepath.Join(t.TempDir(), "metrics.json"),
+	}
+	require.NoError(t, ms.UpdateGauge(&models.GaugeMetric{Name: "Alloc", Type: constants.GaugeName, Value: 1}, context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ms.StartMetricSaving(config, zap.NewNop(), ctx)
+		close(done)
+	}()
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(config.FileStoragePath)
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("сохранение метрик не остановилось после отмены контекста")
+	}
+}
@@ -2,21 +2,58 @@ package metrics
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
 	"github.com/GarikMirzoyan/metricalert/internal/models"
 )
 
+var ErrDuplicateBatch = errors.New("batch already applied")
+
 type MetricStorage interface {
 	Update(metric models.Metric, ctx context.Context) error
 	UpdateGauge(metric *models.GaugeMetric, ctx context.Context) error
 	UpdateCounter(metric *models.CounterMetric, ctx context.Context) error
+	// Гистограмма складывается с сохранённой; после обновления в metric — итоговое состояние
+	UpdateHistogram(metric *models.HistogramMetric, ctx context.Context) error
+	// Скетч summary складывается с сохранённым; после обновления в metric — итоговый скетч
+	UpdateSummary(metric *models.SummaryMetric, ctx context.Context) error
+	// HyperLogLog set объединяется с сохранённым; после обновления в metric — итоговое состояние
+	UpdateSet(metric *models.SetMetric, ctx context.Context) error
+	// Значение info заменяет сохранённое
+	UpdateInfo(metric *models.InfoMetric, ctx context.Context) error
 	UpdateJSON(metric models.Metric, ctx context.Context) (dto.Metrics, error)
+	// Ключи ответа — ключи серий с типом (models.TypedSeriesKey): gauge и counter
+	// с одним именем в одном батче получают отдельные ответы
 	UpdateBatchJSON(metrics []models.Metric, ctx context.Context) (map[string]dto.Metrics, error)
+	// Батч с непустым batchID применяется один раз: идентификатор сохраняется вместе с метриками
+	// (в журнале, снимке или таблице базы) и помнится Options.BatchTTL; повтор — ErrDuplicateBatch
+	UpdateBatchOnce(batchID string, metrics []models.Metric, ctx context.Context) (map[string]dto.Metrics, error)
 
-	GetValue(metricType, name string, ctx context.Context) (string, error)
-	GetGauge(name string, ctx context.Context) (models.GaugeMetric, error)
-	GetCounter(name string, ctx context.Context) (models.CounterMetric, error)
+	GetValue(metricType, name string, labels models.Labels, ctx context.Context) (string, error)
+	GetGauge(name string, labels models.Labels, ctx context.Context) (models.GaugeMetric, error)
+	GetCounter(name string, labels models.Labels, ctx context.Context) (models.CounterMetric, error)
+	GetHistogram(name string, labels models.Labels, ctx context.Context) (models.HistogramMetric, error)
+	GetSummary(name string, labels models.Labels, ctx context.Context) (models.SummaryMetric, error)
+	GetSet(name string, labels models.Labels, ctx context.Context) (models.SetMetric, error)
+	GetInfo(name string, labels models.Labels, ctx context.Context) (models.InfoMetric, error)
 	GetJSON(metric models.Metric, ctx context.Context) (dto.Metrics, error)
+	// Ключи возвращаемых карт — ключи серий (models.SeriesKey)
 	GetAll(ctx context.Context) (map[string]models.GaugeMetric, map[string]models.CounterMetric, error)
+	GetAllHistograms(ctx context.Context) (map[string]models.HistogramMetric, error)
+	GetAllSummaries(ctx context.Context) (map[string]models.SummaryMetric, error)
+	GetAllSets(ctx context.Context) (map[string]models.SetMetric, error)
+	GetAllInfos(ctx context.Context) (map[string]models.InfoMetric, error)
+	GetHistory(metricType, name string, labels models.Labels, from, to time.Time, ctx context.Context) ([]models.Sample, error)
+	// Удаление истории старше Options.HistoryRetention; при нулевом сроке ничего не делает
+	PruneHistory(ctx context.Context) error
+}
+
+// Настройки хранилища из конфигурации сервера
+type Options struct {
+	HistorySize      int             // число последних значений истории на метрику в MemStorage; 0 — DefaultHistorySize
+	HistoryRetention time.Duration   // срок хранения истории; 0 — без ограничения по времени
+	Timestamps       TimestampPolicy // окно допустимого времени наблюдений
+	BatchTTL         time.Duration   // сколько помнить идентификаторы применённых батчей; 0 — всегда
 }
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	agentConfig "github.com/GarikMirzoyan/metricalert/internal/agent/config"
+	"github.com/GarikMirzoyan/metricalert/internal/idempotency"
+	"github.com/GarikMirzoyan/metricalert/internal/middleware/cryptomiddleware"
+	"github.com/GarikMirzoyan/metricalert/internal/middleware/gzipmiddleware"
+	"github.com/GarikMirzoyan/metricalert/internal/middleware/hashmiddleware"
+	"github.com/GarikMirzoyan/metricalert/internal/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Тело, подготовленное агентом, проходит цепочку middleware сервера
+func TestPrepareRequestBody_ServerRoundTrip(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	value := 1.5
+	batch := make([]dto.Metrics, 0, 100)
+	for i := 0; i < 100; i++ {
+		batch = append(batch, dto.Metrics{ID: "Alloc", MType: "gauge", Value: &value})
+	}
+	body, err := json.Marshal(batch)
+	require.NoError(t, err)
+
+	config := agentConfig.Config{Key: "secret", PublicKey: &key.PublicKey}
+	payload, headers, err := prepareRequestBody(body, config)
+	require.NoError(t, err)
+
+	var received []byte
+	var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, err = io.ReadAll(r.Body)
+		require.NoError(t, err)
+	})
+	handler = gzipmiddleware.GzipDecompression(handler)
+	handler = cryptomiddleware.Decrypt(handler, key)
+	handler = hashmiddleware.Hash(handler, config.Key)
+
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(payload))
+	req.Header = headers
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, body, received)
+}
+
+func TestSendBatchMetrics_BatchIDAndErrors(t *testing.T) {
+	var gotBatchID string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBatchID = r.Header.Get(idempotency.HeaderName)
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	value := 1.0
+	batch := []dto.Metrics{{ID: "Alloc", MType: "gauge", Value: &value}}
+	config := agentConfig.Config{Address: server.URL}
+
+	require.NoError(t, SendBatchMetrics(batch, "batch-1", config, context.Background()))
+	assert.Equal(t, "batch-1", gotBatchID)
+
+	// Отказ сервера 4xx возвращается сразу и не считается временной ошибкой
+	status = http.StatusBadRequest
+	err := SendBatchMetrics(batch, "batch-2", config, context.Background())
+	require.Error(t, err)
+	assert.False(t, retry.IsRetriableError(err))
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"slices"
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+)
+
+// Кольцевой буфер фиксированного размера с историей значений одной метрики.
+// Значения упорядочены по времени наблюдения, при переполнении вытесняются самые старые.
+type ringBuffer struct {
+	samples []models.Sample
+	start   int
+	size    int
+}
+
+func newRingBuffer(capacity int) *ringBuffer {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &ringBuffer{samples: make([]models.Sample, capacity)}
+}
+
+// Вставка после всех значений с тем же или более ранним временем: наблюдения, пришедшие
+// не по порядку (повтор из буфера агента, параллельная отправка), встают на своё место.
+// В заполненном буфере значение старше всех сохранённых сразу вытесняется и не добавляется.
+func (rb *ringBuffer) Add(sample models.Sample) {
+	pos := rb.size
+	for pos > 0 && rb.at(pos-1).Timestamp.After(sample.Timestamp) {
+		pos--
+	}
+
+	if rb.size == len(rb.samples) {
+		if pos == 0 {
+			return
+		}
+		rb.start = (rb.start + 1) % len(rb.samples)
+		rb.size--
+		pos--
+	}
+
+	for i := rb.size; i > pos; i-- {
+		rb.set(i, rb.at(i-1))
+	}
+	rb.set(pos, sample)
+	rb.size++
+}
+
+func (rb *ringBuffer) at(i int) models.Sample {
+	return rb.samples[(rb.start+i)%len(rb.samples)]
+}
+
+func (rb *ringBuffer) set(i int, sample models.Sample) {
+	rb.samples[(rb.start+i)%len(rb.samples)] = sample
+}
+
+func (rb *ringBuffer) Len() int {
+	return rb.size
+}
+
+// Удаление значений раньше before с сохранением порядка остальных; возвращает число оставшихся
+func (rb *ringBuffer) DropBefore(before time.Time) int {
+	kept := 0
+	for i := 0; i < rb.size; i++ {
+		sample := rb.at(i)
+		if sample.Timestamp.Before(before) {
+			continue
+		}
+		rb.set(kept, sample)
+		kept++
+	}
+	rb.size = kept
+	return kept
+}
+
+// Значения в интервале [from, to] по возрастанию времени
+func (rb *ringBuffer) Range(from, to time.Time) []models.Sample {
+	result := make([]models.Sample, 0, rb.size)
+	for i := 0; i < rb.size; i++ {
+		sample := rb.at(i)
+		if sample.Timestamp.Before(from) || sample.Timestamp.After(to) {
+			continue
+		}
+		result = append(result, sample)
+	}
+	return result
+}
+
+// Накопленные значения counter в интервале [from, to], если в буфере хранятся приращения:
+// значение в момент наблюдения — итог total за вычетом всех более поздних приращений.
+// Поэтому приращение, пришедшее не по порядку, учитывается во всех следующих значениях.
+func (rb *ringBuffer) RunningTotals(total float64, from, to time.Time) []models.Sample {
+	result := make([]models.Sample, 0, rb.size)
+	for i := rb.size - 1; i >= 0; i-- {
+		sample := rb.at(i)
+		if !sample.Timestamp.Before(from) && !sample.Timestamp.After(to) {
+			result = append(result, models.Sample{Timestamp: sample.Timestamp, Value: total})
+		}
+		total -= sample.Value
+	}
+	slices.Reverse(result)
+	return result
+}
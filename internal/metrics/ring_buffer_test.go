@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRingBuffer_OverwritesOldest(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	rb := newRingBuffer(3)
+
+	for i := 0; i < 5; i++ {
+		rb.Add(models.Sample{Timestamp: base.Add(time.Duration(i) * time.Second), Value: float64(i)})
+	}
+
+	assert.Equal(t, 3, rb.Len())
+
+	samples := rb.Range(base, base.Add(time.Hour))
+	values := make([]float64, 0, len(samples))
+	for _, s := range samples {
+		values = append(values, s.Value)
+	}
+	assert.Equal(t, []float64{2, 3, 4}, values)
+}
+
+func TestRingBuffer_Range(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	rb := newRingBuffer(10)
+
+	for i := 0; i < 5; i++ {
+		rb.Add(models.Sample{Timestamp: base.Add(time.Duration(i) * time.Minute), Value: float64(i)})
+	}
+
+	samples := rb.Range(base.Add(time.Minute), base.Add(3*time.Minute))
+	assert.Len(t, samples, 3)
+	assert.Equal(t, 1.0, samples[0].Value)
+	assert.Equal(t, 3.0, samples[2].Value)
+
+	assert.Empty(t, rb.Range(base.Add(time.Hour), base.Add(2*time.Hour)))
+}
+
+func TestRingBuffer_DropBefore(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	rb := newRingBuffer(4)
+
+	// Буфер переполнен, начало не в нулевой позиции
+	for _, minute := range []int{0, 1, 2, 5, 6, 7} {
+		rb.Add(models.Sample{Timestamp: base.Add(time.Duration(minute) * time.Minute), Value: float64(minute)})
+	}
+
+	assert.Equal(t, 3, rb.DropBefore(base.Add(3*time.Minute)))
+
+	samples := rb.Range(base, base.Add(time.Hour))
+	values := make([]float64, 0, len(samples))
+	for _, s := range samples {
+		values = append(values, s.Value)
+	}
+	assert.Equal(t, []float64{5, 6, 7}, values)
+
+	// После удаления новые значения снова добавляются в конец
+	rb.Add(models.Sample{Timestamp: base.Add(8 * time.Minute), Value: 8})
+	assert.Equal(t, 4, rb.Len())
+	assert.Equal(t, 8.0, rb.Range(base, base.Add(time.Hour))[3].Value)
+}
+
+func TestRingBuffer_OrderedByTimestamp(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	rb := newRingBuffer(3)
+
+	// Буфер переполнен, значения приходят не по порядку
+	for _, minute := range []int{3, 1, 2, 5, 0, 4} {
+		rb.Add(models.Sample{Timestamp: base.Add(time.Duration(minute) * time.Minute), Value: float64(minute)})
+	}
+
+	samples := rb.Range(base, base.Add(time.Hour))
+	values := make([]float64, 0, len(samples))
+	for _, s := range samples {
+		values = append(values, s.Value)
+	}
+	// Значение 0 старше всех в заполненном буфере и не сохраняется
+	assert.Equal(t, []float64{3, 4, 5}, values)
+}
+
+func TestRingBuffer_RunningTotals(t *testing.T) {
+	base := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	rb := newRingBuffer(10)
+
+	// Приращения 5, 10, 15 с временем +3m, +1m, +2m; итог 30
+	rb.Add(models.Sample{Timestamp: base.Add(3 * time.Minute), Value: 5})
+	rb.Add(models.Sample{Timestamp: base.Add(time.Minute), Value: 10})
+	rb.Add(models.Sample{Timestamp: base.Add(2 * time.Minute), Value: 15})
+
+	assert.Equal(t, []models.Sample{
+		{Timestamp: base.Add(time.Minute), Value: 10},
+		{Timestamp: base.Add(2 * time.Minute), Value: 25},
+		{Timestamp: base.Add(3 * time.Minute), Value: 30},
+	}, rb.RunningTotals(30, base, base.Add(time.Hour)))
+
+	assert.Equal(t, []models.Sample{
+		{Timestamp: base.Add(2 * time.Minute), Value: 25},
+	}, rb.RunningTotals(30, base.Add(2*time.Minute), base.Add(2*time.Minute)))
+}
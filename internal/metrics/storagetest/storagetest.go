@@ -0,0 +1,853 @@
+package storagetest
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/metrics"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+	"github.com/GarikMirzoyan/metricalert/internal/sketch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Создание пустого хранилища с заданными настройками для каждого теста
+type Factory func(t *testing.T, options metrics.Options) metrics.MetricStorage
+
+// Проверка реализации хранилища
+type testCase struct {
+	name    string
+	options metrics.Options
+	run     func(t *testing.T, storage metrics.MetricStorage)
+}
+
+// Поведение, общее для всех реализаций metrics.MetricStorage
+var testCases = []testCase{
+	{name: "gauge replaces value", run: testGaugeReplacesValue},
+	{name: "counter accumulates", run: testCounterAccumulates},
+	{name: "gauge and counter share a name", run: testSameNameDifferentTypes},
+	{name: "labels define series", run: testLabelsDefineSeries},
+	{name: "batch merges repeated series", run: testBatchMergesRepeatedSeries},
+	{name: "batch response per type", run: testBatchResponsePerType},
+	{name: "batch applied once", run: testBatchAppliedOnce},
+	{name: "update json returns stored value", run: testUpdateJSONReturnsStoredValue},
+	{name: "get json", run: testGetJSON},
+	{name: "get value formatting", run: testGetValueFormatting},
+	{name: "missing metric", run: testMissingMetric},
+	{name: "invalid input", run: testInvalidInput},
+	{name: "history", run: testHistory},
+	{name: "history out of order", run: testHistoryOutOfOrder},
+	{name: "history retention", options: metrics.Options{HistoryRetention: time.Hour}, run: testHistoryRetention},
+	{name: "histogram merges buckets", run: testHistogramMergesBuckets},
+	{name: "histogram buckets mismatch", run: testHistogramBucketsMismatch},
+	{name: "summary merges sketches", run: testSummaryMergesSketches},
+	{name: "summary accuracy mismatch", run: testSummaryAccuracyMismatch},
+	{name: "set counts distinct members", run: testSetCountsDistinctMembers},
+	{name: "set precision mismatch", run: testSetPrecisionMismatch},
+	{name: "info last write wins", run: testInfoLastWriteWins},
+	{name: "gauge ordered by timestamp", run: testGaugeOrderedByTimestamp},
+}
+
+// Прогон всех проверок; каждая получает новое пустое хранилище
+func Run(t *testing.T, newStorage Factory) {
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, newStorage(t, tc.options))
+		})
+	}
+}
+
+func gauge(name string, value float64, labels models.Labels) *models.GaugeMetric {
+	return &models.GaugeMetric{Name: name, Type: constants.GaugeName, Labels: labels, Value: value}
+}
+
+func counter(name string, delta int64, labels models.Labels) *models.CounterMetric {
+	return &models.CounterMetric{Name: name, Type: constants.CounterName, Labels: labels, Value: delta}
+}
+
+func histogram(name string, buckets []float64, counts []uint64, sum float64, labels models.Labels) *models.HistogramMetric {
+	var count uint64
+	for _, c := range counts {
+		count += c
+	}
+	return &models.HistogramMetric{
+		Name:    name,
+		Type:    constants.HistogramName,
+		Labels:  labels,
+		Buckets: buckets,
+		Counts:  counts,
+		Sum:     sum,
+		Count:   count,
+	}
+}
+
+func summary(t *testing.T, name string, alpha float64, labels models.Labels, values ...float64) *models.SummaryMetric {
+	t.Helper()
+	s, err := sketch.New(alpha)
+	require.NoError(t, err)
+	for _, v := range values {
+		require.NoError(t, s.Add(v))
+	}
+	return &models.SummaryMetric{Name: name, Type: constants.SummaryName, Labels: labels, Sketch: s}
+}
+
+func set(t *testing.T, name string, precision uint8, labels models.Labels, members ...string) *models.SetMetric {
+	t.Helper()
+	h, err := sketch.NewHLL(precision)
+	require.NoError(t, err)
+	for _, member := range members {
+		h.Add(member)
+	}
+	return &models.SetMetric{Name: name, Type: constants.SetName, Labels: labels, HLL: h}
+}
+
+// Элементы user-from … user-(to-1)
+func users(from, to int) []string {
+	members := make([]string, 0, to-from)
+	for i := from; i < to; i++ {
+		members = append(members, "user-"+strconv.Itoa(i))
+	}
+	return members
+}
+
+func info(name, value string, labels models.Labels) *models.InfoMetric {
+	return &models.InfoMetric{Name: name, Type: constants.InfoName, Labels: labels, Value: value}
+}
+
+func requireGauge(t *testing.T, storage metrics.MetricStorage, name string, labels models.Labels, expected float64) {
+	t.Helper()
+	metric, err := storage.GetGauge(name, labels, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, expected, metric.Value)
+}
+
+func requireCounter(t *testing.T, storage metrics.MetricStorage, name string, labels models.Labels, expected int64) {
+	t.Helper()
+	metric, err := storage.GetCounter(name, labels, context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, expected, metric.Value)
+}
+
+func testGaugeReplacesValue(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+
+	require.NoError(t, storage.Update(gauge("Alloc", 1, nil), ctx))
+	require.NoError(t, storage.Update(gauge("Alloc", 2.5, nil), ctx))
+
+	requireGauge(t, storage, "Alloc", nil, 2.5)
+}
+
+func testCounterAccumulates(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+
+	require.NoError(t, storage.Update(counter("PollCount", 3, nil), ctx))
+	require.NoError(t, storage.Update(counter("PollCount", 4, nil), ctx))
+
+	requireCounter(t, storage, "PollCount", nil, 7)
+}
+
+func testSameNameDifferentTypes(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+
+	require.NoError(t, storage.Update(gauge("Alloc", 1.5, nil), ctx))
+	require.NoError(t, storage.Update(counter("Alloc", 2, nil), ctx))
+	require.NoError(t, storage.Update(counter("Alloc", 3, nil), ctx))
+	require.NoError(t, storage.Update(gauge("Alloc", 4.5, nil), ctx))
+
+	requireGauge(t, storage, "Alloc", nil, 4.5)
+	requireCounter(t, storage, "Alloc", nil, 5)
+
+	gauges, counters, err := storage.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 4.5, gauges["Alloc"].Value)
+	assert.Equal(t, int64(5), counters["Alloc"].Value)
+}
+
+func testLabelsDefineSeries(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+	web1 := models.Labels{"host": "web-1"}
+	web2 := models.Labels{"host": "web-2"}
+
+	require.NoError(t, storage.Update(counter("Requests", 1, web1), ctx))
+	require.NoError(t, storage.Update(counter("Requests", 2, web2), ctx))
+	require.NoError(t, storage.Update(counter("Requests", 3, web1), ctx))
+
+	requireCounter(t, storage, "Requests", web1, 4)
+	requireCounter(t, storage, "Requests", web2, 2)
+
+	_, err := storage.GetCounter("Requests", nil, ctx)
+	assert.Error(t, err)
+}
+
+func testBatchMergesRepeatedSeries(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+
+	require.NoError(t, storage.Update(counter("PollCount", 10, nil), ctx))
+
+	responses, err := storage.UpdateBatchJSON([]models.Metric{
+		counter("PollCount", 1, nil),
+		gauge("Alloc", 1, nil),
+		counter("PollCount", 2, nil),
+		gauge("Alloc", 3, nil),
+		gauge("PollCount", 9, nil),
+	}, ctx)
+	require.NoError(t, err)
+
+	// Ответ по серии — итоговое сохранённое значение
+	require.Contains(t, responses, "gauge Alloc")
+	require.NotNil(t, responses["gauge Alloc"].Value)
+	assert.Equal(t, 3.0, *responses["gauge Alloc"].Value)
+	assert.NotNil(t, responses["gauge Alloc"].Timestamp)
+
+	requireCounter(t, storage, "PollCount", nil, 13)
+	requireGauge(t, storage, "Alloc", nil, 3)
+	requireGauge(t, storage, "PollCount", nil, 9)
+}
+
+func testBatchResponsePerType(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+	labels := models.Labels{"host": "web-1"}
+
+	responses, err := storage.UpdateBatchJSON([]models.Metric{
+		gauge("Alloc", 1.5, labels),
+		counter("Alloc", 4, labels),
+		info("Alloc", "heap", labels),
+	}, ctx)
+	require.NoError(t, err)
+	require.Len(t, responses, 3)
+
+	key := func(metricType constants.MetricType) string {
+		return models.TypedSeriesKey(string(metricType), "Alloc", labels)
+	}
+
+	gaugeResponse := responses[key(constants.GaugeName)]
+	assert.Equal(t, string(constants.GaugeName), gaugeResponse.MType)
+	require.NotNil(t, gaugeResponse.Value)
+	assert.Equal(t, 1.5, *gaugeResponse.Value)
+	assert.Nil(t, gaugeResponse.Delta)
+
+	counterResponse := responses[key(constants.CounterName)]
+	assert.Equal(t, string(constants.CounterName), counterResponse.MType)
+	require.NotNil(t, counterResponse.Delta)
+	assert.Equal(t, int64(4), *counterResponse.Delta)
+	assert.Nil(t, counterResponse.Value)
+
+	infoResponse := responses[key(constants.InfoName)]
+	require.NotNil(t, infoResponse.Text)
+	assert.Equal(t, "heap", *infoResponse.Text)
+}
+
+func testBatchAppliedOnce(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+
+	_, err := storage.UpdateBatchOnce("batch-1", []models.Metric{counter("PollCount", 2, nil)}, ctx)
+	require.NoError(t, err)
+
+	// Повтор того же батча не меняет счётчик
+	_, err = storage.UpdateBatchOnce("batch-1", []models.Metric{counter("PollCount", 2, nil)}, ctx)
+	require.ErrorIs(t, err, metrics.ErrDuplicateBatch)
+	requireCounter(t, storage, "PollCount", nil, 2)
+
+	// Батч без идентификатора и батч с другим идентификатором применяются
+	_, err = storage.UpdateBatchOnce("", []models.Metric{counter("PollCount", 1, nil)}, ctx)
+	require.NoError(t, err)
+	_, err = storage.UpdateBatchOnce("batch-2", []models.Metric{counter("PollCount", 1, nil)}, ctx)
+	require.NoError(t, err)
+	requireCounter(t, storage, "PollCount", nil, 4)
+
+	// Отклонённый батч не запоминается
+	_, err = storage.UpdateBatchOnce("batch-3", []models.Metric{gauge("", 1, nil)}, ctx)
+	require.Error(t, err)
+	_, err = storage.UpdateBatchOnce("batch-3", []models.Metric{counter("PollCount", 1, nil)}, ctx)
+	require.NoError(t, err)
+	requireCounter(t, storage, "PollCount", nil, 5)
+}
+
+func testUpdateJSONReturnsStoredValue(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+
+	_, err := storage.UpdateJSON(counter("PollCount", 3, nil), ctx)
+	require.NoError(t, err)
+	response, err := storage.UpdateJSON(counter("PollCount", 4, nil), ctx)
+	require.NoError(t, err)
+	require.NotNil(t, response.Delta)
+	assert.Equal(t, int64(7), *response.Delta)
+	assert.Nil(t, response.Value)
+
+	response, err = storage.UpdateJSON(gauge("Alloc", 2.5, nil), ctx)
+	require.NoError(t, err)
+	require.NotNil(t, response.Value)
+	assert.Equal(t, 2.5, *response.Value)
+	assert.Nil(t, response.Delta)
+}
+
+func testGetJSON(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+	labels := models.Labels{"host": "web-1"}
+
+	require.NoError(t, storage.Update(gauge("Alloc", 1.25, labels), ctx))
+	require.NoError(t, storage.Update(counter("PollCount", 5, labels), ctx))
+
+	response, err := storage.GetJSON(gauge("Alloc", 0, labels), ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "Alloc", response.ID)
+	assert.Equal(t, "gauge", response.MType)
+	assert.Equal(t, map[string]string(labels), response.Labels)
+	require.NotNil(t, response.Value)
+	assert.Equal(t, 1.25, *response.Value)
+
+	response, err = storage.GetJSON(counter("PollCount", 0, labels), ctx)
+	require.NoError(t, err)
+	require.NotNil(t, response.Delta)
+	assert.Equal(t, int64(5), *response.Delta)
+}
+
+func testGetValueFormatting(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+
+	tests := []struct {
+		metric models.Metric
+		want   string
+	}{
+		{metric: gauge("Integer", 3, nil), want: "3"},
+		{metric: gauge("Fraction", 1.5, nil), want: "1.5"},
+		{metric: gauge("Rounded", 0.12345, nil), want: "0.123"},
+		{metric: gauge("Negative", -2.25, nil), want: "-2.25"},
+		{metric: gauge("Large", 123456789, nil), want: "123456789"},
+		{metric: counter("Counter", 42, nil), want: "42"},
+		{metric: counter("BigCounter", 1<<53+1, nil), want: "9007199254740993"},
+	}
+
+	for _, tt := range tests {
+		require.NoError(t, storage.Update(tt.metric, ctx))
+
+		value, err := storage.GetValue(string(tt.metric.GetType()), tt.metric.GetName(), nil, ctx)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, value, tt.metric.GetName())
+	}
+}
+
+func testMissingMetric(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+	require.NoError(t, storage.Update(gauge("Alloc", 1, nil), ctx))
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{name: "GetValue gauge", call: func() error {
+			_, err := storage.GetValue("gauge", "Missing", nil, ctx)
+			return err
+		}},
+		{name: "GetValue counter with gauge name", call: func() error {
+			_, err := storage.GetValue("counter", "Alloc", nil, ctx)
+			return err
+		}},
+		{name: "GetGauge with other labels", call: func() error {
+			_, err := storage.GetGauge("Alloc", models.Labels{"host": "web-1"}, ctx)
+			return err
+		}},
+		{name: "GetCounter", call: func() error {
+			_, err := storage.GetCounter("Missing", nil, ctx)
+			return err
+		}},
+		{name: "GetJSON", call: func() error {
+			_, err := storage.GetJSON(counter("Missing", 0, nil), ctx)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		assert.ErrorIs(t, tt.call(), metrics.ErrMetricNotFound, tt.name)
+	}
+}
+
+func testInvalidInput(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+
+	_, err := storage.GetValue("unknown", "Alloc", nil, ctx)
+	assert.ErrorIs(t, err, metrics.ErrInvalidMetricType)
+
+	_, err = storage.GetHistory("unknown", "Alloc", nil, time.Time{}, time.Now(), ctx)
+	assert.ErrorIs(t, err, metrics.ErrInvalidMetricType)
+
+	assert.ErrorIs(t, storage.UpdateGauge(gauge("", 1, nil), ctx), metrics.ErrInvalidMetricID)
+	assert.ErrorIs(t, storage.UpdateCounter(counter("", 1, nil), ctx), metrics.ErrInvalidMetricID)
+
+	// Батч с ошибкой не применяется целиком
+	_, err = storage.UpdateBatchJSON([]models.Metric{counter("PollCount", 1, nil), gauge("", 1, nil)}, ctx)
+	assert.ErrorIs(t, err, metrics.ErrInvalidMetricID)
+	_, err = storage.GetCounter("PollCount", nil, ctx)
+	assert.ErrorIs(t, err, metrics.ErrMetricNotFound)
+}
+
+func testHistory(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+	from := time.Now().Add(-time.Minute)
+
+	for _, v := range []float64{1, 2} {
+		require.NoError(t, storage.Update(gauge("Alloc", v, nil), ctx))
+	}
+	for _, d := range []int64{5, 7} {
+		require.NoError(t, storage.Update(counter("PollCount", d, nil), ctx))
+	}
+	to := time.Now().Add(time.Minute)
+
+	tests := []struct {
+		metricType string
+		name       string
+		from, to   time.Time
+		want       []float64
+	}{
+		{metricType: "gauge", name: "Alloc", from: from, to: to, want: []float64{1, 2}},
+		// Для counter в истории накопленное значение
+		{metricType: "counter", name: "PollCount", from: from, to: to, want: []float64{5, 12}},
+		{metricType: "gauge", name: "Alloc", from: to, to: to.Add(time.Minute), want: []float64{}},
+		{metricType: "gauge", name: "Missing", from: from, to: to, want: []float64{}},
+	}
+
+	for _, tt := range tests {
+		samples, err := storage.GetHistory(tt.metricType, tt.name, nil, tt.from, tt.to, ctx)
+		require.NoError(t, err)
+
+		values := make([]float64, 0, len(samples))
+		for _, sample := range samples {
+			values = append(values, sample.Value)
+		}
+		assert.Equal(t, tt.want, values, "%s %s", tt.metricType, tt.name)
+	}
+}
+
+func testHistoryOutOfOrder(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	at := func(name string, offset time.Duration, delta int64) *models.CounterMetric {
+		metric := counter(name, delta, nil)
+		metric.Timestamp = base.Add(offset)
+		return metric
+	}
+
+	// Например, повтор из буфера агента после более свежих данных
+	require.NoError(t, storage.Update(at("Requests", 3*time.Minute, 5), ctx))
+	require.NoError(t, storage.Update(at("Requests", time.Minute, 10), ctx))
+	require.NoError(t, storage.Update(at("Requests", 2*time.Minute, 15), ctx))
+
+	// В истории по значению на каждое обновление батча; более старый gauge не записывается
+	temperature := func(offset time.Duration, value float64) *models.GaugeMetric {
+		metric := gauge("Temperature", value, nil)
+		metric.Timestamp = base.Add(offset)
+		return metric
+	}
+	_, err := storage.UpdateBatchJSON([]models.Metric{
+		at("Errors", 2*time.Minute, 1),
+		at("Errors", time.Minute, 2),
+		temperature(time.Minute, 20),
+		temperature(3*time.Minute, 10),
+		temperature(2*time.Minute, 15),
+	}, ctx)
+	require.NoError(t, err)
+
+	history := func(metricType, name string, from, to time.Duration) []models.Sample {
+		samples, err := storage.GetHistory(metricType, name, nil, base.Add(from), base.Add(to), ctx)
+		require.NoError(t, err)
+		for i := range samples {
+			samples[i].Timestamp = samples[i].Timestamp.UTC()
+		}
+		return samples
+	}
+	sample := func(offset time.Duration, value float64) models.Sample {
+		return models.Sample{Timestamp: base.Add(offset).UTC(), Value: value}
+	}
+
+	// История упорядочена по времени наблюдения, а накопленное значение в каждой
+	// точке включает все приращения, наблюдавшиеся не позже неё
+	assert.Equal(t, []models.Sample{
+		sample(time.Minute, 10),
+		sample(2*time.Minute, 25),
+		sample(3*time.Minute, 30),
+	}, history("counter", "Requests", 0, time.Hour))
+	assert.Equal(t, []models.Sample{sample(2*time.Minute, 25)}, history("counter", "Requests", 2*time.Minute, 2*time.Minute))
+	assert.Equal(t, []models.Sample{
+		sample(time.Minute, 2),
+		sample(2*time.Minute, 3),
+	}, history("counter", "Errors", 0, time.Hour))
+	assert.Equal(t, []models.Sample{
+		sample(time.Minute, 20),
+		sample(3*time.Minute, 10),
+	}, history("gauge", "Temperature", 0, time.Hour))
+	requireCounter(t, storage, "Requests", nil, 30)
+}
+
+func testHistoryRetention(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+	now := time.Now()
+	at := func(name string, age time.Duration, value float64) *models.GaugeMetric {
+		metric := gauge(name, value, nil)
+		metric.Timestamp = now.Add(-age)
+		return metric
+	}
+
+	require.NoError(t, storage.Update(at("Alloc", 2*time.Hour, 1), ctx))
+	require.NoError(t, storage.Update(at("Alloc", time.Minute, 2), ctx))
+	require.NoError(t, storage.Update(at("Stale", 3*time.Hour, 5), ctx))
+	require.NoError(t, storage.PruneHistory(ctx))
+
+	history := func(name string) []float64 {
+		samples, err := storage.GetHistory("gauge", name, nil, now.Add(-24*time.Hour), now, ctx)
+		require.NoError(t, err)
+		values := make([]float64, 0, len(samples))
+		for _, sample := range samples {
+			values = append(values, sample.Value)
+		}
+		return values
+	}
+	assert.Equal(t, []float64{2}, history("Alloc"))
+	assert.Equal(t, []float64{}, history("Stale"))
+
+	// Удаляется только история: текущее значение метрики остаётся
+	requireGauge(t, storage, "Stale", nil, 5)
+}
+
+func testHistogramMergesBuckets(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+	labels := models.Labels{"path": "/update"}
+	buckets := []float64{0.1, 1}
+
+	require.NoError(t, storage.Update(histogram("Latency", buckets, []uint64{1, 2, 0}, 1.5, labels), ctx))
+
+	response, err := storage.UpdateJSON(histogram("Latency", buckets, []uint64{0, 1, 1}, 3.5, labels), ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "histogram", response.MType)
+	assert.Equal(t, []uint64{1, 3, 1}, response.Counts)
+	require.NotNil(t, response.Count)
+	assert.Equal(t, uint64(5), *response.Count)
+
+	// Повторы одной серии в батче складываются
+	_, err = storage.UpdateBatchJSON([]models.Metric{
+		histogram("Latency", buckets, []uint64{2, 0, 0}, 0.1, labels),
+		gauge("Latency", 1, nil),
+		histogram("Latency", buckets, []uint64{0, 0, 3}, 6, labels),
+	}, ctx)
+	require.NoError(t, err)
+
+	metric, err := storage.GetHistogram("Latency", labels, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, buckets, metric.Buckets)
+	assert.Equal(t, []uint64{3, 3, 4}, metric.Counts)
+	assert.InDelta(t, 11.1, metric.Sum, 1e-9)
+	assert.Equal(t, uint64(10), metric.Count)
+
+	value, err := storage.GetValue("histogram", "Latency", labels, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "10", value)
+
+	response, err = storage.GetJSON(&models.HistogramMetric{Name: "Latency", Type: constants.HistogramName, Labels: labels}, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, buckets, response.Buckets)
+	assert.Equal(t, []uint64{3, 3, 4}, response.Counts)
+
+	histograms, err := storage.GetAllHistograms(ctx)
+	require.NoError(t, err)
+	require.Len(t, histograms, 1)
+	assert.Equal(t, uint64(10), histograms[models.SeriesKey("Latency", labels)].Count)
+
+	_, err = storage.GetHistogram("Latency", nil, ctx)
+	assert.ErrorIs(t, err, metrics.ErrMetricNotFound)
+}
+
+func testHistogramBucketsMismatch(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+
+	require.NoError(t, storage.Update(histogram("Latency", []float64{0.1, 1}, []uint64{1, 0, 0}, 0.05, nil), ctx))
+
+	err := storage.Update(histogram("Latency", []float64{0.5}, []uint64{1, 0}, 0.2, nil), ctx)
+	assert.ErrorIs(t, err, metrics.ErrInvalidMetricValue)
+
+	// Батч с несовместимой гистограммой не применяется целиком
+	_, err = storage.UpdateBatchJSON([]models.Metric{
+		counter("PollCount", 1, nil),
+		histogram("Latency", []float64{0.5}, []uint64{1, 0}, 0.2, nil),
+	}, ctx)
+	assert.ErrorIs(t, err, metrics.ErrInvalidMetricValue)
+	_, err = storage.GetCounter("PollCount", nil, ctx)
+	assert.ErrorIs(t, err, metrics.ErrMetricNotFound)
+
+	// Число наблюдений по корзинам должно совпадать с count
+	broken := histogram("Latency", []float64{0.1, 1}, []uint64{1, 0, 0}, 0.05, nil)
+	broken.Count = 3
+	assert.ErrorIs(t, storage.Update(broken, ctx), metrics.ErrInvalidMetricValue)
+
+	metric, err := storage.GetHistogram("Latency", nil, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 0, 0}, metric.Counts)
+}
+
+func testSummaryMergesSketches(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+	labels := models.Labels{"path": "/update"}
+	alpha := sketch.DefaultRelativeAccuracy
+
+	require.NoError(t, storage.Update(summary(t, "Duration", alpha, labels, 1, 2), ctx))
+
+	response, err := storage.UpdateJSON(summary(t, "Duration", alpha, labels, 3), ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "summary", response.MType)
+	require.NotNil(t, response.Count)
+	assert.Equal(t, uint64(3), *response.Count)
+	require.NotNil(t, response.Sketch)
+
+	// Повторы одной серии в батче складываются
+	_, err = storage.UpdateBatchJSON([]models.Metric{
+		summary(t, "Duration", alpha, labels, 4, 5, 6),
+		gauge("Duration", 1, nil),
+		summary(t, "Duration", alpha, labels, 7, 8, 9, 10),
+	}, ctx)
+	require.NoError(t, err)
+
+	metric, err := storage.GetSummary("Duration", labels, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(10), metric.Sketch.Count())
+	assert.InDelta(t, 55, metric.Sketch.Sum(), 1e-9)
+
+	p99, err := metric.Sketch.Quantile(0.99)
+	require.NoError(t, err)
+	assert.InEpsilon(t, 9, p99, alpha)
+
+	// В /value/ без квантиля — медиана
+	value, err := storage.GetValue("summary", "Duration", labels, ctx)
+	require.NoError(t, err)
+	median, err := metrics.SummaryQuantile(metric, 0.5)
+	require.NoError(t, err)
+	assert.Equal(t, median, value)
+
+	response, err = storage.GetJSON(&models.SummaryMetric{Name: "Duration", Type: constants.SummaryName, Labels: labels}, ctx)
+	require.NoError(t, err)
+	require.NotNil(t, response.Count)
+	assert.Equal(t, uint64(10), *response.Count)
+
+	summaries, err := storage.GetAllSummaries(ctx)
+	require.NoError(t, err)
+	require.Len(t, summaries, 1)
+	assert.Equal(t, uint64(10), summaries[models.SeriesKey("Duration", labels)].Sketch.Count())
+
+	_, err = storage.GetSummary("Duration", nil, ctx)
+	assert.ErrorIs(t, err, metrics.ErrMetricNotFound)
+}
+
+func testSummaryAccuracyMismatch(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+
+	require.NoError(t, storage.Update(summary(t, "Duration", 0.01, nil, 1), ctx))
+
+	err := storage.Update(summary(t, "Duration", 0.05, nil, 2), ctx)
+	assert.ErrorIs(t, err, metrics.ErrInvalidMetricValue)
+
+	// Пустая summary не является обновлением
+	assert.ErrorIs(t, storage.Update(summary(t, "Duration", 0.01, nil), ctx), metrics.ErrInvalidMetricValue)
+
+	// Батч с несовместимой summary не применяется целиком
+	_, err = storage.UpdateBatchJSON([]models.Metric{
+		counter("PollCount", 1, nil),
+		summary(t, "Duration", 0.05, nil, 2),
+	}, ctx)
+	assert.ErrorIs(t, err, metrics.ErrInvalidMetricValue)
+	_, err = storage.GetCounter("PollCount", nil, ctx)
+	assert.ErrorIs(t, err, metrics.ErrMetricNotFound)
+
+	metric, err := storage.GetSummary("Duration", nil, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), metric.Sketch.Count())
+}
+
+func testSetCountsDistinctMembers(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+	labels := models.Labels{"path": "/update"}
+	precision := uint8(sketch.DefaultHLLPrecision)
+
+	require.NoError(t, storage.Update(set(t, "Users", precision, labels, "alice", "bob"), ctx))
+
+	response, err := storage.UpdateJSON(set(t, "Users", precision, labels, "bob", "carol"), ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "set", response.MType)
+	require.NotNil(t, response.Count)
+	assert.Equal(t, uint64(3), *response.Count)
+	require.NotNil(t, response.HLL)
+
+	// Батчи от разных агентов с пересекающимися элементами объединяются
+	_, err = storage.UpdateBatchJSON([]models.Metric{
+		set(t, "Users", precision, labels, users(0, 600)...),
+		gauge("Users", 1, nil),
+		set(t, "Users", precision, labels, users(400, 1000)...),
+	}, ctx)
+	require.NoError(t, err)
+
+	metric, err := storage.GetSet("Users", labels, ctx)
+	require.NoError(t, err)
+	assert.InEpsilon(t, 1003, metric.HLL.Estimate(), 0.05)
+
+	value, err := storage.GetValue("set", "Users", labels, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, strconv.FormatUint(metric.HLL.Estimate(), 10), value)
+
+	response, err = storage.GetJSON(&models.SetMetric{Name: "Users", Type: constants.SetName, Labels: labels}, ctx)
+	require.NoError(t, err)
+	require.NotNil(t, response.Count)
+	assert.Equal(t, metric.HLL.Estimate(), *response.Count)
+
+	sets, err := storage.GetAllSets(ctx)
+	require.NoError(t, err)
+	require.Len(t, sets, 1)
+	assert.Equal(t, metric.HLL.State(), sets[models.SeriesKey("Users", labels)].HLL.State())
+
+	_, err = storage.GetSet("Users", nil, ctx)
+	assert.ErrorIs(t, err, metrics.ErrMetricNotFound)
+}
+
+func testSetPrecisionMismatch(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+
+	require.NoError(t, storage.Update(set(t, "Users", sketch.DefaultHLLPrecision, nil, "alice"), ctx))
+
+	err := storage.Update(set(t, "Users", 10, nil, "bob"), ctx)
+	assert.ErrorIs(t, err, metrics.ErrInvalidMetricValue)
+
+	// Пустой set не является обновлением
+	assert.ErrorIs(t, storage.Update(set(t, "Users", sketch.DefaultHLLPrecision, nil), ctx), metrics.ErrInvalidMetricValue)
+
+	// Батч с несовместимым set не применяется целиком
+	_, err = storage.UpdateBatchJSON([]models.Metric{
+		counter("PollCount", 1, nil),
+		set(t, "Users", 10, nil, "bob"),
+	}, ctx)
+	assert.ErrorIs(t, err, metrics.ErrInvalidMetricValue)
+	_, err = storage.GetCounter("PollCount", nil, ctx)
+	assert.ErrorIs(t, err, metrics.ErrMetricNotFound)
+
+	metric, err := storage.GetSet("Users", nil, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), metric.HLL.Estimate())
+}
+
+func testInfoLastWriteWins(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+	labels := models.Labels{"host": "web-1"}
+
+	require.NoError(t, storage.Update(info("Version", "1.2.3", labels), ctx))
+
+	response, err := storage.UpdateJSON(info("Version", "1.3.0", labels), ctx)
+	require.NoError(t, err)
+	require.NotNil(t, response.Text)
+	assert.Equal(t, "1.3.0", *response.Text)
+
+	// В батче побеждает последнее значение серии
+	_, err = storage.UpdateBatchJSON([]models.Metric{
+		info("Leader", "false", nil),
+		gauge("Leader", 1, nil),
+		info("Leader", "true", nil),
+	}, ctx)
+	require.NoError(t, err)
+
+	value, err := storage.GetValue("info", "Version", labels, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "1.3.0", value)
+
+	response, err = storage.GetJSON(&models.InfoMetric{Name: "Leader", Type: constants.InfoName}, ctx)
+	require.NoError(t, err)
+	require.NotNil(t, response.Text)
+	assert.Equal(t, "true", *response.Text)
+
+	infos, err := storage.GetAllInfos(ctx)
+	require.NoError(t, err)
+	assert.Len(t, infos, 2)
+	assert.Equal(t, "1.3.0", infos[models.SeriesKey("Version", labels)].Value)
+
+	// Слишком длинное значение отклоняется и не заменяет сохранённое
+	tooLong := info("Version", strings.Repeat("x", models.MaxInfoLength+1), labels)
+	assert.ErrorIs(t, storage.Update(tooLong, ctx), metrics.ErrInvalidMetricValue)
+	_, err = storage.UpdateBatchJSON([]models.Metric{tooLong}, ctx)
+	assert.ErrorIs(t, err, metrics.ErrInvalidMetricValue)
+
+	metric, err := storage.GetInfo("Version", labels, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "1.3.0", metric.Value)
+
+	_, err = storage.GetInfo("Version", nil, ctx)
+	assert.ErrorIs(t, err, metrics.ErrMetricNotFound)
+}
+
+func testGaugeOrderedByTimestamp(t *testing.T, storage metrics.MetricStorage) {
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour).Truncate(time.Millisecond)
+	at := func(offset time.Duration, value float64) *models.GaugeMetric {
+		metric := gauge("Temperature", value, nil)
+		metric.Timestamp = base.Add(offset)
+		return metric
+	}
+
+	require.NoError(t, storage.Update(at(2*time.Second, 20), ctx))
+
+	// Более раннее наблюдение пришло позже и не заменяет сохранённое; в ответе текущее значение
+	response, err := storage.UpdateJSON(at(time.Second, 10), ctx)
+	require.NoError(t, err)
+	require.NotNil(t, response.Value)
+	assert.Equal(t, 20.0, *response.Value)
+	requireGauge(t, storage, "Temperature", nil, 20)
+
+	// В батче побеждает самое позднее наблюдение, а не последнее по порядку
+	responses, err := storage.UpdateBatchJSON([]models.Metric{
+		at(4*time.Second, 30),
+		at(3*time.Second, 25),
+		at(time.Second, 15),
+	}, ctx)
+	require.NoError(t, err)
+	requireGauge(t, storage, "Temperature", nil, 30)
+	requireBatchGauge(t, responses, "gauge Temperature", 30, base.Add(4*time.Second))
+
+	// Батч только из устаревших наблюдений отвечает сохранённым значением и его временем
+	responses, err = storage.UpdateBatchJSON([]models.Metric{at(3*time.Second, 5)}, ctx)
+	require.NoError(t, err)
+	requireBatchGauge(t, responses, "gauge Temperature", 30, base.Add(4*time.Second))
+	requireGauge(t, storage, "Temperature", nil, 30)
+
+	gauges, _, err := storage.GetAll(ctx)
+	require.NoError(t, err)
+	assert.WithinDuration(t, base.Add(4*time.Second), gauges[models.SeriesKey("Temperature", nil)].Timestamp, 0)
+
+	// История записывается по времени наблюдения, отброшенные значения в неё не попадают
+	samples, err := storage.GetHistory("gauge", "Temperature", nil, base, base.Add(time.Minute), ctx)
+	require.NoError(t, err)
+	require.Len(t, samples, 2)
+	assert.Equal(t, 20.0, samples[0].Value)
+	assert.WithinDuration(t, base.Add(2*time.Second), samples[0].Timestamp, 0)
+	assert.Equal(t, 30.0, samples[1].Value)
+	assert.WithinDuration(t, base.Add(4*time.Second), samples[1].Timestamp, 0)
+
+	// Приращения counter складываются независимо от времени наблюдения
+	later := counter("Requests", 5, nil)
+	later.Timestamp = base.Add(3 * time.Second)
+	earlier := counter("Requests", 2, nil)
+	earlier.Timestamp = base.Add(time.Second)
+	require.NoError(t, storage.Update(later, ctx))
+	require.NoError(t, storage.Update(earlier, ctx))
+	requireCounter(t, storage, "Requests", nil, 7)
+
+	responses, err = storage.UpdateBatchJSON([]models.Metric{counter("Requests", 3, nil)}, ctx)
+	require.NoError(t, err)
+	require.Contains(t, responses, "counter Requests")
+	require.NotNil(t, responses["counter Requests"].Delta)
+	assert.Equal(t, int64(10), *responses["counter Requests"].Delta)
+}
+
+func requireBatchGauge(t *testing.T, responses map[string]dto.Metrics, key string, value float64, observedAt time.Time) {
+	t.Helper()
+	require.Contains(t, responses, key)
+	require.NotNil(t, responses[key].Value)
+	assert.Equal(t, value, *responses[key].Value)
+	require.NotNil(t, responses[key].Timestamp)
+	assert.Equal(t, observedAt.UnixMilli(), *responses[key].Timestamp)
+}
@@ -0,0 +1,104 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+)
+
+var ErrInvalidTimestamp = errors.New("invalid metric timestamp")
+
+// Обработка наблюдений со временем вне допустимого окна
+type TimestampMode string
+
+const (
+	TimestampReject TimestampMode = "reject" // запрос отклоняется с ErrInvalidTimestamp
+	TimestampClamp  TimestampMode = "clamp"  // время прижимается к границе окна
+	TimestampAccept TimestampMode = "accept" // время клиента сохраняется без проверки
+)
+
+// Допустимое отклонение времени наблюдения от часов сервера; 0 — без ограничения.
+// Пустой Mode означает TimestampClamp: батч агента с убежавшими вперёд часами
+// сохраняется, а не отклоняется целиком.
+type TimestampPolicy struct {
+	Mode      TimestampMode
+	MaxAge    time.Duration // насколько наблюдение может быть старше времени получения
+	MaxFuture time.Duration // насколько наблюдение может опережать время получения
+}
+
+func (p TimestampPolicy) Validate() error {
+	switch p.Mode {
+	case "", TimestampReject, TimestampClamp, TimestampAccept:
+	default:
+		return fmt.Errorf("%w: unknown mode %q", ErrInvalidTimestamp, p.Mode)
+	}
+	if p.MaxAge < 0 || p.MaxFuture < 0 {
+		return fmt.Errorf("%w: negative limit", ErrInvalidTimestamp)
+	}
+	return nil
+}
+
+// Время наблюдения, которое запишет хранилище: метка клиента или время получения,
+// если метки нет. Метка вне окна отклоняется или прижимается к границе по Mode.
+func (p TimestampPolicy) observedAt(t, now time.Time) (time.Time, error) {
+	if t.IsZero() {
+		return now, nil
+	}
+	if p.Mode == TimestampAccept {
+		return t, nil
+	}
+
+	clamp := p.Mode == "" || p.Mode == TimestampClamp
+	if p.MaxAge > 0 && t.Before(now.Add(-p.MaxAge)) {
+		if clamp {
+			return now.Add(-p.MaxAge), nil
+		}
+		return time.Time{}, fmt.Errorf("%w: %s is older than %s", ErrInvalidTimestamp, t.UTC().Format(time.RFC3339Nano), p.MaxAge)
+	}
+	if p.MaxFuture > 0 && t.After(now.Add(p.MaxFuture)) {
+		if clamp {
+			return now.Add(p.MaxFuture), nil
+		}
+		return time.Time{}, fmt.Errorf("%w: %s is more than %s in the future", ErrInvalidTimestamp, t.UTC().Format(time.RFC3339Nano), p.MaxFuture)
+	}
+	return t, nil
+}
+
+// Время наблюдения из миллисекунд Unix; без метки возвращается нулевое время
+func observationTime(timestamp *int64) (time.Time, error) {
+	if timestamp == nil {
+		return time.Time{}, nil
+	}
+	if *timestamp <= 0 {
+		return time.Time{}, fmt.Errorf("%w: %d", ErrInvalidTimestamp, *timestamp)
+	}
+	return time.UnixMilli(*timestamp), nil
+}
+
+// Время наблюдения из параметра timestamp пути /update/. Сохраняется для gauge
+// и counter; у остальных типов метка только проверяется.
+func SetObservationTime(metric models.Metric, timestamp *int64) error {
+	observedAt, err := observationTime(timestamp)
+	if err != nil {
+		return err
+	}
+
+	switch m := metric.(type) {
+	case *models.GaugeMetric:
+		m.Timestamp = observedAt
+	case *models.CounterMetric:
+		m.Timestamp = observedAt
+	}
+	return nil
+}
+
+// Миллисекунды Unix для DTO; nil для нулевого времени
+func timestampMillis(t time.Time) *int64 {
+	if t.IsZero() {
+		return nil
+	}
+	ms := t.UnixMilli()
+	return &ms
+}
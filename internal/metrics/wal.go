@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+)
+
+// Суффикс журнала предзаписи (WAL) рядом с файлом метрик
+const walSuffix = ".wal"
+
+func walPath(fileStoragePath string) string {
+	return fileStoragePath + walSuffix
+}
+
+// Строка журнала или снимка: итоговое значение метрики либо отметка о применённом батче.
+// Строки прежних версий содержат только метрику и читаются как раньше.
+type fileRecord struct {
+	*dto.Metrics
+	Batch *batchRecord `json:"batch,omitempty"`
+}
+
+type batchRecord struct {
+	ID        string `json:"id"`
+	AppliedAt int64  `json:"applied_at"` // миллисекунды Unix
+}
+
+// Период fsync журнала в периодическом режиме
+var walSyncInterval = time.Second
+
+// Подменяется в тестах для подсчёта fsync журнала
+var syncFile = (*os.File).Sync
+
+// Журнал обновлений, принятых после последнего снимка метрик.
+// В журнал пишутся итоговые значения метрик после обновления, а не приращения,
+// поэтому повторное применение журнала поверх снимка не искажает счётчики.
+//
+// Каждое обновление попадает в журнал до ответа клиенту, поэтому падение процесса
+// не теряет подтверждённых обновлений в обоих режимах. Различается только fsync:
+// при STORE_INTERVAL=0 он выполняется перед ответом, и обновление переживает отключение
+// питания; в периодическом режиме журнал сбрасывается на диск раз в walSyncInterval,
+// и при отключении питания теряются обновления не более чем за последний период.
+type wal struct {
+	file *os.File
+	// fsync после каждой записи (STORE_INTERVAL=0)
+	sync bool
+	// Есть записи, ещё не сброшенные на диск
+	dirty bool
+}
+
+func openWAL(path string, sync bool) (*wal, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось открыть журнал метрик: %w", err)
+	}
+	return &wal{file: file, sync: sync}, nil
+}
+
+// Запись батча одним вызовом write. Отметка батча batch (если есть) пишется последней:
+// при восстановлении идентификатор считается применённым вместе со всеми его метриками.
+func (w *wal) Append(records []dto.Metrics, batch *batchRecord) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for i := range records {
+		if err := encoder.Encode(fileRecord{Metrics: &records[i]}); err != nil {
+			return err
+		}
+	}
+	if batch != nil {
+		if err := encoder.Encode(fileRecord{Batch: batch}); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.file.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	if w.sync {
+		return syncFile(w.file)
+	}
+	w.dirty = true
+	return nil
+}
+
+// Сброс на диск записей, накопленных с прошлого вызова
+func (w *wal) Sync() error {
+	if !w.dirty {
+		return nil
+	}
+	if err := syncFile(w.file); err != nil {
+		return err
+	}
+	w.dirty = false
+	return nil
+}
+
+// Очистка журнала после записи снимка
+func (w *wal) Reset() error {
+	if err := w.file.Truncate(0); err != nil {
+		return err
+	}
+	if err := syncFile(w.file); err != nil {
+		return err
+	}
+	w.dirty = false
+	return nil
+}
+
+func (w *wal) Close() error {
+	return w.file.Close()
+}
+
+// Чтение записей журнала по порядку; отсутствие файла не считается ошибкой.
+// Каждая запись заканчивается переводом строки, поэтому хвост без него —
+// недописанная при сбое запись, которая не была подтверждена, и она отбрасывается.
+func replayWAL(path string, apply func(record fileRecord) error) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать журнал метрик: %w", err)
+	}
+
+	data = data[:bytes.LastIndexByte(data, '\n')+1]
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, '\n')
+		line := data[:end]
+		data = data[end+1:]
+
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		var record fileRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return fmt.Errorf("ошибка при декодировании журнала: %w", err)
+		}
+		if err := apply(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,384 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+	serverConfig "github.com/GarikMirzoyan/metricalert/internal/server/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func walTestConfig(t *testing.T, storeInterval time.Duration) serverConfig.Config {
+	return serverConfig.Config{
+		Restore:         true,
+		StoreInterval:   storeInterval,
+		FileStoragePath: filepath.Join(t.TempDir(), "metrics.json"),
+	}
+}
+
+func addCounter(t *testing.T, ms *MemStorage, delta int64) {
+	t.Helper()
+	require.NoError(t, ms.UpdateCounter(&models.CounterMetric{Name: "PollCount", Type: constants.CounterName, Value: delta}, context.Background()))
+}
+
+func setGauge(t *testing.T, ms *MemStorage, value float64) {
+	t.Helper()
+	require.NoError(t, ms.UpdateGauge(&models.GaugeMetric{Name: "Alloc", Type: constants.GaugeName, Value: value}, context.Background()))
+}
+
+// Перезапуск сервера: новое хранилище восстанавливается с диска и включает журнал
+func restart(t *testing.T, config serverConfig.Config) *MemStorage {
+	t.Helper()
+	ms := NewMemStorage()
+	require.NoError(t, ms.LoadMetricsFromFile(config))
+	require.NoError(t, ms.EnableWAL(config))
+	return ms
+}
+
+func assertState(t *testing.T, ms *MemStorage, gauge, counter string) {
+	t.Helper()
+	ctx := context.Background()
+
+	value, err := ms.GetValue("gauge", "Alloc", nil, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, gauge, value)
+
+	value, err = ms.GetValue("counter", "PollCount", nil, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, counter, value)
+}
+
+func TestWAL_SyncWritesSurviveRestart(t *testing.T) {
+	config := serverConfig.Config{
+		Restore:         true,
+		FileStoragePath: filepath.Join(t.TempDir(), "metrics.json"),
+	}
+	ctx := context.Background()
+
+	ms := NewMemStorage()
+	require.NoError(t, ms.EnableWAL(config))
+
+	require.NoError(t, ms.UpdateGauge(&models.GaugeMetric{Name: "Alloc", Type: constants.GaugeName, Value: 1.5}, ctx))
+	require.NoError(t, ms.UpdateCounter(&models.CounterMetric{Name: "PollCount", Type: constants.CounterName, Value: 2}, ctx))
+
+	// Повтор counter внутри батча должен дать точную сумму
+	responses, err := ms.UpdateBatchJSON([]models.Metric{
+		&models.CounterMetric{Name: "PollCount", Type: constants.CounterName, Value: 3},
+		&models.GaugeMetric{Name: "Alloc", Type: constants.GaugeName, Value: 2.5},
+		&models.CounterMetric{Name: "PollCount", Type: constants.CounterName, Value: 4},
+	}, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(9), *responses["counter PollCount"].Delta)
+
+	// Без SaveMetricsToFile: всё должно восстановиться из журнала
+	restored := NewMemStorage()
+	require.NoError(t, restored.LoadMetricsFromFile(config))
+
+	value, err := restored.GetValue("gauge", "Alloc", nil, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "2.5", value)
+
+	value, err = restored.GetValue("counter", "PollCount", nil, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "9", value)
+
+	// Повторное восстановление из того же журнала не удваивает счётчики
+	require.NoError(t, restored.LoadMetricsFromFile(config))
+	value, err = restored.GetValue("counter", "PollCount", nil, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "9", value)
+}
+
+func TestWAL_SaveCompacts(t *testing.T) {
+	config := serverConfig.Config{
+		Restore:         true,
+		FileStoragePath: filepath.Join(t.TempDir(), "metrics.json"),
+	}
+	ctx := context.Background()
+
+	ms := NewMemStorage()
+	require.NoError(t, ms.EnableWAL(config))
+	for i := 0; i < 10; i++ {
+		require.NoError(t, ms.UpdateCounter(&models.CounterMetric{Name: "PollCount", Type: constants.CounterName, Value: 1}, ctx))
+	}
+
+	info, err := os.Stat(walPath(config.FileStoragePath))
+	require.NoError(t, err)
+	assert.NotZero(t, info.Size())
+
+	require.NoError(t, ms.SaveMetricsToFile(config))
+	info, err = os.Stat(walPath(config.FileStoragePath))
+	require.NoError(t, err)
+	assert.Zero(t, info.Size())
+
+	// После сжатия обновления снова пишутся в журнал
+	require.NoError(t, ms.UpdateCounter(&models.CounterMetric{Name: "PollCount", Type: constants.CounterName, Value: 1}, ctx))
+
+	restored := NewMemStorage()
+	require.NoError(t, restored.LoadMetricsFromFile(config))
+	value, err := restored.GetValue("counter", "PollCount", nil, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "11", value)
+}
+
+func TestWAL_RecoversUpdatesSinceSnapshot(t *testing.T) {
+	// В периодическом режиме обновления между снимками тоже не теряются при падении процесса
+	config := walTestConfig(t, time.Hour)
+
+	ms := restart(t, config)
+	setGauge(t, ms, 1)
+	addCounter(t, ms, 2)
+	require.NoError(t, ms.SaveMetricsToFile(config))
+
+	setGauge(t, ms, 3)
+	addCounter(t, ms, 5)
+
+	assertState(t, restart(t, config), "3", "7")
+}
+
+func TestWAL_CrashDuringSnapshotKeepsPreviousSnapshot(t *testing.T) {
+	config := walTestConfig(t, 0)
+
+	ms := restart(t, config)
+	setGauge(t, ms, 1)
+	addCounter(t, ms, 2)
+	require.NoError(t, ms.SaveMetricsToFile(config))
+	previous, err := os.ReadFile(config.FileStoragePath)
+	require.NoError(t, err)
+
+	setGauge(t, ms, 4)
+	addCounter(t, ms, 3)
+
+	// Сбой до переименования: временный файл записан, но не заменил снимок
+	renameFile = func(oldpath, newpath string) error { return errors.New("crash") }
+	t.Cleanup(func() { renameFile = os.Rename })
+	require.Error(t, ms.SaveMetricsToFile(config))
+	renameFile = os.Rename
+
+	current, err := os.ReadFile(config.FileStoragePath)
+	require.NoError(t, err)
+	assert.Equal(t, previous, current)
+
+	// Недописанный временный файл от сбоя при предыдущем запуске не мешает восстановлению
+	require.NoError(t, os.WriteFile(config.FileStoragePath+".tmp", []byte(`{"id":"Alloc","ty`), 0o644))
+
+	assertState(t, restart(t, config), "4", "5")
+}
+
+func TestWAL_CrashBeforeWALResetDoesNotDoubleCounters(t *testing.T) {
+	config := walTestConfig(t, 0)
+
+	ms := restart(t, config)
+	addCounter(t, ms, 2)
+	setGauge(t, ms, 1)
+	addCounter(t, ms, 3)
+
+	// Сбой после замены снимка, но до очистки журнала: его записи уже есть в снимке
+	walData, err := os.ReadFile(walPath(config.FileStoragePath))
+	require.NoError(t, err)
+	require.NoError(t, ms.SaveMetricsToFile(config))
+	require.NoError(t, os.WriteFile(walPath(config.FileStoragePath), walData, 0o644))
+
+	assertState(t, restart(t, config), "1", "5")
+}
+
+func TestWAL_TornTailIsDiscarded(t *testing.T) {
+	config := walTestConfig(t, 0)
+
+	ms := restart(t, config)
+	setGauge(t, ms, 1)
+	addCounter(t, ms, 2)
+
+	// Сбой посреди записи: последняя запись не дописана и не была подтверждена
+	file, err := os.OpenFile(walPath(config.FileStoragePath), os.O_WRONLY|os.O_APPEND, 0o644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"id":"PollCount","type":"counter","de`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	restored := restart(t, config)
+	assertState(t, restored, "1", "2")
+
+	// После восстановления журнал начинается заново и принимает новые записи
+	addCounter(t, restored, 1)
+	assertState(t, restart(t, config), "1", "3")
+}
+
+func TestWAL_CorruptRecordInTheMiddleFails(t *testing.T) {
+	config := walTestConfig(t, 0)
+	require.NoError(t, os.WriteFile(walPath(config.FileStoragePath), []byte("not json\n{}\n"), 0o644))
+
+	err := NewMemStorage().LoadMetricsFromFile(config)
+	assert.Error(t, err)
+}
+
+func TestWAL_RecoversHistograms(t *testing.T) {
+	config := walTestConfig(t, 0)
+	ctx := context.Background()
+
+	observe := func(ms *MemStorage, value float64) {
+		t.Helper()
+		metric := models.NewHistogram("Latency", nil, []float64{0.1, 1})
+		metric.Observe(value)
+		require.NoError(t, ms.UpdateHistogram(metric, ctx))
+	}
+
+	ms := restart(t, config)
+	observe(ms, 0.05)
+	require.NoError(t, ms.SaveMetricsToFile(config))
+	observe(ms, 5)
+
+	restored, err := restart(t, config).GetHistogram("Latency", nil, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []uint64{1, 0, 1}, restored.Counts)
+	assert.Equal(t, uint64(2), restored.Count)
+	assert.Equal(t, 5.05, restored.Sum)
+}
+
+func TestWAL_RecoversSummaries(t *testing.T) {
+	config := walTestConfig(t, 0)
+	ctx := context.Background()
+
+	observe := func(ms *MemStorage, value float64) {
+		t.Helper()
+		metric, err := newSummaryObservation("Duration", nil, value)
+		require.NoError(t, err)
+		require.NoError(t, ms.UpdateSummary(metric, ctx))
+	}
+
+	ms := restart(t, config)
+	observe(ms, 2)
+	require.NoError(t, ms.SaveMetricsToFile(config))
+	observe(ms, 8)
+
+	restored, err := restart(t, config).GetSummary("Duration", nil, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), restored.Sketch.Count())
+	assert.Equal(t, 10.0, restored.Sketch.Sum())
+
+	maximum, err := restored.Sketch.Quantile(1)
+	require.NoError(t, err)
+	assert.Equal(t, 8.0, maximum)
+}
+
+func TestWAL_RecoversSets(t *testing.T) {
+	config := walTestConfig(t, 0)
+	ctx := context.Background()
+
+	add := func(ms *MemStorage, member string) {
+		t.Helper()
+		metric, err := NewMetric("set", "Users", member, nil)
+		require.NoError(t, err)
+		require.NoError(t, ms.Update(metric, ctx))
+	}
+
+	ms := restart(t, config)
+	add(ms, "alice")
+	require.NoError(t, ms.SaveMetricsToFile(config))
+	add(ms, "bob")
+	add(ms, "alice")
+
+	restored, err := restart(t, config).GetSet("Users", nil, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), restored.HLL.Estimate())
+}
+
+func TestWAL_RecoversObservationTime(t *testing.T) {
+	config := walTestConfig(t, 0)
+	ctx := context.Background()
+	observedAt := time.Now().Add(-time.Minute).Truncate(time.Millisecond)
+
+	set := func(ms *MemStorage, value float64, at time.Time) {
+		t.Helper()
+		metric := &models.GaugeMetric{Name: "Alloc", Type: constants.GaugeName, Value: value, Timestamp: at}
+		require.NoError(t, ms.UpdateGauge(metric, ctx))
+	}
+
+	ms := restart(t, config)
+	set(ms, 1, observedAt)
+	require.NoError(t, ms.SaveMetricsToFile(config))
+	set(ms, 2, observedAt.Add(time.Second))
+
+	// После перезапуска более старое наблюдение по-прежнему не заменяет значение
+	ms = restart(t, config)
+	set(ms, 3, observedAt.Add(-time.Second))
+
+	metric, err := ms.GetGauge("Alloc", nil, ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2.0, metric.Value)
+	assert.True(t, metric.Timestamp.Equal(observedAt.Add(time.Second)))
+}
+
+func TestWAL_RecoversAppliedBatches(t *testing.T) {
+	config := walTestConfig(t, time.Hour)
+	ctx := context.Background()
+	batch := func(ms *MemStorage, id string) error {
+		_, err := ms.UpdateBatchOnce(id, []models.Metric{&models.CounterMetric{Name: "PollCount", Type: constants.CounterName, Value: 2}}, ctx)
+		return err
+	}
+
+	ms := restart(t, config)
+	setGauge(t, ms, 1)
+	require.NoError(t, batch(ms, "first"))
+	require.NoError(t, ms.SaveMetricsToFile(config))
+	require.NoError(t, batch(ms, "second"))
+
+	// Повтор после перезапуска не применяется: "first" восстановлен из снимка, "second" — из журнала
+	ms = restart(t, config)
+	require.ErrorIs(t, batch(ms, "first"), ErrDuplicateBatch)
+	require.ErrorIs(t, batch(ms, "second"), ErrDuplicateBatch)
+	assertState(t, ms, "1", "4")
+
+	// По истечении BatchTTL идентификатор забывается
+	ms = NewMemStorageWithOptions(Options{BatchTTL: time.Millisecond})
+	require.NoError(t, ms.LoadMetricsFromFile(config))
+	time.Sleep(5 * time.Millisecond)
+	require.NoError(t, batch(ms, "first"))
+	assertState(t, ms, "1", "6")
+}
+
+func TestWAL_PeriodicModeSyncsWithinInterval(t *testing.T) {
+	var syncs atomic.Int64
+	syncFile = func(f *os.File) error {
+		syncs.Add(1)
+		return f.Sync()
+	}
+	walSyncInterval = 10 * time.Millisecond
+	t.Cleanup(func() {
+		syncFile = (*os.File).Sync
+		walSyncInterval = time.Second
+	})
+
+	config := walTestConfig(t, time.Hour)
+	ms := restart(t, config)
+
+	// Обновление подтверждается без fsync
+	before := syncs.Load()
+	addCounter(t, ms, 1)
+	assert.Equal(t, before, syncs.Load())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ms.StartMetricSaving(config, zap.NewNop(), ctx)
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	// Журнал сбрасывается на диск в течение walSyncInterval, а без новых записей fsync не повторяется
+	require.Eventually(t, func() bool { return syncs.Load() == before+1 }, time.Second, 5*time.Millisecond)
+	time.Sleep(5 * walSyncInterval)
+	assert.Equal(t, before+1, syncs.Load())
+}
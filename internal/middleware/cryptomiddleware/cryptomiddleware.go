@@ -0,0 +1,56 @@
+package cryptomiddleware
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"io"
+	"net/http"
+
+	"github.com/GarikMirzoyan/metricalert/internal/encryption"
+)
+
+// Middleware для расшифровки входящих данных закрытым ключом сервера.
+// Расшифровываются запросы, помеченные заголовком encryption.HeaderName; POST с телом
+// без заголовка отклоняется, чтобы при заданном ключе шифрование не было необязательным.
+// Должен стоять до GzipDecompression, так как агент шифрует уже сжатое тело.
+func Decrypt(next http.Handler, key *rsa.PrivateKey) http.Handler {
+	if key == nil {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(encryption.HeaderName) != encryption.HeaderValue && r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		encrypted, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "Error reading request body", http.StatusBadRequest)
+			return
+		}
+		r.Body.Close()
+
+		// POST без тела (обновление через путь /update/...) шифровать нечего
+		if r.Header.Get(encryption.HeaderName) != encryption.HeaderValue {
+			if len(encrypted) > 0 {
+				http.Error(w, "Request body must be encrypted", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(encrypted))
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := encryption.Decrypt(key, encrypted)
+		if err != nil {
+			http.Error(w, "Error decrypting data", http.StatusBadRequest)
+			return
+		}
+
+		r.Header.Del(encryption.HeaderName)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		r.ContentLength = int64(len(body))
+		next.ServeHTTP(w, r)
+	})
+}
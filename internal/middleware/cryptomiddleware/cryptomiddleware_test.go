@@ -0,0 +1,107 @@
+package cryptomiddleware
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/GarikMirzoyan/metricalert/internal/encryption"
+)
+
+func generateKey(t *testing.T) *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	return key
+}
+
+func bodyHandler(t *testing.T, received *[]byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("failed to read request body: %v", err)
+		}
+		*received = body
+		w.Write([]byte("OK"))
+	})
+}
+
+// Тест расшифровки помеченного запроса
+func TestDecrypt(t *testing.T) {
+	key := generateKey(t)
+	original := bytes.Repeat([]byte(`{"id":"Alloc","type":"gauge","value":1}`), 100)
+
+	encrypted, err := encryption.Encrypt(&key.PublicKey, original)
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+
+	var received []byte
+	handler := Decrypt(bodyHandler(t, &received), key)
+
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(encrypted))
+	req.Header.Set(encryption.HeaderName, encryption.HeaderValue)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	if !bytes.Equal(received, original) {
+		t.Errorf("decrypted body does not match original")
+	}
+}
+
+// Тест запроса, зашифрованного чужим ключом, и незашифрованного запроса
+func TestDecrypt_WrongKeyAndPlaintext(t *testing.T) {
+	key := generateKey(t)
+	other := generateKey(t)
+
+	encrypted, err := encryption.Encrypt(&other.PublicKey, []byte("data"))
+	if err != nil {
+		t.Fatalf("failed to encrypt: %v", err)
+	}
+
+	var received []byte
+	handler := Decrypt(bodyHandler(t, &received), key)
+
+	req := httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader(encrypted))
+	req.Header.Set(encryption.HeaderName, encryption.HeaderValue)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/updates/", bytes.NewReader([]byte("plain")))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest || received != nil {
+		t.Errorf("expected plaintext request to be rejected, got status %d body %q", rec.Code, received)
+	}
+}
+
+// Тест запросов без тела: обновление через путь и чтение проходят без шифрования
+func TestDecrypt_WithoutBody(t *testing.T) {
+	var received []byte
+	handler := Decrypt(bodyHandler(t, &received), generateKey(t))
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodPost, "/update/gauge/Alloc/1", nil),
+		httptest.NewRequest(http.MethodGet, "/value/gauge/Alloc", nil),
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s %s: expected status %d, got %d", req.Method, req.URL.Path, http.StatusOK, rec.Code)
+		}
+	}
+}
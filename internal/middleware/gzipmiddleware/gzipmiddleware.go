@@ -4,6 +4,8 @@ import (
 	"compress/gzip"
 	"io"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type gzipResponseWriter struct {
@@ -34,8 +36,8 @@ func GzipDecompression(next http.Handler) http.Handler {
 // Middleware для сжатия исходящих данных
 func GzipCompression(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Проверяем, поддерживает ли клиент gzip-сжатие
-		if r.Header.Get("Accept-Encoding") == "gzip" {
+		// Проверяем, поддерживает ли клиент gzip-сжатие (например, "gzip, deflate, br")
+		if acceptsGzip(r.Header.Get("Accept-Encoding")) {
 			// Создаем новый ResponseWriter для сжатия
 			w.Header().Set("Content-Encoding", "gzip")
 			gz := gzip.NewWriter(w)
@@ -50,3 +52,31 @@ func GzipCompression(next http.Handler) http.Handler {
 		}
 	})
 }
+
+// Поддерживает ли клиент gzip; gzip с q=0 (или некорректным q) означает отказ от кодировки
+func acceptsGzip(acceptEncoding string) bool {
+	for _, encoding := range strings.Split(acceptEncoding, ",") {
+		encoding, params, _ := strings.Cut(encoding, ";")
+		if strings.TrimSpace(encoding) != "gzip" {
+			continue
+		}
+		return qValue(params) > 0
+	}
+	return false
+}
+
+// Вес q из параметров кодировки; без параметра q вес равен 1
+func qValue(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.TrimSpace(name) != "q" {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return 0
+		}
+		return q
+	}
+	return 1
+}
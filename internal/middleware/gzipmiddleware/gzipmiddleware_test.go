@@ -99,3 +99,25 @@ func TestGzipDecompression(t *testing.T) {
 		t.Errorf("expected response body %q, got %q", "OK", string(respBody))
 	}
 }
+
+// Тест для разбора заголовка Accept-Encoding
+func TestAcceptsGzip(t *testing.T) {
+	tests := map[string]bool{
+		"gzip":                 true,
+		"gzip, deflate, br":    true,
+		"deflate, gzip;q=0.5":  true,
+		"deflate":              false,
+		"":                     false,
+		"x-gzip-like":          false,
+		"gzip;q=0":             false,
+		"gzip; q=0.0, deflate": false,
+		"gzip;q=1.0":           true,
+		"gzip;level=1;q=0":     false,
+	}
+
+	for header, expected := range tests {
+		if got := acceptsGzip(header); got != expected {
+			t.Errorf("acceptsGzip(%q) = %v, expected %v", header, got, expected)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package hashmiddleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/GarikMirzoyan/metricalert/internal/hash"
+)
+
+// Максимальный размер тела запроса, которое middleware читает в память
+const maxBodySize = 10 << 20
+
+type signingResponseWriter struct {
+	http.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+func (sw *signingResponseWriter) WriteHeader(status int) {
+	if sw.status == 0 {
+		sw.status = status
+	}
+}
+
+func (sw *signingResponseWriter) Write(p []byte) (int, error) {
+	return sw.body.Write(p)
+}
+
+// Middleware для проверки подписи входящих данных и подписи ответов.
+// Подпись считается по телу в том виде, в котором оно передаётся по сети,
+// поэтому middleware должен стоять до разжатия и расшифровки.
+// Запросы с телом и любые POST без корректной подписи отклоняются; у POST
+// без тела подписываются метод и URL запроса.
+func Hash(next http.Handler, key string) http.Handler {
+	if key == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "Error reading request body", http.StatusBadRequest)
+			return
+		}
+		r.Body.Close()
+
+		if len(body) > 0 || r.Method == http.MethodPost {
+			if !hash.Verify(signedData(r, body), key, r.Header.Get(hash.HeaderName)) {
+				http.Error(w, "Invalid request signature", http.StatusBadRequest)
+				return
+			}
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		sw := &signingResponseWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+
+		w.Header().Set(hash.HeaderName, hash.Sign(sw.body.Bytes(), key))
+		w.WriteHeader(sw.status)
+		_, _ = w.Write(sw.body.Bytes())
+	})
+}
+
+// Данные запроса, по которым считается подпись: тело, а для запросов
+// без тела — метод и URL, чтобы нельзя было изменить метрику по path API
+// без ключа.
+func signedData(r *http.Request, body []byte) []byte {
+	if len(body) > 0 {
+		return body
+	}
+	return []byte(r.Method + " " + r.URL.RequestURI())
+}
@@ -0,0 +1,124 @@
+package hashmiddleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/GarikMirzoyan/metricalert/internal/hash"
+)
+
+const testKey = "secret"
+
+func echoHandler(t *testing.T) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("failed to read request body: %v", err)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write(body)
+	})
+}
+
+// Тест подписанного запроса: тело доходит до обработчика, ответ подписан
+func TestHash_ValidSignature(t *testing.T) {
+	body := `[{"id":"Alloc","type":"gauge","value":1}]`
+	handler := Hash(echoHandler(t), testKey)
+
+	req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+	req.Header.Set(hash.HeaderName, hash.Sign([]byte(body), testKey))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, rec.Code)
+	}
+	if rec.Body.String() != body {
+		t.Errorf("expected body %q, got %q", body, rec.Body.String())
+	}
+	if !hash.Verify(rec.Body.Bytes(), testKey, rec.Header().Get(hash.HeaderName)) {
+		t.Errorf("response signature is invalid: %q", rec.Header().Get(hash.HeaderName))
+	}
+}
+
+// Тест запросов с неверной подписью или без неё
+func TestHash_InvalidSignature(t *testing.T) {
+	body := `[{"id":"Alloc","type":"gauge","value":1}]`
+	handler := Hash(echoHandler(t), testKey)
+
+	signatures := []string{"", "zz", hash.Sign([]byte(body), "other-key")}
+	for _, signature := range signatures {
+		req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(body))
+		if signature != "" {
+			req.Header.Set(hash.HeaderName, signature)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("signature %q: expected status %d, got %d", signature, http.StatusBadRequest, rec.Code)
+		}
+	}
+}
+
+// Тест запроса без тела и работы без ключа
+func TestHash_NoBodyAndNoKey(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/value/gauge/Alloc", nil)
+	rec := httptest.NewRecorder()
+	Hash(echoHandler(t), testKey).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected status %d, got %d", http.StatusCreated, rec.Code)
+	}
+	if rec.Header().Get(hash.HeaderName) == "" {
+		t.Error("expected response to be signed")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader("data"))
+	rec = httptest.NewRecorder()
+	Hash(echoHandler(t), "").ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("expected status %d without key, got %d", http.StatusCreated, rec.Code)
+	}
+	if rec.Header().Get(hash.HeaderName) != "" {
+		t.Error("expected response without signature when key is empty")
+	}
+}
+
+// Тест POST без тела: без подписи запрос отклоняется, подпись считается по методу и URL
+func TestHash_BodilessPost(t *testing.T) {
+	const target = "/update/gauge/Alloc/1"
+	handler := Hash(echoHandler(t), testKey)
+
+	req := httptest.NewRequest(http.MethodPost, target, nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unsigned request: expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, target, nil)
+	req.Header.Set(hash.HeaderName, hash.Sign([]byte(http.MethodPost+" "+target), testKey))
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Errorf("signed request: expected status %d, got %d", http.StatusCreated, rec.Code)
+	}
+}
+
+// Тест слишком большого тела запроса
+func TestHash_BodyTooLarge(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/updates/", strings.NewReader(strings.Repeat("a", maxBodySize+1)))
+	rec := httptest.NewRecorder()
+	Hash(echoHandler(t), testKey).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status %d, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+}
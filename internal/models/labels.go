@@ -0,0 +1,139 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrInvalidLabels     = errors.New("invalid labels")
+	ErrInvalidMetricName = errors.New("invalid metric name")
+)
+
+// Набор меток метрики; серия определяется именем и набором меток
+type Labels map[string]string
+
+// Каноническое представление меток: host="a",region="b" (ключи по алфавиту)
+func (l Labels) String() string {
+	if len(l) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(l))
+	for key := range l {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(key)
+		b.WriteByte('=')
+		b.WriteString(strconv.Quote(l[key]))
+	}
+	return b.String()
+}
+
+func (l Labels) Clone() Labels {
+	if len(l) == 0 {
+		return nil
+	}
+	clone := make(Labels, len(l))
+	for key, value := range l {
+		clone[key] = value
+	}
+	return clone
+}
+
+// Имена меток должны соответствовать [a-zA-Z_][a-zA-Z0-9_]*
+func (l Labels) Validate() error {
+	for key := range l {
+		if !isLabelName(key) {
+			return fmt.Errorf("%w: bad label name %q", ErrInvalidLabels, key)
+		}
+	}
+	return nil
+}
+
+func isLabelName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Имя метрики не должно содержать символов ключа серии ({, } и ") и управляющих символов:
+// иначе имя вида a{host="b"} совпадёт с ключом серии a с меткой host. Пустое имя проверяется отдельно.
+func ValidateMetricName(name string) error {
+	for _, r := range name {
+		if r == '{' || r == '}' || r == '"' || unicode.IsControl(r) {
+			return fmt.Errorf("%w: %q", ErrInvalidMetricName, name)
+		}
+	}
+	return nil
+}
+
+// Ключ серии: имя метрики без меток или name{host="a"}
+func SeriesKey(name string, labels Labels) string {
+	if len(labels) == 0 {
+		return name
+	}
+	return name + "{" + labels.String() + "}"
+}
+
+// Ключ серии вместе с типом метрики, например counter Alloc{host="a"}: gauge и counter
+// с одинаковыми именем и метками дают разные ключи
+func TypedSeriesKey(metricType, name string, labels Labels) string {
+	return metricType + " " + SeriesKey(name, labels)
+}
+
+// Разбор ключа серии вида name{host="a",region="b"}
+func ParseSeriesKey(key string) (string, Labels, error) {
+	name, rest, found := strings.Cut(key, "{")
+	if !found {
+		return key, nil, nil
+	}
+	if name == "" || !strings.HasSuffix(rest, "}") {
+		return "", nil, fmt.Errorf("%w: bad series %q", ErrInvalidLabels, key)
+	}
+	rest = strings.TrimSuffix(rest, "}")
+
+	labels := make(Labels)
+	for rest != "" {
+		labelName, tail, ok := strings.Cut(rest, "=")
+		if !ok {
+			return "", nil, fmt.Errorf("%w: bad series %q", ErrInvalidLabels, key)
+		}
+		quoted, err := strconv.QuotedPrefix(tail)
+		if err != nil {
+			return "", nil, fmt.Errorf("%w: bad series %q", ErrInvalidLabels, key)
+		}
+		value, _ := strconv.Unquote(quoted)
+		labels[labelName] = value
+
+		rest = strings.TrimPrefix(tail[len(quoted):], ",")
+	}
+
+	if err := labels.Validate(); err != nil {
+		return "", nil, err
+	}
+	if len(labels) == 0 {
+		labels = nil
+	}
+	return name, labels, nil
+}
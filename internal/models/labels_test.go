@@ -0,0 +1,55 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeriesKey(t *testing.T) {
+	assert.Equal(t, "HeapAlloc", SeriesKey("HeapAlloc", nil))
+	assert.Equal(t, "HeapAlloc", SeriesKey("HeapAlloc", Labels{}))
+	assert.Equal(t,
+		`HeapAlloc{host="web-1",region="eu"}`,
+		SeriesKey("HeapAlloc", Labels{"region": "eu", "host": "web-1"}),
+	)
+}
+
+func TestParseSeriesKey(t *testing.T) {
+	labels := Labels{"host": `web "1"`, "region": "eu,west"}
+	key := SeriesKey("HeapAlloc", labels)
+
+	name, parsed, err := ParseSeriesKey(key)
+	require.NoError(t, err)
+	assert.Equal(t, "HeapAlloc", name)
+	assert.Equal(t, labels, parsed)
+
+	name, parsed, err = ParseSeriesKey("PollCount")
+	require.NoError(t, err)
+	assert.Equal(t, "PollCount", name)
+	assert.Nil(t, parsed)
+
+	for _, bad := range []string{`{host="a"}`, `A{host="a"`, `A{host=a}`, `A{1host="a"}`} {
+		_, _, err := ParseSeriesKey(bad)
+		assert.ErrorIs(t, err, ErrInvalidLabels, bad)
+	}
+}
+
+func TestLabelsValidate(t *testing.T) {
+	assert.NoError(t, Labels{"host": "a", "_x1": "b"}.Validate())
+	assert.ErrorIs(t, Labels{"1host": "a"}.Validate(), ErrInvalidLabels)
+	assert.ErrorIs(t, Labels{"host-name": "a"}.Validate(), ErrInvalidLabels)
+	assert.ErrorIs(t, Labels{"": "a"}.Validate(), ErrInvalidLabels)
+}
+
+func TestValidateMetricName(t *testing.T) {
+	assert.NoError(t, ValidateMetricName("HeapAlloc"))
+	assert.NoError(t, ValidateMetricName("disk.sda-1 read"))
+
+	// Имя с «метками» совпало бы с ключом серии HeapAlloc{host="a"}
+	assert.Equal(t, SeriesKey("HeapAlloc", Labels{"host": "a"}), SeriesKey(`HeapAlloc{host="a"}`, nil))
+	for _, bad := range []string{`HeapAlloc{host="a"}`, "Heap{", "Heap}", `Heap"Alloc`, "Heap\nAlloc"} {
+		assert.ErrorIs(t, ValidateMetricName(bad), ErrInvalidMetricName, bad)
+	}
+}
@@ -1,13 +1,21 @@
 package models
 
-import "github.com/GarikMirzoyan/metricalert/internal/constants"
+import (
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+)
 
 type CounterMetric struct {
-	Name  string
-	Type  constants.MetricType
-	Value int64
+	Name   string
+	Type   constants.MetricType
+	Labels Labels
+	Value  int64
+	// Время наблюдения; нулевое — время получения сервером
+	Timestamp time.Time
 }
 
 func (m CounterMetric) GetName() string               { return m.Name }
 func (m CounterMetric) GetType() constants.MetricType { return m.Type }
 func (m CounterMetric) GetValue() any                 { return m.Value }
+func (m CounterMetric) GetLabels() Labels             { return m.Labels }
@@ -1,13 +1,21 @@
 package models
 
-import "github.com/GarikMirzoyan/metricalert/internal/constants"
+import (
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+)
 
 type GaugeMetric struct {
-	Name  string
-	Type  constants.MetricType
-	Value float64
+	Name   string
+	Type   constants.MetricType
+	Labels Labels
+	Value  float64
+	// Время наблюдения; нулевое — время получения сервером
+	Timestamp time.Time
 }
 
 func (m GaugeMetric) GetName() string               { return m.Name }
 func (m GaugeMetric) GetType() constants.MetricType { return m.Type }
 func (m GaugeMetric) GetValue() any                 { return m.Value }
+func (m GaugeMetric) GetLabels() Labels             { return m.Labels }
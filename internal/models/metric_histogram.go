@@ -0,0 +1,117 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+)
+
+var ErrInvalidHistogram = errors.New("invalid histogram")
+
+// Границы корзин по умолчанию (как в клиентах Prometheus), в секундах
+var DefaultHistogramBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Гистограмма наблюдений. Buckets — верхние границы корзин по возрастанию без +Inf,
+// Counts — число наблюдений в каждой корзине (не накопительное), последний элемент —
+// наблюдения больше всех границ. Гистограммы с одинаковыми границами складываются.
+type HistogramMetric struct {
+	Name    string
+	Type    constants.MetricType
+	Labels  Labels
+	Buckets []float64
+	Counts  []uint64
+	Sum     float64
+	Count   uint64
+}
+
+func (m HistogramMetric) GetName() string               { return m.Name }
+func (m HistogramMetric) GetType() constants.MetricType { return m.Type }
+func (m HistogramMetric) GetValue() any                 { return m }
+func (m HistogramMetric) GetLabels() Labels             { return m.Labels }
+
+// Пустая гистограмма с заданными границами
+func NewHistogram(name string, labels Labels, buckets []float64) *HistogramMetric {
+	return &HistogramMetric{
+		Name:    name,
+		Type:    constants.HistogramName,
+		Labels:  labels,
+		Buckets: slices.Clone(buckets),
+		Counts:  make([]uint64, len(buckets)+1),
+	}
+}
+
+// Границы должны быть конечными и строго возрастать
+func ValidateBuckets(buckets []float64) error {
+	for i, bound := range buckets {
+		if math.IsNaN(bound) || math.IsInf(bound, 0) {
+			return fmt.Errorf("%w: bucket bound %v", ErrInvalidHistogram, bound)
+		}
+		if i > 0 && bound <= buckets[i-1] {
+			return fmt.Errorf("%w: bucket bounds must be increasing", ErrInvalidHistogram)
+		}
+	}
+	return nil
+}
+
+func (m HistogramMetric) Validate() error {
+	if err := ValidateBuckets(m.Buckets); err != nil {
+		return err
+	}
+	if len(m.Counts) != len(m.Buckets)+1 {
+		return fmt.Errorf("%w: expected %d bucket counts, got %d", ErrInvalidHistogram, len(m.Buckets)+1, len(m.Counts))
+	}
+
+	var total uint64
+	for _, count := range m.Counts {
+		total += count
+	}
+	if total != m.Count {
+		return fmt.Errorf("%w: count %d does not match bucket counts %d", ErrInvalidHistogram, m.Count, total)
+	}
+	if math.IsNaN(m.Sum) || math.IsInf(m.Sum, 0) {
+		return fmt.Errorf("%w: sum %v", ErrInvalidHistogram, m.Sum)
+	}
+	return nil
+}
+
+// Учёт наблюдения в корзине с наименьшей границей, не меньшей value
+func (m *HistogramMetric) Observe(value float64) {
+	m.Counts[sort.SearchFloat64s(m.Buckets, value)]++
+	m.Sum += value
+	m.Count++
+}
+
+// Сложение с гистограммой с теми же границами
+func (m *HistogramMetric) Merge(other HistogramMetric) error {
+	if !slices.Equal(m.Buckets, other.Buckets) || len(m.Counts) != len(other.Counts) {
+		return fmt.Errorf("%w: bucket bounds do not match", ErrInvalidHistogram)
+	}
+	for i, count := range other.Counts {
+		m.Counts[i] += count
+	}
+	m.Sum += other.Sum
+	m.Count += other.Count
+	return nil
+}
+
+func (m HistogramMetric) Clone() HistogramMetric {
+	m.Labels = m.Labels.Clone()
+	m.Buckets = slices.Clone(m.Buckets)
+	m.Counts = slices.Clone(m.Counts)
+	return m
+}
+
+// Накопительные счётчики корзин (le), как в формате Prometheus; последний — +Inf
+func (m HistogramMetric) Cumulative() []uint64 {
+	cumulative := make([]uint64, len(m.Counts))
+	var total uint64
+	for i, count := range m.Counts {
+		total += count
+		cumulative[i] = total
+	}
+	return cumulative
+}
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramObserve(t *testing.T) {
+	h := NewHistogram("Latency", nil, []float64{0.1, 1})
+	for _, v := range []float64{0.05, 0.1, 0.5, 3} {
+		h.Observe(v)
+	}
+
+	// Граница корзины включается в неё (le)
+	assert.Equal(t, []uint64{2, 1, 1}, h.Counts)
+	assert.Equal(t, []uint64{2, 3, 4}, h.Cumulative())
+	assert.Equal(t, uint64(4), h.Count)
+	assert.InDelta(t, 3.65, h.Sum, 1e-9)
+	assert.NoError(t, h.Validate())
+}
+
+func TestHistogramMerge(t *testing.T) {
+	h := NewHistogram("Latency", nil, []float64{0.1, 1})
+	h.Observe(0.05)
+
+	other := NewHistogram("Latency", nil, []float64{0.1, 1})
+	other.Observe(2)
+	require.NoError(t, h.Merge(*other))
+	assert.Equal(t, []uint64{1, 0, 1}, h.Counts)
+	assert.Equal(t, uint64(2), h.Count)
+
+	assert.ErrorIs(t, h.Merge(*NewHistogram("Latency", nil, []float64{0.5})), ErrInvalidHistogram)
+	assert.Equal(t, []uint64{1, 0, 1}, h.Counts)
+}
+
+func TestHistogramValidate(t *testing.T) {
+	valid := HistogramMetric{Buckets: []float64{1, 2}, Counts: []uint64{1, 0, 2}, Sum: 7, Count: 3}
+	require.NoError(t, valid.Validate())
+
+	tests := map[string]func(h *HistogramMetric){
+		"decreasing bounds": func(h *HistogramMetric) { h.Buckets = []float64{2, 1} },
+		"infinite bound":    func(h *HistogramMetric) { h.Buckets = []float64{1, math.Inf(1)} },
+		"missing +Inf":      func(h *HistogramMetric) { h.Counts = []uint64{1, 2} },
+		"count mismatch":    func(h *HistogramMetric) { h.Count = 4 },
+		"NaN sum":           func(h *HistogramMetric) { h.Sum = math.NaN() },
+		// Без корзины +Inf гистограмма пуста: так выглядит запрос /value/
+		"no buckets": func(h *HistogramMetric) { h.Buckets, h.Counts, h.Count = nil, nil, 0 },
+	}
+
+	for name, mutate := range tests {
+		h := valid.Clone()
+		mutate(&h)
+		assert.ErrorIs(t, h.Validate(), ErrInvalidHistogram, name)
+	}
+}
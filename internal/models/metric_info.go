@@ -0,0 +1,39 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+)
+
+var ErrInvalidInfo = errors.New("invalid info value")
+
+// Максимальная длина значения info в байтах
+const MaxInfoLength = 256
+
+// Строковое значение, например версия сборки или leader=true.
+// Новое значение заменяет сохранённое, как у gauge.
+type InfoMetric struct {
+	Name   string
+	Type   constants.MetricType
+	Labels Labels
+	Value  string
+}
+
+func (m InfoMetric) GetName() string               { return m.Name }
+func (m InfoMetric) GetType() constants.MetricType { return m.Type }
+func (m InfoMetric) GetValue() any                 { return m.Value }
+func (m InfoMetric) GetLabels() Labels             { return m.Labels }
+
+// Значение должно быть строкой UTF-8 не длиннее MaxInfoLength байт
+func (m InfoMetric) Validate() error {
+	if len(m.Value) > MaxInfoLength {
+		return fmt.Errorf("%w: longer than %d bytes", ErrInvalidInfo, MaxInfoLength)
+	}
+	if !utf8.ValidString(m.Value) {
+		return fmt.Errorf("%w: not valid UTF-8", ErrInvalidInfo)
+	}
+	return nil
+}
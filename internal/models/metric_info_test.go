@@ -0,0 +1,28 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInfoMetric_Validate(t *testing.T) {
+	tests := map[string]bool{
+		"":                                   true,
+		"leader=true":                        true,
+		"версия 1.2":                         true,
+		strings.Repeat("x", MaxInfoLength):   true,
+		strings.Repeat("x", MaxInfoLength+1): false,
+		"\xff\xfe":                           false,
+	}
+
+	for value, valid := range tests {
+		err := InfoMetric{Name: "Version", Value: value}.Validate()
+		if valid {
+			assert.NoError(t, err, value)
+		} else {
+			assert.ErrorIs(t, err, ErrInvalidInfo, value)
+		}
+	}
+}
@@ -6,4 +6,5 @@ type Metric interface {
 	GetName() string
 	GetType() constants.MetricType
 	GetValue() any
+	GetLabels() Labels
 }
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/sketch"
+)
+
+// Множество строковых элементов, например идентификаторов пользователей. Сервер
+// хранит не элементы, а HyperLogLog для оценки их числа; состояния складываются.
+type SetMetric struct {
+	Name   string
+	Type   constants.MetricType
+	Labels Labels
+	HLL    *sketch.HyperLogLog
+}
+
+func (m SetMetric) GetName() string               { return m.Name }
+func (m SetMetric) GetType() constants.MetricType { return m.Type }
+func (m SetMetric) GetValue() any                 { return m }
+func (m SetMetric) GetLabels() Labels             { return m.Labels }
+
+func (m SetMetric) Clone() SetMetric {
+	m.Labels = m.Labels.Clone()
+	if m.HLL != nil {
+		m.HLL = m.HLL.Clone()
+	}
+	return m
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/sketch"
+)
+
+// Сводка наблюдений для оценки квантилей (p50, p95, p99). Сервер хранит не сами
+// наблюдения, а скетч DDSketch; скетчи от разных агентов складываются.
+type SummaryMetric struct {
+	Name   string
+	Type   constants.MetricType
+	Labels Labels
+	Sketch *sketch.DDSketch
+}
+
+func (m SummaryMetric) GetName() string               { return m.Name }
+func (m SummaryMetric) GetType() constants.MetricType { return m.Type }
+func (m SummaryMetric) GetValue() any                 { return m }
+func (m SummaryMetric) GetLabels() Labels             { return m.Labels }
+
+func (m SummaryMetric) Clone() SummaryMetric {
+	m.Labels = m.Labels.Clone()
+	if m.Sketch != nil {
+		m.Sketch = m.Sketch.Clone()
+	}
+	return m
+}
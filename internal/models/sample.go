@@ -0,0 +1,9 @@
+package models
+
+import "time"
+
+// Значение метрики в момент времени
+type Sample struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
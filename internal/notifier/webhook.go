@@ -0,0 +1,163 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/alerts"
+	"github.com/GarikMirzoyan/metricalert/internal/retry"
+	"go.uber.org/zap"
+)
+
+// Размер очереди событий для одного получателя
+const defaultQueueSize = 100
+
+type Receiver struct {
+	Name string
+	URL  string
+}
+
+type receiverQueue struct {
+	receiver Receiver
+	events   chan alerts.Event
+}
+
+// Диспетчер рассылает события алертов по вебхукам.
+// У каждого получателя своя очередь и свой воркер, поэтому медленный
+// получатель не задерживает доставку остальным.
+type Dispatcher struct {
+	queues []*receiverQueue
+	client *http.Client
+	delays []time.Duration
+	logger *zap.Logger
+	wg     sync.WaitGroup
+
+	// Закрытие очередей; после него события не принимаются
+	mu     sync.RWMutex
+	closed bool
+}
+
+func NewDispatcher(receivers []Receiver, logger *zap.Logger) *Dispatcher {
+	queues := make([]*receiverQueue, 0, len(receivers))
+	for _, receiver := range receivers {
+		queues = append(queues, &receiverQueue{
+			receiver: receiver,
+			events:   make(chan alerts.Event, defaultQueueSize),
+		})
+	}
+
+	return &Dispatcher{
+		queues: queues,
+		client: &http.Client{Timeout: 10 * time.Second},
+		delays: retry.DefaultDelays,
+		logger: logger,
+	}
+}
+
+// Запуск воркеров; они завершаются при отмене контекста или после Close,
+// доставив оставшиеся в очереди события
+func (d *Dispatcher) Start(ctx context.Context) {
+	for _, q := range d.queues {
+		d.wg.Add(1)
+		go func(q *receiverQueue) {
+			defer d.wg.Done()
+			d.run(ctx, q)
+		}(q)
+	}
+}
+
+// Ожидание завершения воркеров после отмены контекста или Close
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// Прекращение приёма событий; воркеры досылают очередь и завершаются
+func (d *Dispatcher) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return
+	}
+	d.closed = true
+	for _, q := range d.queues {
+		close(q.events)
+	}
+}
+
+// Постановка события в очереди всех получателей без блокировки
+func (d *Dispatcher) Notify(event alerts.Event) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.closed {
+		return
+	}
+	for _, q := range d.queues {
+		select {
+		case q.events <- event:
+		default:
+			d.logger.Warn("очередь получателя переполнена, событие отброшено",
+				zap.String("receiver", q.receiver.Name),
+				zap.String("rule", event.Rule),
+				zap.String("state", string(event.State)),
+			)
+		}
+	}
+}
+
+func (d *Dispatcher) run(ctx context.Context, q *receiverQueue) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-q.events:
+			if !ok {
+				return
+			}
+			err := retry.WithBackoffDelays(ctx, d.delays, func() error {
+				return d.send(ctx, q.receiver, event)
+			})
+			if err != nil {
+				d.logger.Error("не удалось доставить событие алерта",
+					zap.String("receiver", q.receiver.Name),
+					zap.String("rule", event.Rule),
+					zap.Error(err),
+				)
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, receiver Receiver, event alerts.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, receiver.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("receiver error: %s: %w", resp.Status, retry.ErrRetriable)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("non-retriable status: %s", resp.Status)
+	}
+
+	return nil
+}
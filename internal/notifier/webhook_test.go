@@ -0,0 +1,146 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/alerts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestDispatcher(receivers ...Receiver) *Dispatcher {
+	d := NewDispatcher(receivers, zap.NewNop())
+	d.delays = []time.Duration{10 * time.Millisecond, 10 * time.Millisecond, 10 * time.Millisecond}
+	return d
+}
+
+func testEvent() alerts.Event {
+	return alerts.Event{
+		Alert: alerts.Alert{
+			Rule:        "HighHeap",
+			MetricID:    "HeapAlloc",
+			MetricType:  "gauge",
+			Value:       6e8,
+			State:       alerts.StateFiring,
+			ActiveSince: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		Timestamp: time.Date(2025, 1, 1, 0, 2, 0, 0, time.UTC),
+	}
+}
+
+func TestDispatcher_DeliversPayload(t *testing.T) {
+	received := make(chan map[string]any, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		var payload map[string]any
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		received <- payload
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d := newTestDispatcher(Receiver{Name: "oncall", URL: srv.URL})
+	d.Start(ctx)
+	d.Notify(testEvent())
+
+	select {
+	case payload := <-received:
+		assert.Equal(t, "HighHeap", payload["rule"])
+		assert.Equal(t, "HeapAlloc", payload["metric_id"])
+		assert.Equal(t, "firing", payload["state"])
+		assert.Equal(t, 6e8, payload["value"])
+		assert.Equal(t, "2025-01-01T00:02:00Z", payload["timestamp"])
+	case <-time.After(2 * time.Second):
+		t.Fatal("event was not delivered")
+	}
+}
+
+func TestDispatcher_RetriesServerErrors(t *testing.T) {
+	var attempts atomic.Int32
+	delivered := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		close(delivered)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d := newTestDispatcher(Receiver{Name: "flaky", URL: srv.URL})
+	d.Start(ctx)
+	d.Notify(testEvent())
+
+	select {
+	case <-delivered:
+		assert.Equal(t, int32(3), attempts.Load())
+	case <-time.After(2 * time.Second):
+		t.Fatal("event was not delivered after retries")
+	}
+}
+
+func TestDispatcher_SlowReceiverDoesNotBlockOthers(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	fastReceived := make(chan struct{}, 2)
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fastReceived <- struct{}{}
+	}))
+	defer fast.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	d := newTestDispatcher(Receiver{Name: "slow", URL: slow.URL}, Receiver{Name: "fast", URL: fast.URL})
+	d.Start(ctx)
+	d.Notify(testEvent())
+	d.Notify(testEvent())
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-fastReceived:
+		case <-time.After(2 * time.Second):
+			t.Fatal("fast receiver was blocked by slow one")
+		}
+	}
+	require.Len(t, fastReceived, 0)
+}
+
+func TestDispatcher_CloseDrainsQueue(t *testing.T) {
+	var delivered atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		delivered.Add(1)
+	}))
+	defer srv.Close()
+
+	d := newTestDispatcher(Receiver{Name: "oncall", URL: srv.URL})
+	d.Start(context.Background())
+	for i := 0; i < 3; i++ {
+		d.Notify(testEvent())
+	}
+
+	d.Close()
+	d.Wait()
+	assert.Equal(t, int32(3), delivered.Load())
+
+	// После Close события отбрасываются без паники
+	d.Notify(testEvent())
+	d.Close()
+}
@@ -0,0 +1,114 @@
+package proto
+
+import (
+	"math"
+
+	dto "github.com/GarikMirzoyan/metricalert/internal/DTO"
+	"github.com/GarikMirzoyan/metricalert/internal/sketch"
+)
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative metrics.proto
+
+// Преобразование DTO в protobuf-сообщение
+func FromDTO(metric dto.Metrics) *Metric {
+	return &Metric{
+		Id:        metric.ID,
+		Type:      metric.MType,
+		Delta:     metric.Delta,
+		Value:     metric.Value,
+		Labels:    metric.Labels,
+		Buckets:   metric.Buckets,
+		Counts:    metric.Counts,
+		Sum:       metric.Sum,
+		Count:     metric.Count,
+		Quantile:  metric.Quantile,
+		Sketch:    fromSketchState(metric.Sketch),
+		Members:   metric.Members,
+		Hll:       fromHLLState(metric.HLL),
+		Text:      metric.Text,
+		Timestamp: metric.Timestamp,
+	}
+}
+
+// Преобразование protobuf-сообщения в DTO; дальше метрика валидируется через metrics.NewMetricFromDTO
+func ToDTO(metric *Metric) dto.Metrics {
+	result := dto.Metrics{
+		ID:        metric.GetId(),
+		MType:     metric.GetType(),
+		Delta:     metric.Delta,
+		Value:     metric.Value,
+		Sum:       metric.Sum,
+		Count:     metric.Count,
+		Quantile:  metric.Quantile,
+		Sketch:    toSketchState(metric.GetSketch()),
+		HLL:       toHLLState(metric.GetHll()),
+		Text:      metric.Text,
+		Timestamp: metric.Timestamp,
+	}
+	if len(metric.GetLabels()) > 0 {
+		result.Labels = metric.GetLabels()
+	}
+	if len(metric.GetBuckets()) > 0 {
+		result.Buckets = metric.GetBuckets()
+	}
+	if len(metric.GetCounts()) > 0 {
+		result.Counts = metric.GetCounts()
+	}
+	if len(metric.GetMembers()) > 0 {
+		result.Members = metric.GetMembers()
+	}
+	return result
+}
+
+func fromSketchState(state *sketch.State) *Sketch {
+	if state == nil {
+		return nil
+	}
+	return &Sketch{
+		Alpha:    state.Alpha,
+		Zero:     state.Zero,
+		Positive: state.Positive,
+		Negative: state.Negative,
+		Count:    state.Count,
+		Sum:      state.Sum,
+		Min:      state.Min,
+		Max:      state.Max,
+	}
+}
+
+func toSketchState(s *Sketch) *sketch.State {
+	if s == nil {
+		return nil
+	}
+	return &sketch.State{
+		Alpha:    s.GetAlpha(),
+		Zero:     s.GetZero(),
+		Positive: s.GetPositive(),
+		Negative: s.GetNegative(),
+		Count:    s.GetCount(),
+		Sum:      s.GetSum(),
+		Min:      s.GetMin(),
+		Max:      s.GetMax(),
+	}
+}
+
+func fromHLLState(state *sketch.HLLState) *HyperLogLog {
+	if state == nil {
+		return nil
+	}
+	return &HyperLogLog{
+		Precision: uint32(state.Precision),
+		Registers: state.Registers,
+	}
+}
+
+// Точность вне диапазона uint8 отклоняется при проверке состояния
+func toHLLState(h *HyperLogLog) *sketch.HLLState {
+	if h == nil {
+		return nil
+	}
+	return &sketch.HLLState{
+		Precision: uint8(min(h.GetPrecision(), math.MaxUint8)),
+		Registers: h.GetRegisters(),
+	}
+}
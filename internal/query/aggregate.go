@@ -0,0 +1,158 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+)
+
+var (
+	ErrUnknownFunc     = errors.New("unknown aggregation function")
+	ErrRateForNonCount = errors.New("rate is supported only for counters")
+	ErrInvalidRange    = errors.New("invalid time range")
+	ErrTooManyPoints   = errors.New("too many points requested")
+)
+
+// Ограничение числа точек в ответе по умолчанию (QUERY_MAX_POINTS). Как и в query_range
+// Prometheus, это 11000: хватает на сутки с шагом 10s (8640 точек) и защищает сервер
+// от запросов вроде года с шагом в секунду, для которых ответ пришлось бы держать в памяти целиком.
+const DefaultMaxPoints = 11000
+
+// Функция агрегации значений внутри шага
+type Func string
+
+const (
+	FuncAvg  Func = "avg"
+	FuncMin  Func = "min"
+	FuncMax  Func = "max"
+	FuncLast Func = "last"
+	FuncSum  Func = "sum"
+	FuncRate Func = "rate"
+)
+
+func ParseFunc(name string, metricType constants.MetricType) (Func, error) {
+	fn := Func(name)
+	switch fn {
+	case FuncAvg, FuncMin, FuncMax, FuncLast, FuncSum:
+		return fn, nil
+	case FuncRate:
+		if metricType != constants.CounterName {
+			return "", ErrRateForNonCount
+		}
+		return fn, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnknownFunc, name)
+	}
+}
+
+// Проверка параметров диапазона: в ответе не больше maxPoints шагов
+func ValidateRange(from, to time.Time, step time.Duration, maxPoints int) error {
+	if step <= 0 || to.Before(from) {
+		return ErrInvalidRange
+	}
+	// Sub насыщается на math.MaxInt64 для диапазонов длиннее ~292 лет, а +1 к такому
+	// частному переполнило бы Duration; поэтому сравниваем частное без +1
+	span := to.Sub(from)
+	if !from.Add(span).Equal(to) {
+		return fmt.Errorf("%w: range %s..%s is too long", ErrTooManyPoints, from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	if steps := span / step; steps >= time.Duration(maxPoints) {
+		return fmt.Errorf("%w: %d steps of %s, limit %d", ErrTooManyPoints, int64(steps)+1, step, maxPoints)
+	}
+	return nil
+}
+
+// Агрегация значений по шагам [from+i*step, from+(i+1)*step).
+// Временная метка точки — начало шага, шаги без значений пропускаются.
+// Для rate значения считаются накопленными значениями счётчика: в шаг попадает
+// прирост относительно предыдущего значения (в том числе полученного до from),
+// сброс счётчика (уменьшение значения) трактуется как рост с нуля.
+func Aggregate(samples []models.Sample, from, to time.Time, step time.Duration, fn Func) []models.Sample {
+	points := make([]models.Sample, 0)
+	if step <= 0 {
+		return points
+	}
+
+	var (
+		bucket   = -1
+		acc      accumulator
+		previous *models.Sample
+	)
+
+	flush := func() {
+		if bucket >= 0 && acc.count > 0 {
+			points = append(points, models.Sample{
+				Timestamp: from.Add(time.Duration(bucket) * step),
+				Value:     acc.result(fn, step),
+			})
+		}
+	}
+
+	for i := range samples {
+		sample := samples[i]
+		if sample.Timestamp.Before(from) || sample.Timestamp.After(to) {
+			if sample.Timestamp.Before(from) {
+				previous = &samples[i]
+			}
+			continue
+		}
+
+		idx := int(sample.Timestamp.Sub(from) / step)
+		if idx != bucket {
+			flush()
+			bucket = idx
+			acc = accumulator{min: math.Inf(1), max: math.Inf(-1)}
+		}
+
+		acc.add(sample.Value)
+		if previous != nil {
+			if delta := sample.Value - previous.Value; delta >= 0 {
+				acc.increase += delta
+			} else {
+				acc.increase += sample.Value
+			}
+		}
+		previous = &samples[i]
+	}
+	flush()
+
+	return points
+}
+
+type accumulator struct {
+	count    int
+	sum      float64
+	min      float64
+	max      float64
+	last     float64
+	increase float64
+}
+
+func (a *accumulator) add(value float64) {
+	a.count++
+	a.sum += value
+	a.min = math.Min(a.min, value)
+	a.max = math.Max(a.max, value)
+	a.last = value
+}
+
+func (a *accumulator) result(fn Func, step time.Duration) float64 {
+	switch fn {
+	case FuncAvg:
+		return a.sum / float64(a.count)
+	case FuncMin:
+		return a.min
+	case FuncMax:
+		return a.max
+	case FuncSum:
+		return a.sum
+	case FuncRate:
+		return a.increase / step.Seconds()
+	default:
+		return a.last
+	}
+}
@@ -0,0 +1,110 @@
+package query
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var base = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func samplesAt(values map[time.Duration]float64, order ...time.Duration) []models.Sample {
+	samples := make([]models.Sample, 0, len(order))
+	for _, offset := range order {
+		samples = append(samples, models.Sample{Timestamp: base.Add(offset), Value: values[offset]})
+	}
+	return samples
+}
+
+func TestAggregate(t *testing.T) {
+	values := map[time.Duration]float64{
+		0:                1,
+		10 * time.Second: 5,
+		20 * time.Second: 3,
+		40 * time.Second: 10,
+		50 * time.Second: 2,
+	}
+	samples := samplesAt(values, 0, 10*time.Second, 20*time.Second, 40*time.Second, 50*time.Second)
+
+	tests := []struct {
+		fn       Func
+		expected []float64
+	}{
+		{fn: FuncAvg, expected: []float64{3, 6}},
+		{fn: FuncMin, expected: []float64{1, 2}},
+		{fn: FuncMax, expected: []float64{5, 10}},
+		{fn: FuncLast, expected: []float64{3, 2}},
+		{fn: FuncSum, expected: []float64{9, 12}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.fn), func(t *testing.T) {
+			points := Aggregate(samples, base, base.Add(time.Minute), 30*time.Second, tt.fn)
+			require.Len(t, points, len(tt.expected))
+			for i, p := range points {
+				assert.Equal(t, tt.expected[i], p.Value)
+				assert.Equal(t, base.Add(time.Duration(i)*30*time.Second), p.Timestamp)
+			}
+		})
+	}
+}
+
+func TestAggregate_SkipsEmptySteps(t *testing.T) {
+	samples := samplesAt(map[time.Duration]float64{0: 1, 2 * time.Minute: 2}, 0, 2*time.Minute)
+
+	points := Aggregate(samples, base, base.Add(3*time.Minute), time.Minute, FuncLast)
+	require.Len(t, points, 2)
+	assert.Equal(t, base.Add(2*time.Minute), points[1].Timestamp)
+}
+
+func TestAggregate_Rate(t *testing.T) {
+	// Накопленные значения счётчика; в 70s происходит сброс
+	values := map[time.Duration]float64{
+		-10 * time.Second: 100,
+		10 * time.Second:  130,
+		20 * time.Second:  160,
+		40 * time.Second:  190,
+		70 * time.Second:  15,
+	}
+	samples := samplesAt(values, -10*time.Second, 10*time.Second, 20*time.Second, 40*time.Second, 70*time.Second)
+
+	points := Aggregate(samples, base, base.Add(90*time.Second), 30*time.Second, FuncRate)
+	require.Len(t, points, 3)
+	assert.Equal(t, 2.0, points[0].Value)
+	assert.Equal(t, 1.0, points[1].Value)
+	assert.Equal(t, 0.5, points[2].Value)
+}
+
+func TestParseFunc(t *testing.T) {
+	fn, err := ParseFunc("rate", constants.CounterName)
+	require.NoError(t, err)
+	assert.Equal(t, FuncRate, fn)
+
+	_, err = ParseFunc("rate", constants.GaugeName)
+	assert.ErrorIs(t, err, ErrRateForNonCount)
+
+	_, err = ParseFunc("median", constants.GaugeName)
+	assert.ErrorIs(t, err, ErrUnknownFunc)
+}
+
+func TestValidateRange(t *testing.T) {
+	assert.NoError(t, ValidateRange(base, base.Add(time.Hour), time.Minute, DefaultMaxPoints))
+	assert.ErrorIs(t, ValidateRange(base, base.Add(-time.Hour), time.Minute, DefaultMaxPoints), ErrInvalidRange)
+	assert.ErrorIs(t, ValidateRange(base, base.Add(time.Hour), 0, DefaultMaxPoints), ErrInvalidRange)
+	assert.ErrorIs(t, ValidateRange(base, base.Add(24*time.Hour), time.Second, DefaultMaxPoints), ErrTooManyPoints)
+
+	// Граница: час с шагом в минуту — 61 точка, включая обе границы
+	assert.NoError(t, ValidateRange(base, base.Add(time.Hour), time.Minute, 61))
+	assert.ErrorIs(t, ValidateRange(base, base.Add(time.Hour), time.Minute, 60), ErrTooManyPoints)
+
+	// Крайние значения: частное не переполняется, а насыщенный Sub не обходит лимит
+	minTime, maxTime := time.Unix(0, math.MinInt64), time.Unix(0, math.MaxInt64)
+	assert.ErrorIs(t, ValidateRange(minTime, maxTime, time.Nanosecond, DefaultMaxPoints), ErrTooManyPoints)
+	assert.ErrorIs(t, ValidateRange(base, base.Add(math.MaxInt64), time.Nanosecond, DefaultMaxPoints), ErrTooManyPoints)
+	assert.ErrorIs(t, ValidateRange(time.Unix(-1<<40, 0), time.Unix(1<<40, 0), math.MaxInt64, DefaultMaxPoints), ErrTooManyPoints)
+}
@@ -2,10 +2,16 @@ package repositories
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/GarikMirzoyan/metricalert/internal/constants"
 	"github.com/GarikMirzoyan/metricalert/internal/database"
 	"github.com/GarikMirzoyan/metricalert/internal/models"
+	"github.com/GarikMirzoyan/metricalert/internal/sketch"
 )
 
 type MetricRepository struct {
@@ -19,27 +25,64 @@ func NewMetricRepository(DBConn database.DBConn) *MetricRepository {
 }
 
 func (mr *MetricRepository) Update(metric models.Metric, ctx context.Context) error {
-	_, err := mr.DBConn.Exec(ctx, queryInsertSingleMetric, metric.GetName(), metric.GetType(), metric.GetValue())
+	tx, err := mr.DBConn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
 
-	return err
+	labels, err := encodeLabels(metric.GetLabels())
+	if err != nil {
+		return err
+	}
+
+	if hasState(metric) {
+		if err := mr.updateState(tx, metric, labels, ctx); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	value, delta, ok := metricColumns(metric)
+	if !ok {
+		return fmt.Errorf("invalid value for metric %s", metric.GetName())
+	}
+
+	if err := mr.upsert(tx, metric, labels, value, delta, time.Now(), ctx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (mr *MetricRepository) GetGaugeValue(metricName string, ctx context.Context) (float64, error) {
+func (mr *MetricRepository) GetGaugeValue(metricName string, labels models.Labels, ctx context.Context) (float64, error) {
+	encoded, err := encodeLabels(labels)
+	if err != nil {
+		return 0, err
+	}
+
 	var value float64
-	err := mr.DBConn.QueryRow(ctx, querySelectGauge, metricName).Scan(&value)
+	err = mr.DBConn.QueryRow(ctx, querySelectGauge, metricName, encoded).Scan(&value)
 	if err != nil {
 		return 0, err
 	}
 	return value, nil
 }
 
-func (mr *MetricRepository) GetCounterValue(metricName string, ctx context.Context) (int64, error) {
-	var fvalue float64
-	err := mr.DBConn.QueryRow(ctx, querySelectCounter, metricName).Scan(&fvalue)
+func (mr *MetricRepository) GetCounterValue(metricName string, labels models.Labels, ctx context.Context) (int64, error) {
+	encoded, err := encodeLabels(labels)
 	if err != nil {
 		return 0, err
 	}
-	return int64(fvalue), nil
+
+	var value int64
+	err = mr.DBConn.QueryRow(ctx, querySelectCounter, metricName, encoded).Scan(&value)
+	if err != nil {
+		return 0, err
+	}
+	return value, nil
 }
 
 func (mr *MetricRepository) GetAllMetrics(ctx context.Context) (map[string]models.GaugeMetric, map[string]models.CounterMetric, error) {
@@ -55,25 +98,17 @@ func (mr *MetricRepository) GetAllMetrics(ctx context.Context) (map[string]model
 	for rows.Next() {
 		var name string
 		var metricType constants.MetricType
-		var value float64
+		var rawLabels []byte
+		var value *float64
+		var delta *int64
+		var observed *time.Time
 
-		if err := rows.Scan(&name, &metricType, &value); err != nil {
+		if err := rows.Scan(&name, &metricType, &rawLabels, &value, &delta, &observed); err != nil {
 			return nil, nil, err
 		}
 
-		switch metricType {
-		case constants.GaugeName:
-			gauges[name] = models.GaugeMetric{
-				Name:  name,
-				Type:  constants.GaugeName,
-				Value: value,
-			}
-		case constants.CounterName:
-			counters[name] = models.CounterMetric{
-				Name:  name,
-				Type:  constants.CounterName,
-				Value: int64(value),
-			}
+		if err := collectMetric(gauges, counters, name, metricType, rawLabels, value, delta, observed); err != nil {
+			return nil, nil, err
 		}
 	}
 
@@ -85,6 +120,10 @@ func (mr *MetricRepository) GetAllMetrics(ctx context.Context) (map[string]model
 }
 
 func (mr *MetricRepository) BatchUpdate(metrics []models.Metric, ctx context.Context) error {
+	return mr.BatchUpdateOnce("", 0, metrics, ctx)
+}
+
+func (mr *MetricRepository) BatchUpdateOnce(batchID string, ttl time.Duration, metrics []models.Metric, ctx context.Context) error {
 	tx, err := mr.DBConn.Begin(ctx)
 	if err != nil {
 		return err
@@ -93,40 +132,672 @@ func (mr *MetricRepository) BatchUpdate(metrics []models.Metric, ctx context.Con
 		_ = tx.Rollback()
 	}()
 
+	now := time.Now()
+	if batchID != "" {
+		if err := recordBatch(tx, batchID, ttl, now, ctx); err != nil {
+			return err
+		}
+	}
+
 	for _, m := range metrics {
-		name := m.GetName()
-		typ := m.GetType()
-		val := m.GetValue()
+		labels, err := encodeLabels(m.GetLabels())
+		if err != nil {
+			return err
+		}
 
-		if val == nil {
+		if hasState(m) {
+			if err := mr.updateState(tx, m, labels, ctx); err != nil {
+				return err
+			}
 			continue
 		}
 
-		var value float64
+		value, delta, ok := metricColumns(m)
+		if !ok {
+			continue
+		}
 
-		switch typ {
-		case constants.GaugeName:
-			v, ok := val.(float64)
-			if !ok {
-				continue
-			}
-			value = v
+		if err := mr.upsert(tx, m, labels, value, delta, now, ctx); err != nil {
+			return err
+		}
+	}
 
-		case constants.CounterName:
-			v, ok := val.(int64)
-			if !ok {
-				continue
-			}
-			value = float64(v)
+	return tx.Commit()
+}
 
-		default:
-			continue
+// Одновременный батч с тем же идентификатором ждёт фиксации первой транзакции
+// на уникальном индексе и после неё получает ErrDuplicateBatch
+func recordBatch(tx *sql.Tx, batchID string, ttl time.Duration, now time.Time, ctx context.Context) error {
+	if ttl > 0 {
+		if _, err := tx.ExecContext(ctx, queryDeleteExpiredBatches, now.Add(-ttl)); err != nil {
+			return err
 		}
+	}
+
+	var id string
+	err := tx.QueryRowContext(ctx, queryInsertBatch, batchID, now).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrDuplicateBatch
+	}
+	return err
+}
+
+// Запись gauge или counter и значения в историю; в metric записываются сохранённые
+// значение и время наблюдения. Более старый gauge не применяется и в историю не попадает.
+func (mr *MetricRepository) upsert(tx *sql.Tx, metric models.Metric, labels string, value *float64, delta *int64, now time.Time, ctx context.Context) error {
+	recordedAt := observedAt(metric, now)
 
-		if _, err := tx.ExecContext(ctx, queryInsertMetric, name, typ, value); err != nil {
+	var stored storedValue
+	err := tx.QueryRowContext(ctx, queryInsertMetric, metric.GetName(), metric.GetType(), labels, value, delta, recordedAt).
+		Scan(&stored.value, &stored.delta, &stored.observedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		if err := tx.QueryRowContext(ctx, querySelectStoredGauge, metric.GetName(), labels).Scan(&stored.value, &stored.observedAt); err != nil {
 			return err
 		}
+		stored.writeTo(metric)
+		return nil
+	}
+	if err != nil {
+		return err
 	}
 
-	return tx.Commit()
+	if _, err := tx.ExecContext(ctx, queryInsertHistory, metric.GetName(), metric.GetType(), labels, historyValue(value, delta), recordedAt); err != nil {
+		return err
+	}
+	stored.writeTo(metric)
+	return nil
+}
+
+// Слияние состояния с сохранённым под блокировкой строки; в metric записывается итог
+func (mr *MetricRepository) updateState(tx *sql.Tx, metric models.Metric, labels string, ctx context.Context) error {
+	var current []byte
+	err := tx.QueryRowContext(ctx, queryLockMetricState, metric.GetName(), string(metric.GetType()), labels).Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	state, err := mergeState(current, metric)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, queryUpdateMetricState, metric.GetName(), string(metric.GetType()), labels, state)
+	return err
+}
+
+func (mr *MetricRepository) GetHistogram(metricName string, labels models.Labels, ctx context.Context) (models.HistogramMetric, error) {
+	encoded, err := encodeLabels(labels)
+	if err != nil {
+		return models.HistogramMetric{}, err
+	}
+
+	var state []byte
+	err = mr.DBConn.QueryRow(ctx, querySelectMetricState, string(constants.HistogramName), metricName, encoded).Scan(&state)
+	if err != nil {
+		return models.HistogramMetric{}, err
+	}
+	return decodeHistogram(metricName, labels, state)
+}
+
+func (mr *MetricRepository) GetAllHistograms(ctx context.Context) (map[string]models.HistogramMetric, error) {
+	rows, err := mr.DBConn.Query(ctx, querySelectMetricStates, string(constants.HistogramName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	histograms := make(map[string]models.HistogramMetric)
+	for rows.Next() {
+		var name string
+		var rawLabels, state []byte
+		if err := rows.Scan(&name, &rawLabels, &state); err != nil {
+			return nil, err
+		}
+
+		if err := collectHistogram(histograms, name, rawLabels, state); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return histograms, nil
+}
+
+func (mr *MetricRepository) GetSummary(metricName string, labels models.Labels, ctx context.Context) (models.SummaryMetric, error) {
+	encoded, err := encodeLabels(labels)
+	if err != nil {
+		return models.SummaryMetric{}, err
+	}
+
+	var state []byte
+	err = mr.DBConn.QueryRow(ctx, querySelectMetricState, string(constants.SummaryName), metricName, encoded).Scan(&state)
+	if err != nil {
+		return models.SummaryMetric{}, err
+	}
+	return decodeSummary(metricName, labels, state)
+}
+
+func (mr *MetricRepository) GetAllSummaries(ctx context.Context) (map[string]models.SummaryMetric, error) {
+	rows, err := mr.DBConn.Query(ctx, querySelectMetricStates, string(constants.SummaryName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := make(map[string]models.SummaryMetric)
+	for rows.Next() {
+		var name string
+		var rawLabels, state []byte
+		if err := rows.Scan(&name, &rawLabels, &state); err != nil {
+			return nil, err
+		}
+
+		if err := collectSummary(summaries, name, rawLabels, state); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return summaries, nil
+}
+
+func (mr *MetricRepository) GetSet(metricName string, labels models.Labels, ctx context.Context) (models.SetMetric, error) {
+	encoded, err := encodeLabels(labels)
+	if err != nil {
+		return models.SetMetric{}, err
+	}
+
+	var state []byte
+	err = mr.DBConn.QueryRow(ctx, querySelectMetricState, string(constants.SetName), metricName, encoded).Scan(&state)
+	if err != nil {
+		return models.SetMetric{}, err
+	}
+	return decodeSet(metricName, labels, state)
+}
+
+func (mr *MetricRepository) GetAllSets(ctx context.Context) (map[string]models.SetMetric, error) {
+	rows, err := mr.DBConn.Query(ctx, querySelectMetricStates, string(constants.SetName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sets := make(map[string]models.SetMetric)
+	for rows.Next() {
+		var name string
+		var rawLabels, state []byte
+		if err := rows.Scan(&name, &rawLabels, &state); err != nil {
+			return nil, err
+		}
+
+		if err := collectSet(sets, name, rawLabels, state); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sets, nil
+}
+
+func (mr *MetricRepository) GetInfo(metricName string, labels models.Labels, ctx context.Context) (models.InfoMetric, error) {
+	encoded, err := encodeLabels(labels)
+	if err != nil {
+		return models.InfoMetric{}, err
+	}
+
+	var state []byte
+	err = mr.DBConn.QueryRow(ctx, querySelectMetricState, string(constants.InfoName), metricName, encoded).Scan(&state)
+	if err != nil {
+		return models.InfoMetric{}, err
+	}
+	return decodeInfo(metricName, labels, state)
+}
+
+func (mr *MetricRepository) GetAllInfos(ctx context.Context) (map[string]models.InfoMetric, error) {
+	rows, err := mr.DBConn.Query(ctx, querySelectMetricStates, string(constants.InfoName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	infos := make(map[string]models.InfoMetric)
+	for rows.Next() {
+		var name string
+		var rawLabels, state []byte
+		if err := rows.Scan(&name, &rawLabels, &state); err != nil {
+			return nil, err
+		}
+
+		if err := collectInfo(infos, name, rawLabels, state); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return infos, nil
+}
+
+func (mr *MetricRepository) GetHistory(metricType constants.MetricType, name string, labels models.Labels, from, to time.Time, ctx context.Context) ([]models.Sample, error) {
+	encoded, err := encodeLabels(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mr.DBConn.Query(ctx, querySelectHistory, metricType, name, encoded, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := make([]models.Sample, 0)
+	for rows.Next() {
+		var sample models.Sample
+		if err := rows.Scan(&sample.Timestamp, &sample.Value); err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return samples, nil
+}
+
+func (mr *MetricRepository) DeleteHistoryBefore(before time.Time, ctx context.Context) error {
+	_, err := mr.DBConn.Exec(ctx, queryDeleteHistoryBefore, before)
+	return err
+}
+
+// Значение для истории, где обе метрики хранятся как double precision: у gauge — значение,
+// у counter — приращение (накопленные значения вычисляются при чтении, querySelectHistory)
+func historyValue(value *float64, delta *int64) float64 {
+	if delta != nil {
+		return float64(*delta)
+	}
+	if value != nil {
+		return *value
+	}
+	return 0
+}
+
+// Значения для колонок value и delta: у gauge заполнена только value, у counter — только delta.
+// Метрики без значения или с несовпадающим типом значения не сохраняются.
+func metricColumns(m models.Metric) (*float64, *int64, bool) {
+	switch v := m.GetValue().(type) {
+	case float64:
+		return &v, nil, m.GetType() == constants.GaugeName
+	case int64:
+		return nil, &v, m.GetType() == constants.CounterName
+	default:
+		return nil, nil, false
+	}
+}
+
+// Строка gauge или counter после записи
+type storedValue struct {
+	value      *float64
+	delta      *int64
+	observedAt *time.Time
+}
+
+func (s storedValue) writeTo(metric models.Metric) {
+	switch m := metric.(type) {
+	case *models.GaugeMetric:
+		if s.value != nil {
+			m.Value = *s.value
+		}
+		if s.observedAt != nil {
+			m.Timestamp = *s.observedAt
+		}
+	case *models.CounterMetric:
+		if s.delta != nil {
+			m.Value = *s.delta
+		}
+		if s.observedAt != nil {
+			m.Timestamp = *s.observedAt
+		}
+	}
+}
+
+// Время наблюдения gauge и counter; без метки — время получения
+func observedAt(m models.Metric, now time.Time) time.Time {
+	var t time.Time
+	switch v := m.(type) {
+	case *models.GaugeMetric:
+		t = v.Timestamp
+	case *models.CounterMetric:
+		t = v.Timestamp
+	}
+	if t.IsZero() {
+		return now
+	}
+	return t
+}
+
+// Добавление строки из таблицы metrics в результат GetAllMetrics
+func collectMetric(gauges map[string]models.GaugeMetric, counters map[string]models.CounterMetric, name string, metricType constants.MetricType, rawLabels []byte, value *float64, delta *int64, observed *time.Time) error {
+	labels, err := decodeLabels(rawLabels)
+	if err != nil {
+		return err
+	}
+	key := models.SeriesKey(name, labels)
+
+	var timestamp time.Time
+	if observed != nil {
+		timestamp = *observed
+	}
+
+	switch metricType {
+	case constants.GaugeName:
+		if value == nil {
+			return nil
+		}
+		gauges[key] = models.GaugeMetric{
+			Name:      name,
+			Type:      constants.GaugeName,
+			Labels:    labels,
+			Value:     *value,
+			Timestamp: timestamp,
+		}
+	case constants.CounterName:
+		if delta == nil {
+			return nil
+		}
+		counters[key] = models.CounterMetric{
+			Name:      name,
+			Type:      constants.CounterName,
+			Labels:    labels,
+			Value:     *delta,
+			Timestamp: timestamp,
+		}
+	}
+	return nil
+}
+
+// Состояние histogram в колонке state
+type histogramState struct {
+	Buckets []float64 `json:"buckets"`
+	Counts  []uint64  `json:"counts"`
+	Sum     float64   `json:"sum"`
+	Count   uint64    `json:"count"`
+}
+
+func encodeHistogram(metric models.HistogramMetric) (string, error) {
+	data, err := json.Marshal(histogramState{
+		Buckets: metric.Buckets,
+		Counts:  metric.Counts,
+		Sum:     metric.Sum,
+		Count:   metric.Count,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func decodeHistogram(name string, labels models.Labels, data []byte) (models.HistogramMetric, error) {
+	var state histogramState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return models.HistogramMetric{}, err
+	}
+
+	metric := models.HistogramMetric{
+		Name:    name,
+		Type:    constants.HistogramName,
+		Labels:  labels,
+		Buckets: state.Buckets,
+		Counts:  state.Counts,
+		Sum:     state.Sum,
+		Count:   state.Count,
+	}
+	if err := metric.Validate(); err != nil {
+		return models.HistogramMetric{}, err
+	}
+	return metric, nil
+}
+
+// Новое состояние histogram после слияния с текущим (nil — серии ещё нет).
+// В metric записывается итоговое состояние, как у counter после UpdateCounter.
+func mergeHistogram(current []byte, metric *models.HistogramMetric) (string, error) {
+	if err := metric.Validate(); err != nil {
+		return "", err
+	}
+
+	if current != nil {
+		stored, err := decodeHistogram(metric.Name, metric.Labels, current)
+		if err != nil {
+			return "", err
+		}
+		if err := stored.Merge(*metric); err != nil {
+			return "", err
+		}
+		metric.Buckets, metric.Counts = stored.Buckets, stored.Counts
+		metric.Sum, metric.Count = stored.Sum, stored.Count
+	}
+
+	return encodeHistogram(*metric)
+}
+
+// Добавление строки с состоянием histogram в результат GetAllHistograms
+func collectHistogram(histograms map[string]models.HistogramMetric, name string, rawLabels, state []byte) error {
+	labels, err := decodeLabels(rawLabels)
+	if err != nil {
+		return err
+	}
+
+	metric, err := decodeHistogram(name, labels, state)
+	if err != nil {
+		return err
+	}
+	histograms[models.SeriesKey(name, labels)] = metric
+	return nil
+}
+
+// Состояние summary в колонке state — сериализованный скетч
+func encodeSummary(metric models.SummaryMetric) (string, error) {
+	data, err := json.Marshal(metric.Sketch.State())
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func decodeSummary(name string, labels models.Labels, data []byte) (models.SummaryMetric, error) {
+	var state sketch.State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return models.SummaryMetric{}, err
+	}
+
+	s, err := sketch.FromState(&state)
+	if err != nil {
+		return models.SummaryMetric{}, err
+	}
+	return models.SummaryMetric{
+		Name:   name,
+		Type:   constants.SummaryName,
+		Labels: labels,
+		Sketch: s,
+	}, nil
+}
+
+// Новое состояние summary после слияния с текущим (nil — серии ещё нет)
+func mergeSummary(current []byte, metric *models.SummaryMetric) (string, error) {
+	if current != nil {
+		stored, err := decodeSummary(metric.Name, metric.Labels, current)
+		if err != nil {
+			return "", err
+		}
+		if err := stored.Sketch.Merge(metric.Sketch); err != nil {
+			return "", err
+		}
+		metric.Sketch = stored.Sketch
+	}
+
+	return encodeSummary(*metric)
+}
+
+// Добавление строки с состоянием summary в результат GetAllSummaries
+func collectSummary(summaries map[string]models.SummaryMetric, name string, rawLabels, state []byte) error {
+	labels, err := decodeLabels(rawLabels)
+	if err != nil {
+		return err
+	}
+
+	metric, err := decodeSummary(name, labels, state)
+	if err != nil {
+		return err
+	}
+	summaries[models.SeriesKey(name, labels)] = metric
+	return nil
+}
+
+// Состояние set в колонке state — регистры HyperLogLog
+func encodeSet(metric models.SetMetric) (string, error) {
+	data, err := json.Marshal(metric.HLL.State())
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func decodeSet(name string, labels models.Labels, data []byte) (models.SetMetric, error) {
+	var state sketch.HLLState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return models.SetMetric{}, err
+	}
+
+	h, err := sketch.HLLFromState(&state)
+	if err != nil {
+		return models.SetMetric{}, err
+	}
+	return models.SetMetric{
+		Name:   name,
+		Type:   constants.SetName,
+		Labels: labels,
+		HLL:    h,
+	}, nil
+}
+
+// Новое состояние set после объединения с текущим (nil — серии ещё нет)
+func mergeSet(current []byte, metric *models.SetMetric) (string, error) {
+	if current != nil {
+		stored, err := decodeSet(metric.Name, metric.Labels, current)
+		if err != nil {
+			return "", err
+		}
+		if err := stored.HLL.Merge(metric.HLL); err != nil {
+			return "", err
+		}
+		metric.HLL = stored.HLL
+	}
+
+	return encodeSet(*metric)
+}
+
+// Добавление строки с состоянием set в результат GetAllSets
+func collectSet(sets map[string]models.SetMetric, name string, rawLabels, state []byte) error {
+	labels, err := decodeLabels(rawLabels)
+	if err != nil {
+		return err
+	}
+
+	metric, err := decodeSet(name, labels, state)
+	if err != nil {
+		return err
+	}
+	sets[models.SeriesKey(name, labels)] = metric
+	return nil
+}
+
+// Значение info в колонке state — строка JSON
+func decodeInfo(name string, labels models.Labels, data []byte) (models.InfoMetric, error) {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return models.InfoMetric{}, err
+	}
+	return models.InfoMetric{
+		Name:   name,
+		Type:   constants.InfoName,
+		Labels: labels,
+		Value:  value,
+	}, nil
+}
+
+// Добавление строки с состоянием info в результат GetAllInfos
+func collectInfo(infos map[string]models.InfoMetric, name string, rawLabels, state []byte) error {
+	labels, err := decodeLabels(rawLabels)
+	if err != nil {
+		return err
+	}
+
+	metric, err := decodeInfo(name, labels, state)
+	if err != nil {
+		return err
+	}
+	infos[models.SeriesKey(name, labels)] = metric
+	return nil
+}
+
+// Метрики, которые хранятся в колонке state: составные складываются
+// с сохранённым состоянием, info заменяет его
+func hasState(metric models.Metric) bool {
+	switch metric.(type) {
+	case *models.HistogramMetric, *models.SummaryMetric, *models.SetMetric, *models.InfoMetric:
+		return true
+	default:
+		return false
+	}
+}
+
+func mergeState(current []byte, metric models.Metric) (string, error) {
+	switch m := metric.(type) {
+	case *models.HistogramMetric:
+		return mergeHistogram(current, m)
+	case *models.SummaryMetric:
+		return mergeSummary(current, m)
+	case *models.SetMetric:
+		return mergeSet(current, m)
+	case *models.InfoMetric:
+		data, err := json.Marshal(m.Value)
+		return string(data), err
+	default:
+		return "", fmt.Errorf("metric %s has no state", metric.GetName())
+	}
+}
+
+// Метки хранятся в колонке JSONB; отсутствие меток — пустой объект
+func encodeLabels(labels models.Labels) (string, error) {
+	if len(labels) == 0 {
+		return "{}", nil
+	}
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func decodeLabels(data []byte) (models.Labels, error) {
+	var labels models.Labels
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, err
+	}
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	return labels, nil
 }
@@ -0,0 +1,483 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Колонки временной таблицы для COPY
+var stagingColumns = []string{"seq", "name", "type", "labels", "value", "delta", "observed_at"}
+
+// Репозиторий на pgxpool: батч загружается через COPY во временную таблицу
+// и переносится в metrics одним запросом
+type PgxMetricRepository struct {
+	Pool *pgxpool.Pool
+}
+
+func NewPgxMetricRepository(pool *pgxpool.Pool) *PgxMetricRepository {
+	return &PgxMetricRepository{Pool: pool}
+}
+
+func (mr *PgxMetricRepository) Update(metric models.Metric, ctx context.Context) error {
+	if hasState(metric) {
+		return mr.BatchUpdate([]models.Metric{metric}, ctx)
+	}
+
+	value, delta, ok := metricColumns(metric)
+	if !ok {
+		return fmt.Errorf("invalid value for metric %s", metric.GetName())
+	}
+
+	labels, err := encodeLabels(metric.GetLabels())
+	if err != nil {
+		return err
+	}
+
+	tx, err := mr.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	recordedAt := observedAt(metric, time.Now())
+	var stored storedValue
+	err = tx.QueryRow(ctx, queryInsertMetric, metric.GetName(), string(metric.GetType()), labels, value, delta, recordedAt).
+		Scan(&stored.value, &stored.delta, &stored.observedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Сохранённый gauge новее — обновление не применяется, в metric записывается текущее значение
+		if err := tx.QueryRow(ctx, querySelectStoredGauge, metric.GetName(), labels).Scan(&stored.value, &stored.observedAt); err != nil {
+			return err
+		}
+		stored.writeTo(metric)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(ctx, queryInsertHistory, metric.GetName(), string(metric.GetType()), labels, historyValue(value, delta), recordedAt); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	stored.writeTo(metric)
+	return nil
+}
+
+func (mr *PgxMetricRepository) GetGaugeValue(metricName string, labels models.Labels, ctx context.Context) (float64, error) {
+	encoded, err := encodeLabels(labels)
+	if err != nil {
+		return 0, err
+	}
+
+	var value float64
+	err = mr.Pool.QueryRow(ctx, querySelectGauge, metricName, encoded).Scan(&value)
+	if err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+func (mr *PgxMetricRepository) GetCounterValue(metricName string, labels models.Labels, ctx context.Context) (int64, error) {
+	encoded, err := encodeLabels(labels)
+	if err != nil {
+		return 0, err
+	}
+
+	var value int64
+	err = mr.Pool.QueryRow(ctx, querySelectCounter, metricName, encoded).Scan(&value)
+	if err != nil {
+		return 0, err
+	}
+	return value, nil
+}
+
+func (mr *PgxMetricRepository) GetAllMetrics(ctx context.Context) (map[string]models.GaugeMetric, map[string]models.CounterMetric, error) {
+	rows, err := mr.Pool.Query(ctx, querySelectAllMetrics)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+
+	gauges := make(map[string]models.GaugeMetric)
+	counters := make(map[string]models.CounterMetric)
+
+	for rows.Next() {
+		var name, metricType string
+		var rawLabels []byte
+		var value *float64
+		var delta *int64
+		var observed *time.Time
+
+		if err := rows.Scan(&name, &metricType, &rawLabels, &value, &delta, &observed); err != nil {
+			return nil, nil, err
+		}
+
+		if err := collectMetric(gauges, counters, name, constants.MetricType(metricType), rawLabels, value, delta, observed); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	return gauges, counters, nil
+}
+
+func (mr *PgxMetricRepository) BatchUpdate(metrics []models.Metric, ctx context.Context) error {
+	return mr.BatchUpdateOnce("", 0, metrics, ctx)
+}
+
+func (mr *PgxMetricRepository) BatchUpdateOnce(batchID string, ttl time.Duration, metrics []models.Metric, ctx context.Context) error {
+	rows, err := stagingRows(metrics)
+	if err != nil {
+		return err
+	}
+
+	var stateful []models.Metric
+	for _, m := range metrics {
+		if hasState(m) {
+			stateful = append(stateful, m)
+		}
+	}
+
+	if len(rows) == 0 && len(stateful) == 0 {
+		return nil
+	}
+
+	tx, err := mr.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	if batchID != "" {
+		if err := recordBatchPgx(tx, batchID, ttl, ctx); err != nil {
+			return err
+		}
+	}
+
+	if len(rows) > 0 {
+		if _, err := tx.Exec(ctx, queryCreateStaging); err != nil {
+			return err
+		}
+
+		if _, err := tx.CopyFrom(ctx, pgx.Identifier{"metrics_staging"}, stagingColumns, pgx.CopyFromRows(rows)); err != nil {
+			return err
+		}
+
+		stored, err := mergeStaging(tx, ctx)
+		if err != nil {
+			return err
+		}
+		for _, m := range metrics {
+			if row, ok := stored[models.TypedSeriesKey(string(m.GetType()), m.GetName(), m.GetLabels())]; ok {
+				row.writeTo(m)
+			}
+		}
+	}
+
+	// Histogram, summary, set и info обновляются по одной под блокировкой строки, в той же транзакции
+	for _, metric := range stateful {
+		if err := updateState(tx, metric, ctx); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+func recordBatchPgx(tx pgx.Tx, batchID string, ttl time.Duration, ctx context.Context) error {
+	now := time.Now()
+	if ttl > 0 {
+		if _, err := tx.Exec(ctx, queryDeleteExpiredBatches, now.Add(-ttl)); err != nil {
+			return err
+		}
+	}
+
+	var id string
+	err := tx.QueryRow(ctx, queryInsertBatch, batchID, now).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrDuplicateBatch
+	}
+	return err
+}
+
+// Перенос батча из временной таблицы; результат — сохранённые значения по сериям
+func mergeStaging(tx pgx.Tx, ctx context.Context) (map[string]storedValue, error) {
+	rows, err := tx.Query(ctx, queryMergeStaging)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	stored := make(map[string]storedValue)
+	for rows.Next() {
+		var name, metricType string
+		var rawLabels []byte
+		var row storedValue
+		if err := rows.Scan(&name, &metricType, &rawLabels, &row.value, &row.delta, &row.observedAt); err != nil {
+			return nil, err
+		}
+
+		labels, err := decodeLabels(rawLabels)
+		if err != nil {
+			return nil, err
+		}
+		stored[models.TypedSeriesKey(metricType, name, labels)] = row
+	}
+	return stored, rows.Err()
+}
+
+func updateState(tx pgx.Tx, metric models.Metric, ctx context.Context) error {
+	labels, err := encodeLabels(metric.GetLabels())
+	if err != nil {
+		return err
+	}
+
+	var current []byte
+	err = tx.QueryRow(ctx, queryLockMetricState, metric.GetName(), string(metric.GetType()), labels).Scan(&current)
+	if err != nil {
+		return err
+	}
+
+	state, err := mergeState(current, metric)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, queryUpdateMetricState, metric.GetName(), string(metric.GetType()), labels, state)
+	return err
+}
+
+func (mr *PgxMetricRepository) GetHistogram(metricName string, labels models.Labels, ctx context.Context) (models.HistogramMetric, error) {
+	encoded, err := encodeLabels(labels)
+	if err != nil {
+		return models.HistogramMetric{}, err
+	}
+
+	var state []byte
+	err = mr.Pool.QueryRow(ctx, querySelectMetricState, string(constants.HistogramName), metricName, encoded).Scan(&state)
+	if err != nil {
+		return models.HistogramMetric{}, err
+	}
+	return decodeHistogram(metricName, labels, state)
+}
+
+func (mr *PgxMetricRepository) GetAllHistograms(ctx context.Context) (map[string]models.HistogramMetric, error) {
+	rows, err := mr.Pool.Query(ctx, querySelectMetricStates, string(constants.HistogramName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	histograms := make(map[string]models.HistogramMetric)
+	for rows.Next() {
+		var name string
+		var rawLabels, state []byte
+		if err := rows.Scan(&name, &rawLabels, &state); err != nil {
+			return nil, err
+		}
+
+		if err := collectHistogram(histograms, name, rawLabels, state); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return histograms, nil
+}
+
+func (mr *PgxMetricRepository) GetSummary(metricName string, labels models.Labels, ctx context.Context) (models.SummaryMetric, error) {
+	encoded, err := encodeLabels(labels)
+	if err != nil {
+		return models.SummaryMetric{}, err
+	}
+
+	var state []byte
+	err = mr.Pool.QueryRow(ctx, querySelectMetricState, string(constants.SummaryName), metricName, encoded).Scan(&state)
+	if err != nil {
+		return models.SummaryMetric{}, err
+	}
+	return decodeSummary(metricName, labels, state)
+}
+
+func (mr *PgxMetricRepository) GetAllSummaries(ctx context.Context) (map[string]models.SummaryMetric, error) {
+	rows, err := mr.Pool.Query(ctx, querySelectMetricStates, string(constants.SummaryName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summaries := make(map[string]models.SummaryMetric)
+	for rows.Next() {
+		var name string
+		var rawLabels, state []byte
+		if err := rows.Scan(&name, &rawLabels, &state); err != nil {
+			return nil, err
+		}
+
+		if err := collectSummary(summaries, name, rawLabels, state); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return summaries, nil
+}
+
+func (mr *PgxMetricRepository) GetSet(metricName string, labels models.Labels, ctx context.Context) (models.SetMetric, error) {
+	encoded, err := encodeLabels(labels)
+	if err != nil {
+		return models.SetMetric{}, err
+	}
+
+	var state []byte
+	err = mr.Pool.QueryRow(ctx, querySelectMetricState, string(constants.SetName), metricName, encoded).Scan(&state)
+	if err != nil {
+		return models.SetMetric{}, err
+	}
+	return decodeSet(metricName, labels, state)
+}
+
+func (mr *PgxMetricRepository) GetAllSets(ctx context.Context) (map[string]models.SetMetric, error) {
+	rows, err := mr.Pool.Query(ctx, querySelectMetricStates, string(constants.SetName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sets := make(map[string]models.SetMetric)
+	for rows.Next() {
+		var name string
+		var rawLabels, state []byte
+		if err := rows.Scan(&name, &rawLabels, &state); err != nil {
+			return nil, err
+		}
+
+		if err := collectSet(sets, name, rawLabels, state); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sets, nil
+}
+
+func (mr *PgxMetricRepository) GetInfo(metricName string, labels models.Labels, ctx context.Context) (models.InfoMetric, error) {
+	encoded, err := encodeLabels(labels)
+	if err != nil {
+		return models.InfoMetric{}, err
+	}
+
+	var state []byte
+	err = mr.Pool.QueryRow(ctx, querySelectMetricState, string(constants.InfoName), metricName, encoded).Scan(&state)
+	if err != nil {
+		return models.InfoMetric{}, err
+	}
+	return decodeInfo(metricName, labels, state)
+}
+
+func (mr *PgxMetricRepository) GetAllInfos(ctx context.Context) (map[string]models.InfoMetric, error) {
+	rows, err := mr.Pool.Query(ctx, querySelectMetricStates, string(constants.InfoName))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	infos := make(map[string]models.InfoMetric)
+	for rows.Next() {
+		var name string
+		var rawLabels, state []byte
+		if err := rows.Scan(&name, &rawLabels, &state); err != nil {
+			return nil, err
+		}
+
+		if err := collectInfo(infos, name, rawLabels, state); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return infos, nil
+}
+
+func (mr *PgxMetricRepository) GetHistory(metricType constants.MetricType, name string, labels models.Labels, from, to time.Time, ctx context.Context) ([]models.Sample, error) {
+	encoded, err := encodeLabels(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := mr.Pool.Query(ctx, querySelectHistory, string(metricType), name, encoded, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := make([]models.Sample, 0)
+	for rows.Next() {
+		var sample models.Sample
+		if err := rows.Scan(&sample.Timestamp, &sample.Value); err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return samples, nil
+}
+
+func (mr *PgxMetricRepository) DeleteHistoryBefore(before time.Time, ctx context.Context) error {
+	_, err := mr.Pool.Exec(ctx, queryDeleteHistoryBefore, before)
+	return err
+}
+
+// Строки для COPY; порядковый номер нужен, чтобы при слиянии взять последний gauge.
+// Метрики без значения или неизвестного типа пропускаются, как и в MetricRepository.
+func stagingRows(metrics []models.Metric) ([][]any, error) {
+	now := time.Now()
+	rows := make([][]any, 0, len(metrics))
+	for i, m := range metrics {
+		value, delta, ok := metricColumns(m)
+		if !ok {
+			continue
+		}
+
+		labels, err := encodeLabels(m.GetLabels())
+		if err != nil {
+			return nil, err
+		}
+
+		rows = append(rows, []any{int64(i), m.GetName(), string(m.GetType()), []byte(labels), value, delta, observedAt(m, now)})
+	}
+	return rows, nil
+}
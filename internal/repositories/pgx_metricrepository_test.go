@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStagingRows(t *testing.T) {
+	earlier := time.UnixMilli(1_700_000_000_000)
+	later := earlier.Add(time.Second)
+	metrics := []models.Metric{
+		&models.GaugeMetric{Name: "Alloc", Type: constants.GaugeName, Value: 1.5, Timestamp: later},
+		&models.CounterMetric{Name: "Requests", Type: constants.CounterName, Labels: models.Labels{"host": "web-1"}, Value: 3, Timestamp: earlier},
+		// Тип не совпадает со значением — строка пропускается
+		&models.GaugeMetric{Name: "Broken", Type: constants.CounterName, Value: 2},
+		&models.CounterMetric{Name: "Requests", Type: constants.CounterName, Labels: models.Labels{"host": "web-1"}, Value: 4, Timestamp: later},
+	}
+
+	rows, err := stagingRows(metrics)
+	require.NoError(t, err)
+
+	gauge := 1.5
+	first, second := int64(3), int64(4)
+	assert.Equal(t, [][]any{
+		{int64(0), "Alloc", "gauge", []byte("{}"), &gauge, (*int64)(nil), later},
+		{int64(1), "Requests", "counter", []byte(`{"host":"web-1"}`), (*float64)(nil), &first, earlier},
+		{int64(3), "Requests", "counter", []byte(`{"host":"web-1"}`), (*float64)(nil), &second, later},
+	}, rows)
+}
+
+func TestMetricColumnsKeepCounterExact(t *testing.T) {
+	// 2^53 + 1 не представимо в double precision
+	const big = int64(1<<53 + 1)
+
+	value, delta, ok := metricColumns(&models.CounterMetric{Name: "Big", Type: constants.CounterName, Value: big})
+	require.True(t, ok)
+	assert.Nil(t, value)
+	require.NotNil(t, delta)
+	assert.Equal(t, big, *delta)
+
+	value, delta, ok = metricColumns(&models.GaugeMetric{Name: "Alloc", Type: constants.GaugeName, Value: 2.5})
+	require.True(t, ok)
+	assert.Nil(t, delta)
+	assert.Equal(t, 2.5, *value)
+}
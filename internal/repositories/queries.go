@@ -1,32 +1,168 @@
 package repositories
 
 const (
+	// Gauge хранится в value, counter — в delta (BIGINT); вторая колонка остаётся NULL.
+	// Серия определяется (type, name, labels), поэтому при конфликте тип всегда совпадает:
+	// gauge заменяется, counter суммируется.
+	// Gauge с более ранним временем наблюдения, чем сохранённое, не применяется, и запрос
+	// не возвращает строк; observed_at у counter — время самого позднего приращения.
+	// Возвращаются сохранённые значение и время наблюдения.
 	queryInsertMetric = `
-		INSERT INTO metrics (name, type, value)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (name) DO UPDATE
-		SET value = CASE
-			WHEN EXCLUDED.type = 'counter' THEN metrics.value + EXCLUDED.value
-			ELSE EXCLUDED.value
-		END
+		INSERT INTO metrics (name, type, labels, value, delta, observed_at)
+		VALUES ($1, $2, $3::jsonb, $4::double precision, $5::bigint, $6::timestamptz)
+		ON CONFLICT (type, name, labels) DO UPDATE
+		SET value = EXCLUDED.value,
+			delta = COALESCE(metrics.delta, 0) + EXCLUDED.delta,
+			observed_at = GREATEST(metrics.observed_at, EXCLUDED.observed_at)
+		WHERE metrics.type = 'counter' OR metrics.observed_at IS NULL OR EXCLUDED.observed_at >= metrics.observed_at
+		RETURNING value, delta, observed_at
 	`
 
-	queryInsertSingleMetric = `
-		INSERT INTO metrics (name, type, value)
-		VALUES ($1, $2, $3::double precision)
-		ON CONFLICT (name) DO UPDATE
-		SET value = metrics.value + EXCLUDED.value
+	// Текущий gauge, если более старое наблюдение не применилось
+	querySelectStoredGauge = `
+		SELECT value, observed_at FROM metrics WHERE name = $1 AND labels = $2::jsonb AND type = 'gauge'
 	`
 
 	querySelectGauge = `
-		SELECT value FROM metrics WHERE name = $1 AND type = 'gauge'
+		SELECT value FROM metrics WHERE name = $1 AND labels = $2::jsonb AND type = 'gauge'
 	`
 
 	querySelectCounter = `
-		SELECT value FROM metrics WHERE name = $1 AND type = 'counter'
+		SELECT delta FROM metrics WHERE name = $1 AND labels = $2::jsonb AND type = 'counter'
+	`
+
+	// Строка метрики составного типа блокируется до конца транзакции (если её нет —
+	// создаётся с пустым состоянием), поэтому параллельные слияния не теряют обновлений
+	queryLockMetricState = `
+		INSERT INTO metrics (name, type, labels)
+		VALUES ($1, $2, $3::jsonb)
+		ON CONFLICT (type, name, labels) DO UPDATE
+		SET name = EXCLUDED.name
+		RETURNING state
+	`
+
+	queryUpdateMetricState = `
+		UPDATE metrics SET state = $4::jsonb WHERE name = $1 AND type = $2 AND labels = $3::jsonb
+	`
+
+	querySelectMetricState = `
+		SELECT state FROM metrics WHERE type = $1 AND name = $2 AND labels = $3::jsonb AND state IS NOT NULL
+	`
+
+	querySelectMetricStates = `
+		SELECT name, labels, state FROM metrics WHERE type = $1 AND state IS NOT NULL
 	`
 
 	querySelectAllMetrics = `
-		SELECT name, type, value FROM metrics
+		SELECT name, type, labels, value, delta, observed_at FROM metrics
+	`
+
+	queryInsertHistory = `
+		INSERT INTO metrics_history (name, type, labels, value, recorded_at)
+		VALUES ($1, $2, $3::jsonb, $4, $5)
+	`
+
+	// Идентификатор батча записывается в транзакции с метриками; отсутствие строки в ответе —
+	// батч уже применён. Перед записью удаляются идентификаторы старше срока хранения.
+	queryDeleteExpiredBatches = `
+		DELETE FROM metric_batches WHERE applied_at < $1
+	`
+
+	queryInsertBatch = `
+		INSERT INTO metric_batches (id, applied_at) VALUES ($1, $2)
+		ON CONFLICT (id) DO NOTHING
+		RETURNING id
+	`
+
+	queryDeleteHistoryBefore = `
+		DELETE FROM metrics_history WHERE recorded_at < $1
+	`
+
+	// История counter хранит приращения: накопленное значение в момент наблюдения —
+	// итог из metrics за вычетом всех более поздних приращений серии. Так приращение,
+	// пришедшее не по порядку, учитывается во всех следующих значениях.
+	querySelectHistory = `
+		SELECT recorded_at, value FROM (
+			SELECT h.id, h.recorded_at,
+				CASE WHEN h.type = 'counter'
+					THEN m.delta - COALESCE(SUM(h.value) OVER (
+						ORDER BY h.recorded_at DESC, h.id DESC
+						ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+					), 0)
+					ELSE h.value
+				END AS value
+			FROM metrics_history h
+			LEFT JOIN metrics m ON m.type = h.type AND m.name = h.name AND m.labels = h.labels
+			WHERE h.type = $1 AND h.name = $2 AND h.labels = $3::jsonb AND h.recorded_at >= $4
+		) series
+		WHERE recorded_at <= $5
+		ORDER BY recorded_at, id
+	`
+
+	// Временная таблица для пакетной загрузки через COPY; своя у каждого подключения,
+	// строки удаляются при завершении транзакции
+	queryCreateStaging = `
+		CREATE TEMP TABLE IF NOT EXISTS metrics_staging (
+			seq BIGINT NOT NULL,
+			name TEXT NOT NULL,
+			type TEXT NOT NULL,
+			labels JSONB NOT NULL,
+			value DOUBLE PRECISION,
+			delta BIGINT,
+			observed_at TIMESTAMPTZ NOT NULL
+		) ON COMMIT DELETE ROWS
+	`
+
+	// Слияние батча одним запросом: counter одной серии суммируются, для gauge
+	// остаётся значение с самым поздним временем наблюдения (при равенстве — последнее
+	// в батче) и только если оно не старше сохранённого.
+	// История пишется по строке на каждую строку батча, как при обновлении по одной:
+	// приращение counter или значение gauge, не старшее сохранённого и предыдущих
+	// в батче. Основной запрос и CTE видят таблицы до изменений в CTE, поэтому
+	// metrics в history — сохранённое до батча значение, а для неприменённого gauge
+	// возвращаются прежние значение и время.
+	queryMergeStaging = `
+		WITH staged AS (
+			SELECT seq, name, type, labels, value, delta, observed_at,
+				MAX(observed_at) OVER (
+					PARTITION BY type, name, labels ORDER BY seq
+					ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+				) AS previous_at
+			FROM metrics_staging
+		),
+		batch AS (
+			SELECT DISTINCT ON (type, name, labels)
+				name, type, labels, value, observed_at,
+				SUM(delta) OVER (PARTITION BY type, name, labels) AS delta
+			FROM metrics_staging
+			ORDER BY type, name, labels, observed_at DESC, seq DESC
+		),
+		merged AS (
+			INSERT INTO metrics (name, type, labels, value, delta, observed_at)
+			SELECT name, type, labels, value, delta, observed_at FROM batch
+			ON CONFLICT (type, name, labels) DO UPDATE
+			SET value = EXCLUDED.value,
+				delta = COALESCE(metrics.delta, 0) + EXCLUDED.delta,
+				observed_at = GREATEST(metrics.observed_at, EXCLUDED.observed_at)
+			WHERE metrics.type = 'counter' OR metrics.observed_at IS NULL OR EXCLUDED.observed_at >= metrics.observed_at
+			RETURNING name, type, labels, value, delta, observed_at
+		),
+		history AS (
+			INSERT INTO metrics_history (name, type, labels, value, recorded_at)
+			SELECT staged.name, staged.type, staged.labels,
+				COALESCE(staged.delta::double precision, staged.value), staged.observed_at
+			FROM staged
+			LEFT JOIN metrics ON metrics.type = staged.type AND metrics.name = staged.name AND metrics.labels = staged.labels
+			WHERE staged.type = 'counter'
+				OR staged.observed_at >= COALESCE(GREATEST(staged.previous_at, metrics.observed_at), '-infinity'::timestamptz)
+			ORDER BY staged.seq
+		)
+		SELECT batch.name, batch.type, batch.labels,
+			CASE WHEN merged.name IS NULL THEN metrics.value ELSE merged.value END,
+			CASE WHEN merged.name IS NULL THEN metrics.delta ELSE merged.delta END,
+			CASE WHEN merged.name IS NULL THEN metrics.observed_at ELSE merged.observed_at END
+		FROM batch
+		LEFT JOIN merged ON merged.type = batch.type AND merged.name = batch.name AND merged.labels = batch.labels
+		LEFT JOIN metrics ON metrics.type = batch.type AND metrics.name = batch.name AND metrics.labels = batch.labels
 	`
 )
@@ -0,0 +1,40 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+)
+
+var ErrDuplicateBatch = errors.New("batch already applied")
+
+// Хранение метрик в PostgreSQL; реализуется через database/sql (MetricRepository)
+// и через pgxpool с пакетной загрузкой COPY (PgxMetricRepository).
+// Update и BatchUpdate складывают histogram, summary и set с сохранёнными и записывают итог в метрику;
+// info хранится в той же колонке state и просто заменяется. Для gauge и counter в метрику
+// записываются сохранённые значение и время наблюдения — в том числе когда более старый gauge
+// не применился.
+type Repository interface {
+	Update(metric models.Metric, ctx context.Context) error
+	GetGaugeValue(metricName string, labels models.Labels, ctx context.Context) (float64, error)
+	GetCounterValue(metricName string, labels models.Labels, ctx context.Context) (int64, error)
+	GetHistogram(metricName string, labels models.Labels, ctx context.Context) (models.HistogramMetric, error)
+	GetSummary(metricName string, labels models.Labels, ctx context.Context) (models.SummaryMetric, error)
+	GetSet(metricName string, labels models.Labels, ctx context.Context) (models.SetMetric, error)
+	GetInfo(metricName string, labels models.Labels, ctx context.Context) (models.InfoMetric, error)
+	GetAllMetrics(ctx context.Context) (map[string]models.GaugeMetric, map[string]models.CounterMetric, error)
+	GetAllHistograms(ctx context.Context) (map[string]models.HistogramMetric, error)
+	GetAllSummaries(ctx context.Context) (map[string]models.SummaryMetric, error)
+	GetAllSets(ctx context.Context) (map[string]models.SetMetric, error)
+	GetAllInfos(ctx context.Context) (map[string]models.InfoMetric, error)
+	BatchUpdate(metrics []models.Metric, ctx context.Context) error
+	// BatchUpdate с записью идентификатора батча в той же транзакции; повтор идентификатора,
+	// записанного не раньше чем ttl назад, — ErrDuplicateBatch без изменений (ttl 0 — всегда)
+	BatchUpdateOnce(batchID string, ttl time.Duration, metrics []models.Metric, ctx context.Context) error
+	GetHistory(metricType constants.MetricType, name string, labels models.Labels, from, to time.Time, ctx context.Context) ([]models.Sample, error)
+	// Удаление значений истории, записанных раньше before
+	DeleteHistoryBefore(before time.Time, ctx context.Context) error
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/GarikMirzoyan/metricalert/internal/constants"
+	"github.com/GarikMirzoyan/metricalert/internal/database"
+	"github.com/GarikMirzoyan/metricalert/internal/models"
+)
+
+// Бенчмарки пакетной записи на реальной базе:
+// DATABASE_DSN=postgres://... go test -run=^$ -bench=BatchUpdate ./internal/repositories/
+func benchmarkDSN(b *testing.B) string {
+	dsn := os.Getenv("DATABASE_DSN")
+	if dsn == "" {
+		b.Skip("DATABASE_DSN не задан")
+	}
+	return dsn
+}
+
+func benchmarkBatch(size int) []models.Metric {
+	batch := make([]models.Metric, 0, size)
+	for i := 0; i < size; i++ {
+		labels := models.Labels{"host": fmt.Sprintf("bench-%d", i%10)}
+		if i%2 == 0 {
+			batch = append(batch, &models.GaugeMetric{Name: fmt.Sprintf("BenchGauge%d", i), Type: constants.GaugeName, Labels: labels, Value: float64(i)})
+		} else {
+			batch = append(batch, &models.CounterMetric{Name: fmt.Sprintf("BenchCounter%d", i), Type: constants.CounterName, Labels: labels, Value: 1})
+		}
+	}
+	return batch
+}
+
+func runBatchUpdate(b *testing.B, repo Repository) {
+	ctx := context.Background()
+	for _, size := range []int{10, 100, 1000} {
+		batch := benchmarkBatch(size)
+		b.Run(fmt.Sprintf("batch=%d", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if err := repo.BatchUpdate(batch, ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkBatchUpdate(b *testing.B) {
+	dsn := benchmarkDSN(b)
+
+	b.Run("sql", func(b *testing.B) {
+		conn, err := database.NewDBConnection(dsn)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer conn.Close()
+		if err := conn.RunMigrations(); err != nil {
+			b.Fatal(err)
+		}
+
+		runBatchUpdate(b, NewMetricRepository(conn))
+	})
+
+	b.Run("pgxpool", func(b *testing.B) {
+		pool, err := database.NewPool(dsn)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer pool.Close()
+		if err := pool.RunMigrations(); err != nil {
+			b.Fatal(err)
+		}
+
+		runBatchUpdate(b, NewPgxMetricRepository(pool.Pool))
+	})
+}
@@ -1,7 +1,9 @@
 package retry
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net"
@@ -12,26 +14,40 @@ import (
 	"github.com/jackc/pgx/v5/pgconn"
 )
 
-func WithBackoff(action func() error) error {
-	delays := []time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second}
-	var lastErr error
+// Ошибка, которую стоит повторить (например, ответ сервера 5xx)
+var ErrRetriable = errors.New("retriable error")
 
-	for i, delay := range delays {
+// Задержки между попытками по умолчанию
+var DefaultDelays = []time.Duration{1 * time.Second, 3 * time.Second, 5 * time.Second}
+
+func WithBackoff(ctx context.Context, action func() error) error {
+	return WithBackoffDelays(ctx, DefaultDelays, action)
+}
+
+// Выполнение action с повторами retriable-ошибок: не больше len(delays) попыток
+// (минимум одна), после неудачной попытки ждём очередную задержку из delays.
+// После последней попытки ожидания нет, поэтому последняя задержка не используется;
+// при отмене ctx ожидание прерывается и возвращается последняя ошибка.
+func WithBackoffDelays(ctx context.Context, delays []time.Duration, action func() error) error {
+	for i := 0; ; i++ {
 		err := action()
-		if err == nil {
-			return nil
-		}
-		if !IsRetriableError(err) {
+		if err == nil || !IsRetriableError(err) || i >= len(delays)-1 {
 			return err
 		}
-		lastErr = err
-		log.Printf("попытка %d неудачна: %v — повтор через %s", i+1, err, delay)
-		time.Sleep(delay)
+
+		log.Printf("попытка %d неудачна: %v — повтор через %s", i+1, err, delays[i])
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (повтор отменён: %v)", err, ctx.Err())
+		case <-time.After(delays[i]):
+		}
 	}
-	return lastErr
 }
 
 func IsRetriableError(err error) bool {
+	if errors.Is(err, ErrRetriable) {
+		return true
+	}
 	var netErr net.Error
 	if errors.As(err, &netErr) {
 		return true
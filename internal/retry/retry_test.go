@@ -0,0 +1,53 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithBackoffDelays_NoSleepAfterLastAttempt(t *testing.T) {
+	attempts := 0
+	start := time.Now()
+	err := WithBackoffDelays(context.Background(), []time.Duration{10 * time.Millisecond, 10 * time.Millisecond, time.Hour}, func() error {
+		attempts++
+		return fmt.Errorf("unavailable: %w", ErrRetriable)
+	})
+
+	// Попыток столько же, сколько задержек
+	require.ErrorIs(t, err, ErrRetriable)
+	assert.Equal(t, 3, attempts)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestWithBackoffDelays_NonRetriable(t *testing.T) {
+	attempts := 0
+	err := WithBackoffDelays(context.Background(), []time.Duration{time.Hour}, func() error {
+		attempts++
+		return errors.New("bad request")
+	})
+
+	require.Error(t, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestWithBackoffDelays_ContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	attempts := 0
+	start := time.Now()
+	err := WithBackoffDelays(ctx, []time.Duration{time.Hour, time.Hour}, func() error {
+		attempts++
+		return fmt.Errorf("unavailable: %w", ErrRetriable)
+	})
+
+	require.ErrorIs(t, err, ErrRetriable)
+	assert.Equal(t, 1, attempts)
+	assert.Less(t, time.Since(start), time.Second)
+}
@@ -2,8 +2,19 @@ package config
 
 import (
 	"flag"
+	"log"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/GarikMirzoyan/metricalert/internal/query"
+)
+
+// Реализации хранилища в базе данных
+const (
+	DBBackendSQL     = "sql"
+	DBBackendPgxPool = "pgxpool"
 )
 
 // Структура конфигурации для сервера
@@ -13,6 +24,21 @@ type Config struct {
 	Restore            bool
 	Address            string
 	DBConnectionString string
+	DBBackend          string
+	RulesFile          string
+	AlertInterval      time.Duration
+	WebhookURLs        []string
+	HistorySize        int
+	HistoryRetention   time.Duration
+	HistogramBuckets   []float64
+	QueryMaxPoints     int
+	MaxSampleAge       time.Duration
+	MaxSampleFuture    time.Duration
+	SampleTimeMode     string
+	BatchTTL           time.Duration
+	Key                string
+	CryptoKey          string
+	GRPCAddress        string
 }
 
 func InitConfig() Config {
@@ -21,13 +47,43 @@ func InitConfig() Config {
 	defaultRestore := true
 	//"postgres://mirzoangarikaregovic@localhost:5432/metricalert"
 	defaultDBConnectionString := ""
+	defaultDBBackend := DBBackendSQL
+	defaultRulesFile := ""
+	defaultAlertInterval := 10 * time.Second
+	defaultWebhookURLs := ""
+	defaultHistorySize := 1000
+	defaultHistoryRetention := 7 * 24 * time.Hour
+	defaultQueryMaxPoints := query.DefaultMaxPoints
+	defaultHistogramBuckets := "0.005,0.01,0.025,0.05,0.1,0.25,0.5,1,2.5,5,10"
+	defaultMaxSampleAge := time.Duration(0)
+	defaultMaxSampleFuture := time.Minute
+	defaultSampleTimeMode := "clamp"
+	defaultBatchTTL := 24 * time.Hour
+	defaultKey := ""
+	defaultCryptoKey := ""
+	defaultGRPCAddress := ""
 
 	defaultAddress := "localhost:8080"
 	address := flag.String("a", defaultAddress, "HTTP server address (without http:// or https://)")
-	storeInterval := flag.Int("i", int(defaultStoreInterval.Seconds()), "Interval for saving metrics (in seconds)")
+	storeInterval := flag.Int("i", int(defaultStoreInterval.Seconds()), "Interval for saving metrics (in seconds, 0 - fsync every update before responding; otherwise the update log is fsynced every second)")
 	fileStoragePath := flag.String("f", defaultFileStoragePath, "Path to file where metrics will be saved")
 	restore := flag.Bool("r", defaultRestore, "Restore metrics from file on start (true/false)")
 	DBConnectionString := flag.String("d", defaultDBConnectionString, "DB connction string")
+	dbBackend := flag.String("db-backend", defaultDBBackend, "Database backend: sql (database/sql) or pgxpool (COPY-based batch ingestion)")
+	rulesFile := flag.String("rules", defaultRulesFile, "Path to JSON file with alerting rules")
+	alertInterval := flag.Int("alert-interval", int(defaultAlertInterval.Seconds()), "Interval for evaluating alerting rules (in seconds)")
+	key := flag.String("k", defaultKey, "Key for HMAC-SHA256 signing of requests and responses")
+	cryptoKey := flag.String("crypto-key", defaultCryptoKey, "Path to PEM file with RSA private key for decrypting agent payloads")
+	historySize := flag.Int("history-size", defaultHistorySize, "Number of values kept in memory per metric")
+	historyRetention := flag.Int("history-retention", int(defaultHistoryRetention.Seconds()), "Delete metric history older than this many seconds (0 - keep forever)")
+	histogramBuckets := flag.String("histogram-buckets", defaultHistogramBuckets, "Comma-separated increasing bucket bounds for histograms built from single observations")
+	queryMaxPoints := flag.Int("query-max-points", defaultQueryMaxPoints, "Maximum number of points returned by /query (from, to and step beyond it are rejected)")
+	maxSampleAge := flag.Int("max-sample-age", int(defaultMaxSampleAge.Seconds()), "Limit for sample timestamps older than this many seconds (0 - no limit)")
+	maxSampleFuture := flag.Int("max-sample-future", int(defaultMaxSampleFuture.Seconds()), "Limit for sample timestamps more than this many seconds ahead of server time (0 - no limit)")
+	sampleTimeMode := flag.String("sample-time-mode", defaultSampleTimeMode, "Handling of sample timestamps outside the limits: reject (400 Bad Request), clamp (move to the limit) or accept (store as is)")
+	batchTTL := flag.Int("batch-ttl", int(defaultBatchTTL.Seconds()), "How long applied batch IDs are remembered to reject retries (in seconds, 0 - forever)")
+	grpcAddress := flag.String("grpc-address", defaultGRPCAddress, "gRPC server address, empty to disable gRPC")
+	webhookURLs := flag.String("webhooks", defaultWebhookURLs, "Comma-separated webhook URLs for alert notifications")
 	flag.Parse()
 
 	if envAddress := os.Getenv("ADDRESS"); envAddress != "" {
@@ -38,6 +94,14 @@ func InitConfig() Config {
 		*DBConnectionString = envDBDSN
 	}
 
+	if envDBBackend := os.Getenv("DB_BACKEND"); envDBBackend != "" {
+		*dbBackend = envDBBackend
+	}
+
+	if *dbBackend != DBBackendSQL && *dbBackend != DBBackendPgxPool {
+		log.Fatalf("неизвестная реализация хранилища %q: ожидается %s или %s", *dbBackend, DBBackendSQL, DBBackendPgxPool)
+	}
+
 	// Чтение из переменных окружения
 	if envStoreInterval := os.Getenv("STORE_INTERVAL"); envStoreInterval != "" {
 		if si, err := time.ParseDuration(envStoreInterval + "s"); err == nil {
@@ -53,11 +117,128 @@ func InitConfig() Config {
 		*restore = envRestore == "true"
 	}
 
+	if envRulesFile := os.Getenv("RULES_FILE"); envRulesFile != "" {
+		*rulesFile = envRulesFile
+	}
+
+	if envAlertInterval := os.Getenv("ALERT_INTERVAL"); envAlertInterval != "" {
+		if ai, err := time.ParseDuration(envAlertInterval + "s"); err == nil {
+			*alertInterval = int(ai.Seconds())
+		}
+	}
+
+	if envKey := os.Getenv("KEY"); envKey != "" {
+		*key = envKey
+	}
+
+	if envCryptoKey := os.Getenv("CRYPTO_KEY"); envCryptoKey != "" {
+		*cryptoKey = envCryptoKey
+	}
+
+	if envHistorySize := os.Getenv("HISTORY_SIZE"); envHistorySize != "" {
+		if hs, err := strconv.Atoi(envHistorySize); err == nil {
+			*historySize = hs
+		}
+	}
+
+	if envHistoryRetention := os.Getenv("HISTORY_RETENTION"); envHistoryRetention != "" {
+		if hr, err := time.ParseDuration(envHistoryRetention + "s"); err == nil {
+			*historyRetention = int(hr.Seconds())
+		}
+	}
+
+	if envHistogramBuckets := os.Getenv("HISTOGRAM_BUCKETS"); envHistogramBuckets != "" {
+		*histogramBuckets = envHistogramBuckets
+	}
+
+	buckets, err := parseBuckets(*histogramBuckets)
+	if err != nil {
+		log.Fatalf("некорректные границы корзин гистограмм %q: %v", *histogramBuckets, err)
+	}
+
+	if envQueryMaxPoints := os.Getenv("QUERY_MAX_POINTS"); envQueryMaxPoints != "" {
+		if qmp, err := strconv.Atoi(envQueryMaxPoints); err == nil {
+			*queryMaxPoints = qmp
+		}
+	}
+
+	if *queryMaxPoints < 1 {
+		log.Fatalf("некорректное ограничение числа точек запроса %d: ожидается положительное число", *queryMaxPoints)
+	}
+
+	if envMaxSampleAge := os.Getenv("MAX_SAMPLE_AGE"); envMaxSampleAge != "" {
+		if age, err := time.ParseDuration(envMaxSampleAge + "s"); err == nil {
+			*maxSampleAge = int(age.Seconds())
+		}
+	}
+
+	if envMaxSampleFuture := os.Getenv("MAX_SAMPLE_FUTURE"); envMaxSampleFuture != "" {
+		if future, err := time.ParseDuration(envMaxSampleFuture + "s"); err == nil {
+			*maxSampleFuture = int(future.Seconds())
+		}
+	}
+
+	if envSampleTimeMode := os.Getenv("SAMPLE_TIME_MODE"); envSampleTimeMode != "" {
+		*sampleTimeMode = envSampleTimeMode
+	}
+
+	if envBatchTTL := os.Getenv("BATCH_TTL"); envBatchTTL != "" {
+		if ttl, err := time.ParseDuration(envBatchTTL + "s"); err == nil {
+			*batchTTL = int(ttl.Seconds())
+		}
+	}
+
+	if envWebhookURLs := os.Getenv("WEBHOOK_URLS"); envWebhookURLs != "" {
+		*webhookURLs = envWebhookURLs
+	}
+
+	if envGRPCAddress := os.Getenv("GRPC_ADDRESS"); envGRPCAddress != "" {
+		*grpcAddress = envGRPCAddress
+	}
+
 	return Config{
 		StoreInterval:      time.Duration(*storeInterval) * time.Second,
 		FileStoragePath:    *fileStoragePath,
 		Restore:            *restore,
 		Address:            *address,
 		DBConnectionString: *DBConnectionString,
+		DBBackend:          *dbBackend,
+		RulesFile:          *rulesFile,
+		AlertInterval:      time.Duration(*alertInterval) * time.Second,
+		WebhookURLs:        splitList(*webhookURLs),
+		HistorySize:        *historySize,
+		HistoryRetention:   time.Duration(*historyRetention) * time.Second,
+		HistogramBuckets:   buckets,
+		QueryMaxPoints:     *queryMaxPoints,
+		MaxSampleAge:       time.Duration(*maxSampleAge) * time.Second,
+		MaxSampleFuture:    time.Duration(*maxSampleFuture) * time.Second,
+		SampleTimeMode:     *sampleTimeMode,
+		BatchTTL:           time.Duration(*batchTTL) * time.Second,
+		Key:                *key,
+		CryptoKey:          *cryptoKey,
+		GRPCAddress:        *grpcAddress,
+	}
+}
+
+func splitList(value string) []string {
+	var result []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+func parseBuckets(value string) ([]float64, error) {
+	items := splitList(value)
+	buckets := make([]float64, 0, len(items))
+	for _, item := range items {
+		bound, err := strconv.ParseFloat(item, 64)
+		if err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bound)
 	}
+	return buckets, nil
 }
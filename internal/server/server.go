@@ -1,17 +1,34 @@
 package server
 
 import (
+	"context"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/GarikMirzoyan/metricalert/internal/alerts"
 	"github.com/GarikMirzoyan/metricalert/internal/database"
+	"github.com/GarikMirzoyan/metricalert/internal/encryption"
+	"github.com/GarikMirzoyan/metricalert/internal/grpcserver"
 	"github.com/GarikMirzoyan/metricalert/internal/handlers"
 	"github.com/GarikMirzoyan/metricalert/internal/metrics"
+	"github.com/GarikMirzoyan/metricalert/internal/middleware/cryptomiddleware"
 	"github.com/GarikMirzoyan/metricalert/internal/middleware/gzipmiddleware"
+	"github.com/GarikMirzoyan/metricalert/internal/middleware/hashmiddleware"
 	"github.com/GarikMirzoyan/metricalert/internal/middleware/loggermiddleware"
+	"github.com/GarikMirzoyan/metricalert/internal/notifier"
 	"github.com/GarikMirzoyan/metricalert/internal/repositories"
 	"github.com/GarikMirzoyan/metricalert/internal/server/config"
 	"github.com/go-chi/chi"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 type Server struct {
@@ -29,46 +46,102 @@ func NewServer(storage metrics.MetricStorage, logger *zap.Logger, config config.
 	return server
 }
 
+// Время на завершение обработки текущих запросов при остановке сервера
+const shutdownTimeout = 10 * time.Second
+
+// Период удаления истории старше HISTORY_RETENTION
+const historyPruneInterval = time.Minute
+
 func Run() {
 	r := chi.NewRouter()
 	logger, _ := zap.NewProduction()
 	defer logger.Sync()
 
-	SetMiddlewares(r, logger)
-
 	config := config.InitConfig()
 
+	// Контекст отменяется по SIGINT, SIGTERM или SIGQUIT
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGQUIT)
+	defer stop()
+
+	// Фоновые задачи отменяются отдельно, только после остановки серверов,
+	// чтобы обработчики запросов не работали с остановленными алертами и рассылкой
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	dispatchCtx, stopDispatch := context.WithCancel(context.Background())
+	defer stopDispatch()
+
+	var privateKey *rsa.PrivateKey
+	if config.CryptoKey != "" {
+		key, err := encryption.LoadPrivateKey(config.CryptoKey)
+		if err != nil {
+			logger.Fatal("Error loading private key", zap.Error(err))
+		}
+		privateKey = key
+	}
+
+	parser, err := metrics.NewParser(config.HistogramBuckets)
+	if err != nil {
+		logger.Fatal("Invalid histogram buckets", zap.Error(err))
+	}
+
+	storageOptions := metrics.Options{
+		HistorySize:      config.HistorySize,
+		HistoryRetention: config.HistoryRetention,
+		Timestamps: metrics.TimestampPolicy{
+			Mode:      metrics.TimestampMode(config.SampleTimeMode),
+			MaxAge:    config.MaxSampleAge,
+			MaxFuture: config.MaxSampleFuture,
+		},
+		BatchTTL: config.BatchTTL,
+	}
+	if err := storageOptions.Timestamps.Validate(); err != nil {
+		logger.Fatal("Invalid sample timestamp limits", zap.Error(err))
+	}
+
+	SetMiddlewares(r, logger, config, privateKey)
+
 	var storage metrics.MetricStorage
+	var memStorage *metrics.MemStorage
+	var dbConn database.Database
+	var background sync.WaitGroup
 
 	if config.DBConnectionString == "" {
 		// In-memory storage
-		memStorage := metrics.NewMemStorage()
+		memStorage = metrics.NewMemStorageWithOptions(storageOptions)
 
 		if err := memStorage.LoadMetricsFromFile(config); err != nil {
 			logger.Error("Error loading metrics", zap.Error(err))
 		}
 
-		go memStorage.StartMetricSaving(config, logger)
-
-		if err := memStorage.SaveMetricsToFile(config); err != nil {
-			logger.Error("Error saving metrics on shutdown", zap.Error(err))
+		if err := memStorage.EnableWAL(config); err != nil {
+			// Без журнала синхронная запись невозможна; в периодическом режиме
+			// сервер продолжает работу, как и при ошибках сохранения снимка
+			if config.StoreInterval == 0 {
+				logger.Fatal("Error opening metrics WAL", zap.Error(err))
+			}
+			logger.Error("Error opening metrics WAL", zap.Error(err))
 		}
 
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			memStorage.StartMetricSaving(config, logger, backgroundCtx)
+		}()
+
 		storage = memStorage
 	} else {
 		// Подключение к базе
-		dbConn, err := database.NewDBConnection(config.DBConnectionString)
+		conn, repo, err := openDatabase(config)
 		if err != nil {
 			logger.Fatal("Error connecting to database", zap.Error(err))
 		}
-		defer dbConn.Close()
+		dbConn = conn
 
 		if err := dbConn.RunMigrations(); err != nil {
 			logger.Fatal("Migration error", zap.Error(err))
 		}
 
-		repo := repositories.NewMetricRepository(dbConn)
-		dbStorage := metrics.NewDBStorage(repo)
+		dbStorage := metrics.NewDBStorageWithOptions(repo, storageOptions)
 		storage = dbStorage
 
 		dbBaseHandlers := handlers.NewDBBaseHandlers(dbConn)
@@ -77,29 +150,200 @@ func Run() {
 
 	server := NewServer(storage, logger, config)
 
-	handlers := handlers.NewHandlers(storage)
+	if config.HistoryRetention > 0 {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			server.pruneHistory(backgroundCtx)
+		}()
+	}
+
+	metricHandlers := handlers.NewHandlersWithOptions(storage, handlers.Options{
+		Parser:         parser,
+		QueryMaxPoints: config.QueryMaxPoints,
+		Logger:         logger,
+	})
+
+	SetMetricRoutes(r, metricHandlers)
+
+	var rules []alerts.Rule
+	if config.RulesFile != "" {
+		loaded, err := alerts.LoadRules(config.RulesFile)
+		if err != nil {
+			logger.Fatal("Error loading alerting rules", zap.Error(err))
+		}
+		rules = loaded
+	}
+
+	var alertNotifier alerts.Notifier
+	var dispatcher *notifier.Dispatcher
+	if len(config.WebhookURLs) > 0 {
+		receivers := make([]notifier.Receiver, 0, len(config.WebhookURLs))
+		for _, url := range config.WebhookURLs {
+			receivers = append(receivers, notifier.Receiver{Name: url, URL: url})
+		}
+		dispatcher = notifier.NewDispatcher(receivers, logger)
+		dispatcher.Start(dispatchCtx)
+		alertNotifier = dispatcher
+	}
 
-	SetMetricRoutes(r, handlers)
+	alertEngine := alerts.NewEngine(storage, rules, config.AlertInterval, alertNotifier, logger)
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		alertEngine.Start(backgroundCtx)
+	}()
 
-	// // Загружаем метрики, если указано
-	// if err := storage.LoadMetricsFromFile(server.config); err != nil {
-	// 	logger.Error("Error loading metrics", zap.Error(err))
-	// }
+	alertsHandlers := handlers.NewAlertsHandlers(alertEngine)
+	SetAlertRoutes(r, alertsHandlers)
 
-	// // Запускаем сохранение метрик
-	// go storage.StartMetricSaving(server.config, server.logger)
+	serveErrors := make(chan error, 2)
 
-	server.logger.Info("Starting server", zap.String("address", config.Address))
-	if err := http.ListenAndServe(config.Address, r); err != nil {
+	var grpcServer *grpc.Server
+	if config.GRPCAddress != "" {
+		listener, err := net.Listen("tcp", config.GRPCAddress)
+		if err != nil {
+			logger.Fatal("Error listening gRPC address", zap.Error(err))
+		}
+		grpcServer = grpcserver.NewServer(storage, parser, config.Key, privateKey, logger)
+		go func() {
+			server.logger.Info("Starting gRPC server", zap.String("address", config.GRPCAddress))
+			if err := grpcServer.Serve(listener); err != nil {
+				serveErrors <- fmt.Errorf("gRPC server: %w", err)
+			}
+		}()
+	}
+
+	httpServer := &http.Server{Addr: config.Address, Handler: r}
+	go func() {
+		server.logger.Info("Starting server", zap.String("address", config.Address))
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serveErrors <- fmt.Errorf("HTTP server: %w", err)
+		}
+	}()
+
+	select {
+	case <-ctx.Done():
+		server.logger.Info("Received shutdown signal")
+	case err := <-serveErrors:
 		server.logger.Error("Error starting server", zap.Error(err))
 	}
+	stop()
+
+	// Сначала дожидаемся текущих запросов, затем останавливаем фоновые задачи
+	// (и в случае ошибки запуска) и досылаем накопленные события алертов
+	server.shutdown(httpServer, grpcServer)
+
+	stopBackground()
+	background.Wait()
+	if dispatcher != nil {
+		server.drainDispatcher(dispatcher, stopDispatch)
+	}
+
+	// Финальное сохранение: в файл попадают все обновления, принятые до остановки
+	if memStorage != nil {
+		if err := memStorage.SaveMetricsToFile(config); err != nil {
+			server.logger.Error("Error saving metrics on shutdown", zap.Error(err))
+		}
+	}
+	if dbConn != nil {
+		dbConn.Close()
+	}
+
+	server.logger.Info("Server stopped")
+}
+
+// Периодическое удаление устаревшей истории; завершается при отмене контекста
+func (s *Server) pruneHistory(ctx context.Context) {
+	ticker := time.NewTicker(historyPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.storage.PruneHistory(ctx); err != nil {
+				s.logger.Error("Error pruning metric history", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Подключение к базе и репозиторий выбранной реализации (DB_BACKEND)
+func openDatabase(cfg config.Config) (database.Database, repositories.Repository, error) {
+	if cfg.DBBackend == config.DBBackendPgxPool {
+		pool, err := database.NewPool(cfg.DBConnectionString)
+		if err != nil {
+			return nil, nil, err
+		}
+		return pool, repositories.NewPgxMetricRepository(pool.Pool), nil
+	}
+
+	conn, err := database.NewDBConnection(cfg.DBConnectionString)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, repositories.NewMetricRepository(conn), nil
+}
+
+// Остановка приёма новых запросов и ожидание завершения текущих
+func (s *Server) shutdown(httpServer *http.Server, grpcServer *grpc.Server) {
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		s.logger.Error("Error shutting down HTTP server", zap.Error(err))
+	}
+
+	if grpcServer == nil {
+		return
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-shutdownCtx.Done():
+		s.logger.Warn("gRPC server did not stop in time, closing connections")
+		grpcServer.Stop()
+	}
 }
 
-func SetMiddlewares(r *chi.Mux, logger *zap.Logger) {
-	// Добавляем middleware для логирования и сжатия
+// Досылка событий из очередей диспетчера; по истечении shutdownTimeout оставшиеся отбрасываются
+func (s *Server) drainDispatcher(dispatcher *notifier.Dispatcher, cancel context.CancelFunc) {
+	dispatcher.Close()
+
+	drained := make(chan struct{})
+	go func() {
+		dispatcher.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-time.After(shutdownTimeout):
+		s.logger.Warn("Alert notifications were not delivered in time")
+		cancel()
+		<-drained
+	}
+}
+
+func SetMiddlewares(r *chi.Mux, logger *zap.Logger, config config.Config, privateKey *rsa.PrivateKey) {
+	// Добавляем middleware для логирования, подписи, шифрования и сжатия
 	r.Use(func(next http.Handler) http.Handler {
 		return loggermiddleware.Logger(next, logger)
 	})
+	r.Use(func(next http.Handler) http.Handler {
+		return hashmiddleware.Hash(next, config.Key) // Проверка подписи до разжатия
+	})
+	r.Use(func(next http.Handler) http.Handler {
+		return cryptomiddleware.Decrypt(next, privateKey) // Расшифровка до разжатия
+	})
 	r.Use(gzipmiddleware.GzipDecompression) // Разжатие входящих данных
 	r.Use(gzipmiddleware.GzipCompression)   // Сжатие исходящих данных
 }
@@ -110,9 +354,15 @@ func SetMetricRoutes(r *chi.Mux, handlers *handlers.Handler) {
 	r.Post("/updates/", handlers.BatchMetricsUpdateHandler)
 	r.Get("/value/{type}/{name}", handlers.GetValueHandler)
 	r.Post("/value/", handlers.GetValueHandlerJSON)
+	r.Get("/query", handlers.QueryHandler)
+	r.Get("/metrics", handlers.PrometheusHandler)
 	r.Get("/", handlers.RootHandler)
 }
 
+func SetAlertRoutes(r *chi.Mux, handlers *handlers.AlertsHandler) {
+	r.Get("/alerts", handlers.ListAlertsHandler)
+}
+
 func SetDBRoutes(r *chi.Mux, handlers *handlers.DBBaseHandler) {
 	r.Get("/ping", handlers.PingDBHandler)
 }
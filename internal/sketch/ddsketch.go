@@ -0,0 +1,214 @@
+package sketch
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+var (
+	ErrEmpty           = errors.New("sketch is empty")
+	ErrInvalidQuantile = errors.New("quantile must be between 0 and 1")
+	ErrIncompatible    = errors.New("sketches have different parameters")
+	ErrInvalidState    = errors.New("invalid sketch state")
+	ErrInvalidValue    = errors.New("value must be finite")
+)
+
+// Относительная погрешность квантилей по умолчанию (1%)
+const DefaultRelativeAccuracy = 0.01
+
+// Максимальное число корзин для положительных и для отрицательных значений.
+// При переполнении сливаются корзины с наименьшими по модулю значениями,
+// поэтому точность верхних квантилей сохраняется.
+const maxBins = 2048
+
+// Значения меньше по модулю считаются нулём
+const minIndexable = 1e-9
+
+// DDSketch: наблюдения раскладываются по корзинам с границами gamma^(i-1) < |v| <= gamma^i,
+// где gamma = (1+alpha)/(1-alpha). Квантиль оценивается с относительной погрешностью alpha.
+// Скетчи с одинаковым alpha складываются без потери точности.
+type DDSketch struct {
+	alpha    float64
+	logGamma float64
+	positive map[int32]uint64
+	negative map[int32]uint64
+	zero     uint64
+	count    uint64
+	sum      float64
+	min      float64
+	max      float64
+}
+
+func New(alpha float64) (*DDSketch, error) {
+	if !(alpha > 0 && alpha < 1) {
+		return nil, fmt.Errorf("%w: relative accuracy %v", ErrInvalidState, alpha)
+	}
+	return &DDSketch{
+		alpha:    alpha,
+		logGamma: math.Log((1 + alpha) / (1 - alpha)),
+		positive: make(map[int32]uint64),
+		negative: make(map[int32]uint64),
+	}, nil
+}
+
+// Скетч с погрешностью по умолчанию
+func NewDefault() *DDSketch {
+	s, _ := New(DefaultRelativeAccuracy)
+	return s
+}
+
+func (s *DDSketch) RelativeAccuracy() float64 { return s.alpha }
+func (s *DDSketch) Count() uint64             { return s.count }
+func (s *DDSketch) Sum() float64              { return s.sum }
+
+func (s *DDSketch) Add(value float64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("%w: %v", ErrInvalidValue, value)
+	}
+
+	switch {
+	case value > minIndexable:
+		s.positive[s.index(value)]++
+		collapse(s.positive)
+	case value < -minIndexable:
+		s.negative[s.index(-value)]++
+		collapse(s.negative)
+	default:
+		s.zero++
+	}
+
+	if s.count == 0 || value < s.min {
+		s.min = value
+	}
+	if s.count == 0 || value > s.max {
+		s.max = value
+	}
+	s.count++
+	s.sum += value
+	return nil
+}
+
+// Сложение со скетчем с той же погрешностью
+func (s *DDSketch) Merge(other *DDSketch) error {
+	if s.alpha != other.alpha {
+		return fmt.Errorf("%w: %v and %v", ErrIncompatible, s.alpha, other.alpha)
+	}
+	if other.count == 0 {
+		return nil
+	}
+
+	for index, count := range other.positive {
+		s.positive[index] += count
+	}
+	for index, count := range other.negative {
+		s.negative[index] += count
+	}
+	collapse(s.positive)
+	collapse(s.negative)
+	s.zero += other.zero
+
+	if s.count == 0 || other.min < s.min {
+		s.min = other.min
+	}
+	if s.count == 0 || other.max > s.max {
+		s.max = other.max
+	}
+	s.count += other.count
+	s.sum += other.sum
+	return nil
+}
+
+// Оценка квантиля q (0 — минимум, 1 — максимум)
+func (s *DDSketch) Quantile(q float64) (float64, error) {
+	if !(q >= 0 && q <= 1) {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidQuantile, q)
+	}
+	if s.count == 0 {
+		return 0, ErrEmpty
+	}
+	if q == 0 {
+		return s.min, nil
+	}
+	if q == 1 {
+		return s.max, nil
+	}
+
+	rank := q * float64(s.count-1)
+	var seen uint64
+
+	// Отрицательные значения по возрастанию: от больших по модулю к меньшим
+	negative := sortedIndexes(s.negative)
+	for i := len(negative) - 1; i >= 0; i-- {
+		seen += s.negative[negative[i]]
+		if float64(seen) > rank {
+			return s.clamp(-s.value(negative[i])), nil
+		}
+	}
+
+	seen += s.zero
+	if float64(seen) > rank {
+		return s.clamp(0), nil
+	}
+
+	for _, index := range sortedIndexes(s.positive) {
+		seen += s.positive[index]
+		if float64(seen) > rank {
+			return s.clamp(s.value(index)), nil
+		}
+	}
+	return s.max, nil
+}
+
+func (s *DDSketch) Clone() *DDSketch {
+	clone := *s
+	clone.positive = make(map[int32]uint64, len(s.positive))
+	for index, count := range s.positive {
+		clone.positive[index] = count
+	}
+	clone.negative = make(map[int32]uint64, len(s.negative))
+	for index, count := range s.negative {
+		clone.negative[index] = count
+	}
+	return &clone
+}
+
+// Номер корзины для положительного значения
+func (s *DDSketch) index(value float64) int32 {
+	return int32(math.Ceil(math.Log(value) / s.logGamma))
+}
+
+// Значение, представляющее корзину: погрешность не больше alpha для любого значения в ней
+func (s *DDSketch) value(index int32) float64 {
+	return 2 * math.Exp(float64(index)*s.logGamma) / (1 + math.Exp(s.logGamma))
+}
+
+// Оценка не выходит за наблюдавшиеся минимум и максимум
+func (s *DDSketch) clamp(value float64) float64 {
+	return math.Max(s.min, math.Min(s.max, value))
+}
+
+// Слияние корзин с наименьшими номерами, если их больше maxBins
+func collapse(bins map[int32]uint64) {
+	if len(bins) <= maxBins {
+		return
+	}
+
+	indexes := sortedIndexes(bins)
+	excess := len(indexes) - maxBins
+	target := indexes[excess]
+	for _, index := range indexes[:excess] {
+		bins[target] += bins[index]
+		delete(bins, index)
+	}
+}
+
+func sortedIndexes(bins map[int32]uint64) []int32 {
+	indexes := make([]int32, 0, len(bins))
+	for index := range bins {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+	return indexes
+}
@@ -0,0 +1,118 @@
+package sketch
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Точный квантиль с тем же рангом, что и в DDSketch
+func exactQuantile(sorted []float64, q float64) float64 {
+	return sorted[int(q*float64(len(sorted)-1))]
+}
+
+func TestDDSketch_RelativeAccuracy(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	values := make([]float64, 0, 10000)
+	s := NewDefault()
+	for range 10000 {
+		v := rng.ExpFloat64() * 0.2
+		if rng.IntN(10) == 0 {
+			v = -v
+		}
+		values = append(values, v)
+		require.NoError(t, s.Add(v))
+	}
+	sort.Float64s(values)
+
+	for _, q := range []float64{0.01, 0.25, 0.5, 0.9, 0.95, 0.99} {
+		got, err := s.Quantile(q)
+		require.NoError(t, err)
+		want := exactQuantile(values, q)
+		assert.InEpsilon(t, want, got, DefaultRelativeAccuracy+1e-9, "q=%v", q)
+	}
+
+	minimum, err := s.Quantile(0)
+	require.NoError(t, err)
+	assert.Equal(t, values[0], minimum)
+	maximum, err := s.Quantile(1)
+	require.NoError(t, err)
+	assert.Equal(t, values[len(values)-1], maximum)
+}
+
+func TestDDSketch_MergeEqualsSingleSketch(t *testing.T) {
+	whole, left, right := NewDefault(), NewDefault(), NewDefault()
+	for i := range 1000 {
+		v := float64(i%97) + 0.5
+		require.NoError(t, whole.Add(v))
+		if i%2 == 0 {
+			require.NoError(t, left.Add(v))
+		} else {
+			require.NoError(t, right.Add(v))
+		}
+	}
+
+	require.NoError(t, left.Merge(right))
+	assert.Equal(t, whole.State(), left.State())
+
+	other, err := New(0.05)
+	require.NoError(t, err)
+	assert.ErrorIs(t, left.Merge(other), ErrIncompatible)
+}
+
+func TestDDSketch_Errors(t *testing.T) {
+	s := NewDefault()
+
+	_, err := s.Quantile(0.5)
+	assert.ErrorIs(t, err, ErrEmpty)
+
+	assert.ErrorIs(t, s.Add(math.NaN()), ErrInvalidValue)
+	assert.ErrorIs(t, s.Add(math.Inf(1)), ErrInvalidValue)
+
+	require.NoError(t, s.Add(1))
+	for _, q := range []float64{-0.1, 1.5, math.NaN()} {
+		_, err := s.Quantile(q)
+		assert.ErrorIs(t, err, ErrInvalidQuantile)
+	}
+}
+
+func TestDDSketch_CollapseKeepsUpperQuantiles(t *testing.T) {
+	s := NewDefault()
+	// Диапазон шире, чем помещается в maxBins корзин
+	for v := 1e-6; v < 1e12; v *= 1.01 {
+		require.NoError(t, s.Add(v))
+	}
+	assert.LessOrEqual(t, len(s.positive), maxBins)
+
+	p99, err := s.Quantile(0.99)
+	require.NoError(t, err)
+	assert.Greater(t, p99, 1e11)
+}
+
+func TestState_RoundTrip(t *testing.T) {
+	s := NewDefault()
+	for _, v := range []float64{-3, 0, 0.5, 2, 2, 100} {
+		require.NoError(t, s.Add(v))
+	}
+
+	data, err := json.Marshal(s.State())
+	require.NoError(t, err)
+
+	var state State
+	require.NoError(t, json.Unmarshal(data, &state))
+	restored, err := FromState(&state)
+	require.NoError(t, err)
+	assert.Equal(t, s.State(), restored.State())
+
+	state.Count++
+	_, err = FromState(&state)
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	_, err = FromState(&State{Alpha: 1})
+	assert.ErrorIs(t, err, ErrInvalidState)
+}
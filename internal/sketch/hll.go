@@ -0,0 +1,116 @@
+package sketch
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+// Точность HyperLogLog по умолчанию: 2^12 регистров, стандартная погрешность около 1.6%
+const DefaultHLLPrecision = 12
+
+const (
+	minHLLPrecision = 4
+	maxHLLPrecision = 16
+)
+
+// HyperLogLog: оценка числа различных элементов по 2^precision регистрам.
+// Хеш детерминирован, поэтому состояния с разных агентов складываются
+// (поэлементный максимум регистров) и дают оценку объединения множеств.
+type HyperLogLog struct {
+	precision uint8
+	registers []uint8
+}
+
+func NewHLL(precision uint8) (*HyperLogLog, error) {
+	if precision < minHLLPrecision || precision > maxHLLPrecision {
+		return nil, fmt.Errorf("%w: precision %d", ErrInvalidState, precision)
+	}
+	return &HyperLogLog{
+		precision: precision,
+		registers: make([]uint8, 1<<precision),
+	}, nil
+}
+
+// HyperLogLog с точностью по умолчанию
+func NewDefaultHLL() *HyperLogLog {
+	h, _ := NewHLL(DefaultHLLPrecision)
+	return h
+}
+
+func (h *HyperLogLog) Precision() uint8 { return h.precision }
+
+func (h *HyperLogLog) Add(member string) {
+	x := hashMember(member)
+	index := x >> (64 - h.precision)
+	// Единица в младших битах ограничивает длину серии нулей
+	rank := uint8(bits.LeadingZeros64(x<<h.precision|1<<(h.precision-1))) + 1
+	if rank > h.registers[index] {
+		h.registers[index] = rank
+	}
+}
+
+// Объединение с HyperLogLog той же точности
+func (h *HyperLogLog) Merge(other *HyperLogLog) error {
+	if h.precision != other.precision {
+		return fmt.Errorf("%w: precision %d and %d", ErrIncompatible, h.precision, other.precision)
+	}
+	for i, rank := range other.registers {
+		if rank > h.registers[i] {
+			h.registers[i] = rank
+		}
+	}
+	return nil
+}
+
+// Оценка числа различных элементов; для малых множеств — линейный подсчёт
+func (h *HyperLogLog) Estimate() uint64 {
+	m := float64(len(h.registers))
+
+	var sum float64
+	var zeros int
+	for _, rank := range h.registers {
+		sum += math.Ldexp(1, -int(rank))
+		if rank == 0 {
+			zeros++
+		}
+	}
+
+	alpha := 0.7213 / (1 + 1.079/m)
+	estimate := alpha * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return uint64(math.Round(estimate))
+}
+
+// Пустой ли HyperLogLog: ни один элемент не добавлен
+func (h *HyperLogLog) Empty() bool {
+	for _, rank := range h.registers {
+		if rank != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (h *HyperLogLog) Clone() *HyperLogLog {
+	clone := *h
+	clone.registers = append([]uint8(nil), h.registers...)
+	return &clone
+}
+
+// FNV-1a с перемешиванием из MurmurHash3: у FNV слабо меняются старшие биты,
+// а по ним выбирается регистр
+func hashMember(member string) uint64 {
+	f := fnv.New64a()
+	_, _ = f.Write([]byte(member))
+	x := f.Sum64()
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
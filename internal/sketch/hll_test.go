@@ -0,0 +1,76 @@
+package sketch
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHyperLogLog_Estimate(t *testing.T) {
+	h := NewDefaultHLL()
+	assert.True(t, h.Empty())
+	assert.Equal(t, uint64(0), h.Estimate())
+
+	for _, n := range []int{10, 1000, 100000} {
+		h := NewDefaultHLL()
+		for i := range n {
+			// Повторы не увеличивают оценку
+			h.Add("user-" + strconv.Itoa(i))
+			h.Add("user-" + strconv.Itoa(i))
+		}
+		assert.InEpsilon(t, n, h.Estimate(), 0.05, "n=%d", n)
+	}
+}
+
+func TestHyperLogLog_MergeEqualsUnion(t *testing.T) {
+	union, left, right := NewDefaultHLL(), NewDefaultHLL(), NewDefaultHLL()
+	for i := range 5000 {
+		member := "user-" + strconv.Itoa(i)
+		union.Add(member)
+		// Множества пересекаются на 1000 элементов
+		if i < 3000 {
+			left.Add(member)
+		}
+		if i >= 2000 {
+			right.Add(member)
+		}
+	}
+
+	require.NoError(t, left.Merge(right))
+	assert.Equal(t, union.State(), left.State())
+	assert.InEpsilon(t, 5000, left.Estimate(), 0.05)
+
+	other, err := NewHLL(10)
+	require.NoError(t, err)
+	assert.ErrorIs(t, left.Merge(other), ErrIncompatible)
+}
+
+func TestHLLState_RoundTrip(t *testing.T) {
+	h := NewDefaultHLL()
+	for _, member := range []string{"a", "b", "c"} {
+		h.Add(member)
+	}
+
+	data, err := json.Marshal(h.State())
+	require.NoError(t, err)
+
+	var state HLLState
+	require.NoError(t, json.Unmarshal(data, &state))
+	restored, err := HLLFromState(&state)
+	require.NoError(t, err)
+	assert.Equal(t, h.State(), restored.State())
+	assert.Equal(t, uint64(3), restored.Estimate())
+
+	_, err = HLLFromState(&HLLState{Precision: DefaultHLLPrecision, Registers: []byte{1}})
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	state.Registers[0] = 60
+	_, err = HLLFromState(&state)
+	assert.ErrorIs(t, err, ErrInvalidState)
+
+	_, err = HLLFromState(&HLLState{Precision: 30})
+	assert.ErrorIs(t, err, ErrInvalidState)
+}
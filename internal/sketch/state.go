@@ -0,0 +1,110 @@
+package sketch
+
+import (
+	"fmt"
+	"math"
+)
+
+// Сериализуемое состояние скетча: для DTO, файла метрик, журнала и базы.
+// Ключи Positive и Negative — номера корзин.
+type State struct {
+	Alpha    float64          `json:"alpha"`
+	Zero     uint64           `json:"zero,omitempty"`
+	Positive map[int32]uint64 `json:"positive,omitempty"`
+	Negative map[int32]uint64 `json:"negative,omitempty"`
+	Count    uint64           `json:"count"`
+	Sum      float64          `json:"sum"`
+	Min      float64          `json:"min"`
+	Max      float64          `json:"max"`
+}
+
+func (s *DDSketch) State() *State {
+	clone := s.Clone()
+	state := &State{
+		Alpha: s.alpha,
+		Zero:  s.zero,
+		Count: s.count,
+		Sum:   s.sum,
+		Min:   s.min,
+		Max:   s.max,
+	}
+	if len(clone.positive) > 0 {
+		state.Positive = clone.positive
+	}
+	if len(clone.negative) > 0 {
+		state.Negative = clone.negative
+	}
+	return state
+}
+
+// Восстановление скетча с проверкой согласованности состояния
+func FromState(state *State) (*DDSketch, error) {
+	s, err := New(state.Alpha)
+	if err != nil {
+		return nil, err
+	}
+	if len(state.Positive) > maxBins || len(state.Negative) > maxBins {
+		return nil, fmt.Errorf("%w: more than %d bins", ErrInvalidState, maxBins)
+	}
+
+	total := state.Zero
+	for index, count := range state.Positive {
+		s.positive[index] = count
+		total += count
+	}
+	for index, count := range state.Negative {
+		s.negative[index] = count
+		total += count
+	}
+	if total != state.Count {
+		return nil, fmt.Errorf("%w: count %d does not match bins %d", ErrInvalidState, state.Count, total)
+	}
+	for _, v := range []float64{state.Sum, state.Min, state.Max} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidState, v)
+		}
+	}
+	if state.Count > 0 && state.Min > state.Max {
+		return nil, fmt.Errorf("%w: min %v is greater than max %v", ErrInvalidState, state.Min, state.Max)
+	}
+
+	s.zero = state.Zero
+	s.count = state.Count
+	s.sum = state.Sum
+	s.min = state.Min
+	s.max = state.Max
+	return s, nil
+}
+
+// Сериализуемое состояние HyperLogLog; Registers — значения регистров подряд (в JSON — base64)
+type HLLState struct {
+	Precision uint8  `json:"precision"`
+	Registers []byte `json:"registers"`
+}
+
+func (h *HyperLogLog) State() *HLLState {
+	return &HLLState{
+		Precision: h.precision,
+		Registers: append([]byte(nil), h.registers...),
+	}
+}
+
+// Восстановление HyperLogLog с проверкой числа и значений регистров
+func HLLFromState(state *HLLState) (*HyperLogLog, error) {
+	h, err := NewHLL(state.Precision)
+	if err != nil {
+		return nil, err
+	}
+	if len(state.Registers) != len(h.registers) {
+		return nil, fmt.Errorf("%w: expected %d registers, got %d", ErrInvalidState, len(h.registers), len(state.Registers))
+	}
+
+	maxRank := 64 - state.Precision + 1
+	for _, rank := range state.Registers {
+		if rank > maxRank {
+			return nil, fmt.Errorf("%w: register value %d", ErrInvalidState, rank)
+		}
+	}
+	copy(h.registers, state.Registers)
+	return h, nil
+}
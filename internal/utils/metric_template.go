@@ -19,6 +19,18 @@ func InitTemplate() *template.Template {
 			{{range $key, $metric := .Counters}}
 				<li>{{$key}}: {{$metric.Value}}</li>
 			{{end}}
+			{{range $key, $metric := .Histograms}}
+				<li>{{$key}}: count={{$metric.Count}} sum={{$metric.Sum}}</li>
+			{{end}}
+			{{range $key, $metric := .Summaries}}
+				<li>{{$key}}: count={{$metric.Sketch.Count}} sum={{$metric.Sketch.Sum}}</li>
+			{{end}}
+			{{range $key, $metric := .Sets}}
+				<li>{{$key}}: ~{{$metric.HLL.Estimate}} distinct</li>
+			{{end}}
+			{{range $key, $metric := .Infos}}
+				<li>{{$key}}: {{$metric.Value}}</li>
+			{{end}}
 		</ul>
 	</body>
 	</html>
@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}'::jsonb;
+ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_name_key;
+ALTER TABLE metrics ADD CONSTRAINT metrics_name_labels_key UNIQUE (name, labels);
+
+ALTER TABLE metrics_history ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}'::jsonb;
+DROP INDEX IF EXISTS metrics_history_series_idx;
+CREATE INDEX IF NOT EXISTS metrics_history_series_idx ON metrics_history (type, name, labels, recorded_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS metrics_history_series_idx;
+ALTER TABLE metrics_history DROP COLUMN IF EXISTS labels;
+CREATE INDEX IF NOT EXISTS metrics_history_series_idx ON metrics_history (type, name, recorded_at);
+
+ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_name_labels_key;
+ALTER TABLE metrics DROP COLUMN IF EXISTS labels;
+ALTER TABLE metrics ADD CONSTRAINT metrics_name_key UNIQUE (name);
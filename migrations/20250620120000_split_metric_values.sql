@@ -0,0 +1,8 @@
+-- +goose Up
+-- Counter хранится точно в BIGINT, gauge остаётся в DOUBLE PRECISION
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS delta BIGINT;
+UPDATE metrics SET delta = ROUND(value)::bigint, value = NULL WHERE type = 'counter';
+
+-- +goose Down
+UPDATE metrics SET value = delta::double precision WHERE type = 'counter';
+ALTER TABLE metrics DROP COLUMN IF EXISTS delta;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- Серия определяется типом, именем и метками: gauge и counter могут называться одинаково
+ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_name_labels_key;
+ALTER TABLE metrics ADD CONSTRAINT metrics_type_name_labels_key UNIQUE (type, name, labels);
+
+-- +goose Down
+-- В старой схеме gauge и counter с одинаковым именем не помещаются: counter удаляется
+DELETE FROM metrics c USING metrics g
+WHERE c.type = 'counter' AND g.type = 'gauge' AND c.name = g.name AND c.labels = g.labels;
+ALTER TABLE metrics DROP CONSTRAINT IF EXISTS metrics_type_name_labels_key;
+ALTER TABLE metrics ADD CONSTRAINT metrics_name_labels_key UNIQUE (name, labels);
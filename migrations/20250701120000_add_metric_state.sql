@@ -0,0 +1,7 @@
+-- +goose Up
+-- Состояние метрик составных типов (histogram) хранится в JSONB; value и delta у них пустые
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS state JSONB;
+
+-- +goose Down
+DELETE FROM metrics WHERE type = 'histogram';
+ALTER TABLE metrics DROP COLUMN IF EXISTS state;
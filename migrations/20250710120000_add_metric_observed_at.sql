@@ -0,0 +1,7 @@
+-- +goose Up
+-- Время наблюдения gauge и последнего приращения counter; gauge не заменяется более старым наблюдением.
+-- NULL — метрика записана до появления колонки
+ALTER TABLE metrics ADD COLUMN IF NOT EXISTS observed_at TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE metrics DROP COLUMN IF EXISTS observed_at;
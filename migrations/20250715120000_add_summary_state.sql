@@ -0,0 +1,7 @@
+-- +goose Up
+-- Состояние summary (скетч DDSketch) хранится в той же колонке state, что и у histogram
+COMMENT ON COLUMN metrics.state IS 'Состояние метрик составных типов (histogram, summary); value и delta у них пустые';
+
+-- +goose Down
+DELETE FROM metrics WHERE type = 'summary';
+COMMENT ON COLUMN metrics.state IS NULL;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Состояние set (HyperLogLog) хранится в колонке state
+COMMENT ON COLUMN metrics.state IS 'Состояние метрик составных типов (histogram, summary, set); value и delta у них пустые';
+
+-- +goose Down
+DELETE FROM metrics WHERE type = 'set';
+COMMENT ON COLUMN metrics.state IS 'Состояние метрик составных типов (histogram, summary); value и delta у них пустые';
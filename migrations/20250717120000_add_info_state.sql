@@ -0,0 +1,7 @@
+-- +goose Up
+-- Строковое значение info хранится в колонке state
+COMMENT ON COLUMN metrics.state IS 'Состояние метрик составных типов (histogram, summary, set) и значение info; value и delta у них пустые';
+
+-- +goose Down
+DELETE FROM metrics WHERE type = 'info';
+COMMENT ON COLUMN metrics.state IS 'Состояние метрик составных типов (histogram, summary, set); value и delta у них пустые';
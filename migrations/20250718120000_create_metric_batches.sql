@@ -0,0 +1,12 @@
+-- +goose Up
+-- Идентификаторы применённых батчей: повтор батча и после перезапуска сервера не применяется второй раз.
+-- Записываются в той же транзакции, что и метрики; устаревшие удаляются при записи новых.
+CREATE TABLE IF NOT EXISTS metric_batches (
+    id TEXT PRIMARY KEY,
+    applied_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS metric_batches_applied_at_idx ON metric_batches (applied_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS metric_batches;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Индекс для удаления истории старше срока хранения (HISTORY_RETENTION)
+CREATE INDEX IF NOT EXISTS metrics_history_recorded_at_idx ON metrics_history (recorded_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS metrics_history_recorded_at_idx;
@@ -0,0 +1,25 @@
+-- +goose Up
+-- История counter хранит приращения, накопленное значение вычисляется при чтении:
+-- приращение, пришедшее не по порядку, учитывается во всех более поздних точках.
+-- Первое сохранённое значение серии остаётся как есть — при чтении оно не используется.
+UPDATE metrics_history h SET value = d.delta
+FROM (
+    SELECT id, value - LAG(value) OVER (PARTITION BY type, name, labels ORDER BY recorded_at, id) AS delta
+    FROM metrics_history
+    WHERE type = 'counter'
+) d
+WHERE h.id = d.id AND d.delta IS NOT NULL;
+
+-- +goose Down
+UPDATE metrics_history h SET value = t.total
+FROM (
+    SELECT h.id, m.delta - COALESCE(SUM(h.value) OVER (
+        PARTITION BY h.type, h.name, h.labels
+        ORDER BY h.recorded_at DESC, h.id DESC
+        ROWS BETWEEN UNBOUNDED PRECEDING AND 1 PRECEDING
+    ), 0) AS total
+    FROM metrics_history h
+    JOIN metrics m ON m.type = h.type AND m.name = h.name AND m.labels = h.labels
+    WHERE h.type = 'counter'
+) t
+WHERE h.id = t.id;